@@ -3,15 +3,33 @@ package app
 import (
 	"fmt"
 	"log"
+	"sender/internal/data/blockchain/block"
+	"sender/internal/data/blockchain/chain"
+	"sender/internal/data/blockchain/mempool"
 	"sender/internal/data/blockchain/transaction"
+	"sender/internal/data/dealevent"
 	"sender/internal/server/blockchain"
+	"sender/internal/server/blockchain/connectionpool"
 	"sender/internal/server/blockchain/protocol/message"
+	"sender/internal/storage/blockstore"
+	"sender/internal/storage/outbox"
+	"sender/internal/tracker"
+	"time"
 )
 
 type AppState struct {
 	Server       *blockchain.Server
-	KafkaChan    chan message.MessageInterface
+	KafkaChan    chan dealevent.Event
 	ProtocolChan chan message.Message
+	BlockStore   *blockstore.Store
+	Chain        *chain.Chain
+	Confirmer    *chain.Confirmer
+	Mempool      *mempool.Mempool
+	Tracker      *tracker.Tracker
+	Outbox       *outbox.Outbox
+	Pool         *connectionpool.ConnectionPool
+	// Penalties returns the invalid messages counted per peer address
+	Penalties func() map[string]int
 }
 
 // func NewAppState(server *blockchain.Server) AppState {
@@ -22,13 +40,298 @@ type AppState struct {
 // 	}
 // }
 
-func (s *AppState) ReadBlock(blockMessage *message.BlockMessage) {
-	s.KafkaChan <- blockMessage
+// StoreBlock persists the block in the local block store
+func (s *AppState) StoreBlock(newBlock *block.Block) error {
+	if s.BlockStore == nil {
+		return nil
+	}
+
+	hash, err := s.BlockStore.Append(newBlock)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Block %d stored, hash: %s", newBlock.ID, hash)
+	return nil
+}
+
+// AddBlock links the block into the local chain.
+// Without a chain every block is treated as extending the best chain.
+func (s *AppState) AddBlock(newBlock *block.Block) (chain.Result, error) {
+	result := chain.Result{Status: chain.Extended, Applied: []*block.Block{newBlock}}
+	if s.Chain != nil {
+		var err error
+		result, err = s.Chain.Add(newBlock)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	s.updateMempool(result)
+	s.trackBlocks(result)
+	return result, nil
+}
+
+// updateMempool drops transactions that were mined and returns transactions
+// of blocks that left the best chain back to the pool
+func (s *AppState) updateMempool(result chain.Result) {
+	if s.Mempool == nil {
+		return
+	}
+
+	for _, reverted := range result.Reverted {
+		for i := range reverted.Transactions {
+			s.Mempool.Restore(&reverted.Transactions[i])
+		}
+	}
+	for _, applied := range result.Applied {
+		if pruned := s.Mempool.PruneBlock(applied); len(pruned) > 0 {
+			log.Printf("Block %d included %d pending transactions", applied.ID, len(pruned))
+		}
+	}
+}
+
+// trackBlocks records deals whose transactions joined or left the best chain
+func (s *AppState) trackBlocks(result chain.Result) {
+	for _, reverted := range result.Reverted {
+		for _, tx := range reverted.Transactions {
+			s.Tracker.RecordBlock(tx.Signature, tracker.Reverted, 0, "", 0)
+		}
+	}
+	for _, applied := range result.Applied {
+		hash, _ := applied.Hash()
+		for i, tx := range applied.Transactions {
+			s.attachDeal(&applied.Transactions[i])
+			s.Tracker.RecordBlock(tx.Signature, tracker.InBlock, applied.ID, hash, 1)
+		}
+	}
+}
+
+// attachDeal links a transaction the tracker does not know yet to its deal,
+// so a redelivered deal that already has a transaction is not signed again
+func (s *AppState) attachDeal(tx *transaction.Transaction) {
+	if s.Tracker == nil {
+		return
+	}
+	if _, exists := s.Tracker.GetBySignature(tx.Signature); exists {
+		return
+	}
+
+	if dealID, ok := tx.DealID(); ok {
+		id, _ := tx.ID()
+		s.Tracker.AttachTransaction(dealID, tx.Signature, id)
+	}
+}
+
+// RestoreMempool links pending transactions kept on disk to their deals.
+// The kafka offset of a deal is committed after its transaction is in the
+// mempool, a redelivery after a restart finds the deal in flight.
+func (s *AppState) RestoreMempool() {
+	if s.Mempool == nil {
+		return
+	}
+
+	for _, entry := range s.Mempool.Entries() {
+		s.attachDeal(entry.Transaction)
+		s.Tracker.RecordBySignature(entry.Transaction.Signature, tracker.Pending, entry.ID)
+	}
+}
+
+// RestoreChain rebuilds the chain from the blocks kept in the block store
+func (s *AppState) RestoreChain() {
+	if s.Chain == nil || s.BlockStore == nil {
+		return
+	}
+
+	for _, storedBlock := range s.BlockStore.Blocks() {
+		if _, err := s.AddBlock(storedBlock); err != nil {
+			log.Printf("Failed to restore block %d: %v", storedBlock.ID, err)
+		}
+	}
+
+	if tip, ok := s.Chain.Tip(); ok {
+		log.Printf("Chain restored, tip: %d %s", tip.Height, tip.Hash)
+	}
+
+	// a block may be stored without its events when the node stopped before
+	// they reached the outbox, blocks above the last announced one are published again
+	if s.Confirmer != nil {
+		s.Confirmer.Prime()
+		s.publishConfirmed(s.Confirmer.Pending())
+		s.saveConfirmer()
+	}
+}
+
+// PublishChainUpdate sends deal events for blocks that reached the confirmation
+// depth and for confirmed blocks that left the best chain.
+// Without a confirmer every applied block is published immediately.
+func (s *AppState) PublishChainUpdate(result chain.Result) {
+	if s.Confirmer == nil {
+		for _, applied := range result.Applied {
+			hash, _ := applied.Hash()
+			s.logPublishError(s.publish(dealevent.FromBlock(dealevent.Confirmed, applied, hash, 1)))
+		}
+		return
+	}
+
+	confirmed, reverted := s.Confirmer.Process(result)
+	for _, revertedBlock := range reverted {
+		log.Printf("Confirmed block %d %s left the best chain", revertedBlock.Block.ID, revertedBlock.Hash)
+		s.logPublishError(s.publish(dealevent.FromBlock(dealevent.Reverted, revertedBlock.Block, revertedBlock.Hash, 0)))
+	}
+	s.publishConfirmed(confirmed)
+
+	if len(confirmed) > 0 || len(reverted) > 0 {
+		s.saveConfirmer()
+	}
+}
+
+// publishConfirmed publishes the events of confirmed blocks in order.
+// A block whose events could not be stored is not announced, it and the blocks
+// above it are published again with the next chain update.
+func (s *AppState) publishConfirmed(confirmed []chain.ConfirmedBlock) {
+	for i, confirmedBlock := range confirmed {
+		log.Printf("Block %d %s confirmed (%d)", confirmedBlock.Block.ID, confirmedBlock.Hash, confirmedBlock.Confirmations)
+		err := s.publish(dealevent.FromBlock(dealevent.Confirmed, confirmedBlock.Block, confirmedBlock.Hash, confirmedBlock.Confirmations))
+		if err != nil {
+			log.Printf("Failed to publish confirmed block %d %s: %v", confirmedBlock.Block.ID, confirmedBlock.Hash, err)
+			s.Confirmer.Forget(confirmed[i:])
+			return
+		}
+	}
+}
+
+// saveConfirmer remembers the last announced block once its events are in the outbox
+func (s *AppState) saveConfirmer() {
+	if err := s.Confirmer.Save(); err != nil {
+		log.Printf("Failed to save confirmed blocks: %v", err)
+	}
 }
 
-func (s *AppState) SendTransaction(transaction *transaction.Transaction) {
+// publish moves the tracked deals and hands the events to kafka.
+// With an outbox the events are on disk when publish returns, so a block is
+// never reported as confirmed while its events only live in memory.
+func (s *AppState) publish(events []dealevent.Event) error {
+	for _, event := range events {
+		s.track(event)
+		if event.CorrelationID == "" {
+			if rec, exists := s.Tracker.GetBySignature(event.Transaction.Signature); exists {
+				event.CorrelationID = rec.CorrelationID
+			}
+		}
+
+		if s.Outbox != nil {
+			if err := s.storeEvent(event); err != nil {
+				return err
+			}
+			continue
+		}
+		s.KafkaChan <- event
+	}
+	return nil
+}
+
+func (s *AppState) logPublishError(err error) {
+	if err != nil {
+		log.Printf("Failed to publish deal event: %v", err)
+	}
+}
+
+// storeEvent appends the event to the outbox, retrying until the disk accepts it.
+// An event without a valid payload is never stored and its error is returned.
+func (s *AppState) storeEvent(event dealevent.Event) error {
+	payload, err := event.Payload()
+	if err != nil {
+		return fmt.Errorf("failed to build %s payload: %w", event.Kind, err)
+	}
+
+	// событие не должно потеряться, пока диск недоступен
+	for attempt := 1; ; attempt++ {
+		_, err := s.Outbox.Append(outbox.Entry{
+			Kind:    string(event.Kind),
+			Key:     event.Key(),
+			Value:   string(payload),
+			Headers: event.Headers(),
+		})
+		if err == nil {
+			return nil
+		}
+		delay := outbox.DefaultBackoff.Delay(attempt)
+		log.Printf("Failed to store %s in outbox, retrying in %s: %v", event.Kind, delay, err)
+		time.Sleep(delay)
+	}
+}
+
+// track records the event in the deal tracker.
+// Reverted deals are already recorded by trackBlocks.
+func (s *AppState) track(event dealevent.Event) {
+	signature := event.Transaction.Signature
+	switch event.Kind {
+	case dealevent.Confirmed:
+		blockID := 0
+		if event.Block != nil {
+			blockID = event.Block.ID
+		}
+		s.Tracker.RecordBlock(signature, tracker.Confirmed, blockID, event.BlockHash, event.Confirmations)
+	case dealevent.Failed:
+		s.Tracker.RecordBySignature(signature, tracker.Failed, event.Reason)
+	}
+}
+
+// RejectDeal records a deal that will not be signed and publishes DealRejected.
+// dealID is 0 if the message could not be parsed.
+func (s *AppState) RejectDeal(dealID int, correlationID string, message []byte, err error) {
+	if dealID != 0 {
+		s.Tracker.Record(dealID, tracker.Rejected, err.Error())
+	}
+
+	event := dealevent.NewRejected(dealID, message, err)
+	event.CorrelationID = correlationID
+	s.logPublishError(s.publish([]dealevent.Event{event}))
+}
+
+// SendTransaction puts the transaction into the mempool and broadcasts it.
+// If the mempool cannot record the transaction nothing is broadcast and the
+// error is returned, so the caller can retry the deal. A persisted mempool has
+// the transaction on disk when SendTransaction returns nil; lost broadcasts are
+// repeated by RunMempool.
+func (s *AppState) SendTransaction(transaction *transaction.Transaction) error {
+	if s.Mempool != nil {
+		id, _, err := s.Mempool.Add(transaction)
+		if err != nil {
+			return fmt.Errorf("failed to add transaction %s to mempool: %w", id, err)
+		}
+		s.Mempool.MarkBroadcast(id)
+		s.Tracker.RecordBySignature(transaction.Signature, tracker.Pending, id)
+	}
+
 	messageTransaction := message.NewTransactionMessage(transaction)
 	s.ProtocolChan <- messageTransaction
+	s.Tracker.RecordBySignature(transaction.Signature, tracker.Broadcast, "")
+	return nil
+}
+
+// RunMempool periodically rebroadcasts pending transactions and reports
+// the ones that were not included in a block before their max age
+func (s *AppState) RunMempool(interval time.Duration) {
+	if s.Mempool == nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		for _, expired := range s.Mempool.Expire(now) {
+			log.Printf("Transaction %s expired after %d broadcasts", expired.ID, expired.Broadcasts)
+			s.logPublishError(s.publish([]dealevent.Event{dealevent.NewFailed(*expired.Transaction, "not included in a block before max age")}))
+		}
+
+		for _, due := range s.Mempool.DueForRebroadcast(now) {
+			log.Printf("Rebroadcasting pending transaction")
+			s.ProtocolChan <- message.NewTransactionMessage(due)
+		}
+	}
 }
 
 func (s *AppState) Connect(ipAddr string) {
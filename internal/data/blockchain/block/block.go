@@ -1,23 +1,66 @@
 package block
 
 import (
+	"bytes"
+	"encoding/json"
 	"sender/internal/data/blockchain/transaction"
 	"sender/internal/jsonutil"
 )
 
 type Block struct {
 	ID           int                       `json:"id"`
-	TimeCreated  int64                     `json:"time_create"`
+	TimeCreated  Timestamp                 `json:"time_create"`
 	Transactions []transaction.Transaction `json:"transactions"`
 	PreviousHash string                    `json:"previous_hash"`
 	Nonce        uint64                    `json:"nonce"`
+
+	// Transactions exactly as the block author encoded them (compacted),
+	// nil for blocks built locally, see CanonicalBytes
+	rawTransactions []json.RawMessage
 }
 
 func (b *Block) ToJson() ([]byte, error) {
 	if b.Transactions == nil {
 		b.Transactions = []transaction.Transaction{}
 	}
-	return jsonutil.ToJSON(b)
+	return jsonutil.Marshal(b)
+}
+
+// MarshalJSON writes received transactions back as they came from the wire
+func (b Block) MarshalJSON() ([]byte, error) {
+	return b.CanonicalBytes()
+}
+
+// UnmarshalJSON decodes the block and keeps the raw JSON of every transaction
+func (b *Block) UnmarshalJSON(data []byte) error {
+	type plainBlock Block
+	var wire struct {
+		*plainBlock
+		Transactions []json.RawMessage `json:"transactions"`
+	}
+	wire.plainBlock = (*plainBlock)(b)
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+
+	b.Transactions, b.rawTransactions = nil, nil
+	if wire.Transactions == nil {
+		return nil
+	}
+
+	b.Transactions = make([]transaction.Transaction, len(wire.Transactions))
+	b.rawTransactions = make([]json.RawMessage, len(wire.Transactions))
+	for i, raw := range wire.Transactions {
+		if err := json.Unmarshal(raw, &b.Transactions[i]); err != nil {
+			return err
+		}
+		var compacted bytes.Buffer
+		if err := json.Compact(&compacted, raw); err != nil {
+			return err
+		}
+		b.rawTransactions[i] = compacted.Bytes()
+	}
+	return nil
 }
 
 func FromJSON(blockJson []byte) (*Block, error) {
@@ -4,17 +4,18 @@ import (
 	"encoding/json"
 	"sender/internal/data/blockchain/block"
 	"sender/internal/data/blockchain/transaction"
+	"sender/internal/data/decimal"
 	"testing"
 	"time"
 )
 
-func generateTestTransaction(amount float64) transaction.Transaction {
+func generateTestTransaction(amount int64) transaction.Transaction {
 	return transaction.Transaction{
 		Sender:          "test_sender",
 		BuyerPublicKey:  "test_buyer",
 		SellerPublicKey: "test_seller",
 		DealMessage:     "test_message",
-		Transfer:        amount,
+		Transfer:        decimal.New(amount, 0),
 		Signature:       "test_signature",
 	}
 }
@@ -22,7 +23,7 @@ func generateTestTransaction(amount float64) transaction.Transaction {
 func TestBlock_ToJson(t *testing.T) {
 	block := &block.Block{
 		ID:           1,
-		TimeCreated:  time.Now().UTC().Unix(),
+		TimeCreated:  block.NewTimestamp(time.Now()),
 		Transactions: []transaction.Transaction{generateTestTransaction(100)},
 		PreviousHash: "abc123",
 		Nonce:        42,
@@ -76,8 +77,8 @@ func TestFromJSON(t *testing.T) {
 	if tx.Sender != "test_sender" {
 		t.Errorf("Expected sender 'test_sender', got '%s'", tx.Sender)
 	}
-	if tx.Transfer != 100 {
-		t.Errorf("Expected transfer 100, got %f", tx.Transfer)
+	if !tx.Transfer.Equal(decimal.New(100, 0)) {
+		t.Errorf("Expected transfer 100, got %s", tx.Transfer)
 	}
 }
 
@@ -106,7 +107,7 @@ func TestBlock_GetTransactions(t *testing.T) {
 	}
 
 	for i, tx := range result {
-		if tx.Sender != transactions[i].Sender || tx.Transfer != transactions[i].Transfer {
+		if tx.Sender != transactions[i].Sender || !tx.Transfer.Equal(transactions[i].Transfer) {
 			t.Errorf("Transaction mismatch at index %d: expected %+v, got %+v", i, transactions[i], tx)
 		}
 	}
@@ -0,0 +1,148 @@
+package block
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sender/internal/jsonutil"
+	"strings"
+	"time"
+)
+
+// DefaultDifficulty is the number of leading zero hex digits the network requires
+const DefaultDifficulty = 3
+
+// maxFutureDrift is how far ahead of local time a block timestamp may be
+const maxFutureDrift = 2 * time.Hour
+
+// hashHexLength is the length of a hex encoded SHA-512 hash
+const hashHexLength = sha512.Size * 2
+
+var (
+	ErrNilBlock          = errors.New("block is nil")
+	ErrInsufficientWork  = errors.New("block hash does not meet difficulty")
+	ErrInvalidPrevious   = errors.New("invalid previous hash")
+	ErrInvalidBlockID    = errors.New("invalid block id")
+	ErrTimestampInFuture = errors.New("block timestamp is too far in the future")
+	ErrInvalidDifficulty = errors.New("difficulty must not be negative")
+)
+
+// canonicalBlock fixes the field order of the network's block encoding.
+// Miners hash exactly this JSON: id, time_create, transactions, previous_hash, nonce.
+// time_create and the transactions are written back byte for byte as they came
+// from the wire: re-encoding would escape <, > and &, rewrite decimals such as
+// 5000.0 and add or drop empty fields.
+type canonicalBlock struct {
+	ID           int               `json:"id"`
+	TimeCreated  Timestamp         `json:"time_create"`
+	Transactions []json.RawMessage `json:"transactions"`
+	PreviousHash string            `json:"previous_hash"`
+	Nonce        uint64            `json:"nonce"`
+}
+
+// CanonicalBytes returns the encoding of the block that is used for hashing
+func (b *Block) CanonicalBytes() ([]byte, error) {
+	if b == nil {
+		return nil, ErrNilBlock
+	}
+
+	transactions, err := b.transactionsJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	return jsonutil.Marshal(canonicalBlock{
+		ID:           b.ID,
+		TimeCreated:  b.TimeCreated,
+		Transactions: transactions,
+		PreviousHash: b.PreviousHash,
+		Nonce:        b.Nonce,
+	})
+}
+
+// transactionsJSON returns the raw transactions of a received block,
+// or encodes Transactions of a block built locally
+func (b *Block) transactionsJSON() ([]json.RawMessage, error) {
+	if b.rawTransactions != nil && len(b.rawTransactions) == len(b.Transactions) {
+		return b.rawTransactions, nil
+	}
+
+	transactions := make([]json.RawMessage, len(b.Transactions))
+	for i := range b.Transactions {
+		data, err := jsonutil.Marshal(&b.Transactions[i])
+		if err != nil {
+			return nil, err
+		}
+		transactions[i] = data
+	}
+	return transactions, nil
+}
+
+// Hash returns the hex encoded SHA-512 hash of the canonical block encoding
+func (b *Block) Hash() (string, error) {
+	data, err := b.CanonicalBytes()
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha512.Sum512(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// MeetsDifficulty reports whether the hash starts with at least difficulty zero digits
+func MeetsDifficulty(hash string, difficulty int) bool {
+	if difficulty <= 0 {
+		return true
+	}
+	if len(hash) < difficulty {
+		return false
+	}
+	return strings.Count(hash[:difficulty], "0") == difficulty
+}
+
+// LeadingZeros returns the number of leading zero hex digits of the hash
+func LeadingZeros(hash string) int {
+	return len(hash) - len(strings.TrimLeft(hash, "0"))
+}
+
+// Validate checks the block structure and its proof of work
+func (b *Block) Validate(difficulty int) error {
+	if b == nil {
+		return ErrNilBlock
+	}
+	if difficulty < 0 {
+		return ErrInvalidDifficulty
+	}
+	if b.ID < 0 {
+		return fmt.Errorf("%w: %d", ErrInvalidBlockID, b.ID)
+	}
+
+	if b.PreviousHash != "" {
+		if len(b.PreviousHash) != hashHexLength {
+			return fmt.Errorf("%w: expected %d hex digits, got %d", ErrInvalidPrevious, hashHexLength, len(b.PreviousHash))
+		}
+		if _, err := hex.DecodeString(b.PreviousHash); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidPrevious, err)
+		}
+	}
+
+	created, err := b.TimeCreated.Time()
+	if err != nil {
+		return err
+	}
+	if created.After(time.Now().Add(maxFutureDrift)) {
+		return ErrTimestampInFuture
+	}
+
+	hash, err := b.Hash()
+	if err != nil {
+		return err
+	}
+	if !MeetsDifficulty(hash, difficulty) {
+		return fmt.Errorf("%w: %s (difficulty %d)", ErrInsufficientWork, hash, difficulty)
+	}
+
+	return nil
+}
@@ -0,0 +1,251 @@
+package block_test
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"regexp"
+	"sender/internal/data/blockchain/block"
+	"sender/internal/data/blockchain/transaction"
+	"sender/internal/jsonutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mine перебирает nonce, пока хеш блока не удовлетворит сложности
+func mine(t *testing.T, b *block.Block, difficulty int) string {
+	t.Helper()
+	for nonce := uint64(0); nonce < 1_000_000; nonce++ {
+		b.Nonce = nonce
+		hash, err := b.Hash()
+		if err != nil {
+			t.Fatalf("Hash failed: %v", err)
+		}
+		if block.MeetsDifficulty(hash, difficulty) {
+			return hash
+		}
+	}
+	t.Fatal("Failed to mine block")
+	return ""
+}
+
+func TestBlock_HashIsDeterministic(t *testing.T) {
+	b := &block.Block{ID: 1, TimeCreated: block.NewTimestamp(time.Unix(1745089962, 0)), PreviousHash: "abc", Nonce: 7}
+
+	first, err := b.Hash()
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+	second, _ := b.Hash()
+	if first != second {
+		t.Errorf("Expected equal hashes, got %s and %s", first, second)
+	}
+	if len(first) != 128 {
+		t.Errorf("Expected SHA-512 hex hash of 128 chars, got %d", len(first))
+	}
+
+	// nil и пустой список транзакций кодируются одинаково
+	withEmpty := &block.Block{ID: 1, TimeCreated: block.NewTimestamp(time.Unix(1745089962, 0)), PreviousHash: "abc", Nonce: 7, Transactions: []transaction.Transaction{}}
+	emptyHash, _ := withEmpty.Hash()
+	if first != emptyHash {
+		t.Errorf("Expected nil and empty transactions to hash equally")
+	}
+
+	b.Nonce = 8
+	changed, _ := b.Hash()
+	if changed == first {
+		t.Error("Expected hash to change with nonce")
+	}
+}
+
+func TestBlock_CanonicalBytes(t *testing.T) {
+	// блок 4 из json_example.json в том виде, в котором его хеширует майнер
+	wire := `{"id":4,"time_create":"2024-12-17T13:22:03.166566100Z","transactions":[],"previous_hash":"000159c13b2e192c546583a72027d99f3053f32dda5dba89eeb9d9444908b484e66239a9f27f1bba6c66c2d8373bb258abda969293b3def80833bd0bf4ef9483","nonce":9611}`
+	b, err := block.FromJSON([]byte(wire))
+	if err != nil {
+		t.Fatalf("FromJSON failed: %v", err)
+	}
+
+	data, err := b.CanonicalBytes()
+	if err != nil {
+		t.Fatalf("CanonicalBytes failed: %v", err)
+	}
+	if string(data) != wire {
+		t.Errorf("Expected %s, got %s", wire, data)
+	}
+
+	// старые блоки с unix-временем хешируются так же, как пришли
+	legacyWire := `{"id":4,"time_create":10,"transactions":[],"previous_hash":"00ab","nonce":9611}`
+	legacy, err := block.FromJSON([]byte(legacyWire))
+	if err != nil {
+		t.Fatalf("FromJSON of unix timestamp failed: %v", err)
+	}
+	data, _ = legacy.CanonicalBytes()
+	if string(data) != legacyWire {
+		t.Errorf("Expected %s, got %s", legacyWire, data)
+	}
+}
+
+// exampleTransaction достает первую транзакцию из логов узла в json_example.json
+var exampleTransaction = regexp.MustCompile(`"transaction":(\{[^}]*\})`)
+
+func TestBlock_CanonicalBytesKeepsRawTransactions(t *testing.T) {
+	data, err := os.ReadFile("../../../../json_example.json")
+	if err != nil {
+		t.Fatalf("Failed to read json_example.json: %v", err)
+	}
+	match := exampleTransaction.FindSubmatch(data)
+	if match == nil {
+		t.Fatal("Expected a transaction in json_example.json")
+	}
+	var example bytes.Buffer
+	if err := json.Compact(&example, match[1]); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+
+	// майнер пишет 12.0, не экранирует <>& и экранирует кириллицу,
+	// в транзакции нет полей version, nonce и created_at
+	deal := `{"sender":"s","buyer":"b","seller":"c","message":"{\"typeName\":\"\u041f\u043e\u043a\u0443\u043f\u043a\u0430\",\"note\":\"<a&b>\"}","transfer":5000.0,"signature":"sig"}`
+	wire := `{"id":5,"time_create":"2024-12-17T13:22:05.004371500Z","transactions":[` + example.String() + `,` + deal + `],"previous_hash":"00ab","nonce":42}`
+
+	b, err := block.FromJSON([]byte(wire))
+	if err != nil {
+		t.Fatalf("FromJSON failed: %v", err)
+	}
+	if len(b.Transactions) != 2 || !strings.Contains(b.Transactions[1].DealMessage, "Покупка") {
+		t.Fatalf("Expected decoded transactions, got %+v", b.Transactions)
+	}
+
+	canonical, err := b.CanonicalBytes()
+	if err != nil {
+		t.Fatalf("CanonicalBytes failed: %v", err)
+	}
+	if string(canonical) != wire {
+		t.Fatalf("Expected raw transactions to be hashed as received:\n got %s\nwant %s", canonical, wire)
+	}
+
+	sum := sha512.Sum512([]byte(wire))
+	hash, _ := b.Hash()
+	if hash != hex.EncodeToString(sum[:]) {
+		t.Errorf("Expected hash over the wire bytes, got %s", hash)
+	}
+
+	// блок, пересланный другим узлам или записанный на диск, сохраняет хеш
+	relayed, err := jsonutil.Marshal(b)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(relayed) != wire {
+		t.Errorf("Expected the block to be relayed byte for byte, got %s", relayed)
+	}
+	received, err := block.FromJSON(relayed)
+	if err != nil {
+		t.Fatalf("FromJSON of relayed block failed: %v", err)
+	}
+	if relayedHash, _ := received.Hash(); relayedHash != hash {
+		t.Errorf("Expected relayed block hash %s, got %s", hash, relayedHash)
+	}
+}
+
+// exampleBlock достает блоки из логов узла в json_example.json
+var exampleBlock = regexp.MustCompile(`"block":(\{"id":\d+,"time_create":"[^"]+","transactions":\[\],"previous_hash":"[0-9a-f]+","nonce":\d+\})`)
+
+func TestBlock_HashMatchesExampleChain(t *testing.T) {
+	data, err := os.ReadFile("../../../../json_example.json")
+	if err != nil {
+		t.Fatalf("Failed to read json_example.json: %v", err)
+	}
+
+	matches := exampleBlock.FindAllSubmatch(data, -1)
+	if len(matches) < 2 {
+		t.Fatalf("Expected a chain of blocks in json_example.json, got %d", len(matches))
+	}
+
+	blocks := make([]*block.Block, 0, len(matches))
+	for _, match := range matches {
+		b, err := block.FromJSON(match[1])
+		if err != nil {
+			t.Fatalf("FromJSON failed: %v", err)
+		}
+		blocks = append(blocks, b)
+	}
+
+	for i := 0; i+1 < len(blocks); i++ {
+		hash, err := blocks[i].Hash()
+		if err != nil {
+			t.Fatalf("Hash failed: %v", err)
+		}
+		if next := blocks[i+1]; next.PreviousHash != hash {
+			t.Errorf("Block %d: expected previous_hash %s, got hash %s of block %d", next.ID, next.PreviousHash, hash, blocks[i].ID)
+		}
+		if err := blocks[i].Validate(block.DefaultDifficulty); err != nil {
+			t.Errorf("Block %d failed validation: %v", blocks[i].ID, err)
+		}
+	}
+}
+
+func TestMeetsDifficulty(t *testing.T) {
+	hash := "000159c13b2e"
+	if !block.MeetsDifficulty(hash, 3) {
+		t.Error("Expected hash to meet difficulty 3")
+	}
+	if block.MeetsDifficulty(hash, 4) {
+		t.Error("Expected hash not to meet difficulty 4")
+	}
+	if !block.MeetsDifficulty("abc", 0) {
+		t.Error("Difficulty 0 must always be met")
+	}
+	if block.LeadingZeros(hash) != 3 {
+		t.Errorf("Expected 3 leading zeros, got %d", block.LeadingZeros(hash))
+	}
+}
+
+func TestBlock_Validate(t *testing.T) {
+	previousHash := strings.Repeat("0", 3) + strings.Repeat("a", 125)
+	b := &block.Block{ID: 2, TimeCreated: block.NewTimestamp(time.Now()), PreviousHash: previousHash}
+	mine(t, b, 2)
+
+	if err := b.Validate(2); err != nil {
+		t.Fatalf("Expected mined block to be valid, got %v", err)
+	}
+
+	t.Run("Nil block", func(t *testing.T) {
+		var nilBlock *block.Block
+		if err := nilBlock.Validate(2); !errors.Is(err, block.ErrNilBlock) {
+			t.Errorf("Expected ErrNilBlock, got %v", err)
+		}
+	})
+
+	t.Run("Tampered block", func(t *testing.T) {
+		tampered := *b
+		tampered.Transactions = []transaction.Transaction{{Sender: "attacker", Signature: "forged"}}
+		hash, _ := tampered.Hash()
+		err := tampered.Validate(2)
+		if block.MeetsDifficulty(hash, 2) {
+			t.Skip("Tampered block meets difficulty by chance")
+		}
+		if !errors.Is(err, block.ErrInsufficientWork) {
+			t.Errorf("Expected ErrInsufficientWork, got %v", err)
+		}
+	})
+
+	t.Run("Invalid previous hash", func(t *testing.T) {
+		invalid := *b
+		invalid.PreviousHash = "not-a-hash"
+		if err := invalid.Validate(0); !errors.Is(err, block.ErrInvalidPrevious) {
+			t.Errorf("Expected ErrInvalidPrevious, got %v", err)
+		}
+	})
+
+	t.Run("Timestamp in future", func(t *testing.T) {
+		future := *b
+		future.TimeCreated = block.NewTimestamp(time.Now().Add(24 * time.Hour))
+		if err := future.Validate(0); !errors.Is(err, block.ErrTimestampInFuture) {
+			t.Errorf("Expected ErrTimestampInFuture, got %v", err)
+		}
+	})
+}
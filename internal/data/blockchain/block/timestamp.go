@@ -0,0 +1,79 @@
+package block
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+var ErrInvalidTimestamp = errors.New("invalid block timestamp")
+
+// Timestamp keeps time_create exactly as the block author encoded it.
+// Miners send an RFC 3339 string and hash those bytes, older blocks carry
+// unix seconds, so the raw JSON value is preserved for hashing.
+type Timestamp struct {
+	raw json.RawMessage
+}
+
+// NewTimestamp returns the RFC 3339 timestamp the miners use
+func NewTimestamp(t time.Time) Timestamp {
+	return Timestamp{raw: json.RawMessage(strconv.Quote(t.UTC().Format(time.RFC3339Nano)))}
+}
+
+// IsZero reports whether the timestamp is unset
+func (ts Timestamp) IsZero() bool {
+	return len(ts.raw) == 0
+}
+
+// Time parses the timestamp, either an RFC 3339 string or unix seconds
+func (ts Timestamp) Time() (time.Time, error) {
+	if ts.IsZero() {
+		return time.Unix(0, 0).UTC(), nil
+	}
+
+	if ts.raw[0] == '"' {
+		var value string
+		if err := json.Unmarshal(ts.raw, &value); err != nil {
+			return time.Time{}, fmt.Errorf("%w: %v", ErrInvalidTimestamp, err)
+		}
+		parsed, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: %v", ErrInvalidTimestamp, err)
+		}
+		return parsed, nil
+	}
+
+	seconds, err := strconv.ParseInt(string(ts.raw), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %v", ErrInvalidTimestamp, err)
+	}
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+func (ts Timestamp) String() string {
+	return string(ts.raw)
+}
+
+func (ts Timestamp) MarshalJSON() ([]byte, error) {
+	if ts.IsZero() {
+		return []byte("0"), nil
+	}
+	return ts.raw, nil
+}
+
+func (ts *Timestamp) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		ts.raw = nil
+		return nil
+	}
+	if len(data) == 0 || (data[0] != '"' && (data[0] < '0' || data[0] > '9')) {
+		return fmt.Errorf("%w: %s", ErrInvalidTimestamp, data)
+	}
+
+	ts.raw = append(json.RawMessage(nil), data...)
+	return nil
+}
@@ -0,0 +1,470 @@
+package chain
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sender/internal/data/blockchain/block"
+	"sort"
+	"sync"
+)
+
+// DefaultMaxOrphans limits how many blocks without a known parent are kept in memory,
+// the oldest orphan is evicted when the pool is full
+const DefaultMaxOrphans = 256
+
+var ErrInvalidHeight = errors.New("block id does not follow its parent")
+
+// Status describes what happened to the best chain after a block was added
+type Status int
+
+const (
+	// Extended - the block (and possibly connected orphans) extended the best chain
+	Extended Status = iota
+	// Reorganized - a competing branch overtook the best chain
+	Reorganized
+	// SideBranch - the block was linked but the best chain did not change
+	SideBranch
+	// Orphaned - the parent is unknown, the block is held until it arrives
+	Orphaned
+	// Duplicate - the block is already known
+	Duplicate
+)
+
+func (s Status) String() string {
+	switch s {
+	case Extended:
+		return "extended"
+	case Reorganized:
+		return "reorganized"
+	case SideBranch:
+		return "side_branch"
+	case Orphaned:
+		return "orphaned"
+	case Duplicate:
+		return "duplicate"
+	default:
+		return fmt.Sprintf("Status(%d)", int(s))
+	}
+}
+
+// Result is the outcome of Chain.Add
+type Result struct {
+	Status Status
+	Hash   string
+	// Blocks that joined the best chain, parent first
+	Applied []*block.Block
+	// Blocks that left the best chain, tip first
+	Reverted []*block.Block
+}
+
+// TipInfo describes the head of a branch
+type TipInfo struct {
+	Hash   string `json:"hash"`
+	Height int    `json:"height"`
+	Work   string `json:"work"`
+	Best   bool   `json:"best"`
+	// Height of the last block shared with the best chain
+	ForkHeight int `json:"fork_height"`
+}
+
+// node is a block linked into the block tree
+type node struct {
+	hash     string
+	block    *block.Block
+	parent   *node
+	children []*node
+	work     *big.Int
+}
+
+// Chain keeps the block tree, the orphan pool and the best (most work) tip
+type Chain struct {
+	mutex sync.RWMutex
+
+	nodes map[string]*node
+	tips  map[string]*node
+	best  *node
+
+	// hashes of trusted blocks that may be linked before their parent
+	checkpoints map[string]bool
+	// parent hash -> checkpoint blocks linked without that parent
+	roots map[string][]*node
+
+	// parent hash -> blocks waiting for that parent
+	orphans     map[string][]*block.Block
+	orphanCount int
+	maxOrphans  int
+	// orphans in arrival order, may still hold orphans that were connected
+	orphanOrder []*block.Block
+}
+
+// New creates an empty chain
+func New() *Chain {
+	return &Chain{
+		nodes:       make(map[string]*node),
+		tips:        make(map[string]*node),
+		checkpoints: make(map[string]bool),
+		roots:       make(map[string][]*node),
+		orphans:     make(map[string][]*block.Block),
+		maxOrphans:  DefaultMaxOrphans,
+	}
+}
+
+// SetCheckpoints sets the hashes of trusted blocks that start the tree
+// even when their parent is unknown. Any other block without a known parent,
+// except genesis, waits in the orphan pool.
+func (c *Chain) SetCheckpoints(hashes []string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.checkpoints = make(map[string]bool, len(hashes))
+	for _, hash := range hashes {
+		c.checkpoints[hash] = true
+	}
+}
+
+// SetMaxOrphans sets the maximum size of the orphan pool
+func (c *Chain) SetMaxOrphans(maxOrphans int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.maxOrphans = maxOrphans
+}
+
+// BlockWork returns the expected number of hashes needed to find the given hash
+func BlockWork(hash string) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), uint(4*block.LeadingZeros(hash)))
+}
+
+// Add links the block into the tree and updates the best chain.
+// The block must already be validated with block.Validate.
+func (c *Chain) Add(b *block.Block) (Result, error) {
+	hash, err := b.Hash()
+	if err != nil {
+		return Result{}, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.isKnown(hash, b.PreviousHash) {
+		return Result{Status: Duplicate, Hash: hash}, nil
+	}
+
+	parent, parentKnown := c.nodes[b.PreviousHash]
+	isRoot := b.PreviousHash == "" || c.checkpoints[hash]
+
+	if !parentKnown && !isRoot {
+		for c.orphanCount >= c.maxOrphans {
+			if !c.evictOldestOrphan() {
+				break
+			}
+		}
+		c.orphans[b.PreviousHash] = append(c.orphans[b.PreviousHash], b)
+		c.orphanCount++
+		c.orphanOrder = append(c.orphanOrder, b)
+		return Result{Status: Orphaned, Hash: hash}, nil
+	}
+
+	if parentKnown && b.ID != parent.block.ID+1 {
+		return Result{}, fmt.Errorf("%w: parent %d, block %d", ErrInvalidHeight, parent.block.ID, b.ID)
+	}
+
+	n := c.link(hash, b, parent)
+	if parent == nil && b.PreviousHash != "" {
+		c.roots[b.PreviousHash] = append(c.roots[b.PreviousHash], n)
+	}
+	c.connectOrphans(hash)
+
+	return c.updateBest(hash), nil
+}
+
+// isKnown reports whether the block is already linked or waiting in the orphan pool
+func (c *Chain) isKnown(hash string, previousHash string) bool {
+	if _, exists := c.nodes[hash]; exists {
+		return true
+	}
+
+	for _, orphan := range c.orphans[previousHash] {
+		if orphanHash, err := orphan.Hash(); err == nil && orphanHash == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// evictOldestOrphan drops the orphan that has waited the longest,
+// it returns false when the pool is empty
+func (c *Chain) evictOldestOrphan() bool {
+	for len(c.orphanOrder) > 0 {
+		oldest := c.orphanOrder[0]
+		c.orphanOrder = c.orphanOrder[1:]
+
+		waiting := c.orphans[oldest.PreviousHash]
+		for i, orphan := range waiting {
+			if orphan != oldest {
+				continue
+			}
+			if len(waiting) == 1 {
+				delete(c.orphans, oldest.PreviousHash)
+			} else {
+				c.orphans[oldest.PreviousHash] = append(waiting[:i:i], waiting[i+1:]...)
+			}
+			c.orphanCount--
+			return true
+		}
+	}
+	return false
+}
+
+// compactOrphanOrder forgets connected orphans once they outnumber the waiting ones
+func (c *Chain) compactOrphanOrder() {
+	if len(c.orphanOrder) <= 2*c.orphanCount {
+		return
+	}
+
+	waiting := make(map[*block.Block]bool, c.orphanCount)
+	for _, orphans := range c.orphans {
+		for _, orphan := range orphans {
+			waiting[orphan] = true
+		}
+	}
+
+	order := make([]*block.Block, 0, c.orphanCount)
+	for _, orphan := range c.orphanOrder {
+		if waiting[orphan] {
+			order = append(order, orphan)
+		}
+	}
+	c.orphanOrder = order
+}
+
+// link inserts the block into the tree
+func (c *Chain) link(hash string, b *block.Block, parent *node) *node {
+	work := BlockWork(hash)
+	if parent != nil {
+		work.Add(work, parent.work)
+		delete(c.tips, parent.hash)
+	}
+
+	n := &node{
+		hash:   hash,
+		block:  b,
+		parent: parent,
+		work:   work,
+	}
+	if parent != nil {
+		parent.children = append(parent.children, n)
+	}
+	c.nodes[hash] = n
+	c.tips[hash] = n
+	return n
+}
+
+// reparent attaches a checkpoint block linked without its parent
+// and adds the parent work to the whole subtree
+func (c *Chain) reparent(n *node, parent *node) {
+	n.parent = parent
+	parent.children = append(parent.children, n)
+	delete(c.tips, parent.hash)
+
+	stack := []*node{n}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		current.work.Add(current.work, parent.work)
+		stack = append(stack, current.children...)
+	}
+}
+
+// connectOrphans links every orphan that descends from the given hash
+// and attaches checkpoint blocks that were waiting for it
+func (c *Chain) connectOrphans(hash string) {
+	queue := []string{hash}
+	for len(queue) > 0 {
+		parentHash := queue[0]
+		queue = queue[1:]
+
+		parent := c.nodes[parentHash]
+		for _, root := range c.roots[parentHash] {
+			if root.block.ID == parent.block.ID+1 {
+				c.reparent(root, parent)
+			}
+		}
+		delete(c.roots, parentHash)
+
+		waiting := c.orphans[parentHash]
+		delete(c.orphans, parentHash)
+		c.orphanCount -= len(waiting)
+
+		for _, orphan := range waiting {
+			if orphan.ID != parent.block.ID+1 {
+				continue
+			}
+			orphanHash, err := orphan.Hash()
+			if err != nil {
+				continue
+			}
+			if _, exists := c.nodes[orphanHash]; exists {
+				continue
+			}
+			c.link(orphanHash, orphan, parent)
+			queue = append(queue, orphanHash)
+		}
+	}
+	c.compactOrphanOrder()
+}
+
+// updateBest selects the tip with the most cumulative work and
+// returns the blocks that were applied to and reverted from the best chain
+func (c *Chain) updateBest(hash string) Result {
+	candidate := c.best
+	for _, tip := range c.tips {
+		if candidate == nil || tip.work.Cmp(candidate.work) > 0 {
+			candidate = tip
+		}
+	}
+
+	if candidate == c.best {
+		return Result{Status: SideBranch, Hash: hash}
+	}
+
+	oldBest := c.best
+	c.best = candidate
+
+	fork := commonAncestor(oldBest, candidate)
+
+	var reverted []*block.Block
+	for n := oldBest; n != nil && n != fork; n = n.parent {
+		reverted = append(reverted, n.block)
+	}
+
+	var applied []*block.Block
+	for n := candidate; n != nil && n != fork; n = n.parent {
+		applied = append(applied, n.block)
+	}
+	reverse(applied)
+
+	status := Extended
+	if len(reverted) > 0 {
+		status = Reorganized
+	}
+
+	return Result{
+		Status:   status,
+		Hash:     hash,
+		Applied:  applied,
+		Reverted: reverted,
+	}
+}
+
+// Tip returns the head of the best chain
+func (c *Chain) Tip() (TipInfo, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	if c.best == nil {
+		return TipInfo{}, false
+	}
+	return c.tipInfo(c.best), true
+}
+
+// Forks returns the heads of all known branches, best first
+func (c *Chain) Forks() []TipInfo {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	forks := make([]TipInfo, 0, len(c.tips))
+	for _, tip := range c.tips {
+		forks = append(forks, c.tipInfo(tip))
+	}
+
+	sort.Slice(forks, func(i, j int) bool {
+		if forks[i].Best != forks[j].Best {
+			return forks[i].Best
+		}
+		return forks[i].Height > forks[j].Height
+	})
+	return forks
+}
+
+func (c *Chain) tipInfo(tip *node) TipInfo {
+	forkHeight := -1
+	if fork := commonAncestor(tip, c.best); fork != nil {
+		forkHeight = fork.block.ID
+	}
+
+	return TipInfo{
+		Hash:       tip.hash,
+		Height:     tip.block.ID,
+		Work:       tip.work.String(),
+		Best:       tip == c.best,
+		ForkHeight: forkHeight,
+	}
+}
+
+// Has reports whether the block is linked into the tree
+func (c *Chain) Has(hash string) bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	_, exists := c.nodes[hash]
+	return exists
+}
+
+// Range returns up to limit blocks of the best chain starting at fromHeight.
+// When afterHash is on the best chain the range starts right after that block instead.
+func (c *Chain) Range(fromHeight int, afterHash string, limit int) []*block.Block {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	if c.best == nil || limit <= 0 {
+		return nil
+	}
+
+	if after, exists := c.nodes[afterHash]; exists && commonAncestor(after, c.best) == after {
+		fromHeight = after.block.ID + 1
+	}
+
+	var blocks []*block.Block
+	for n := c.best; n != nil && n.block.ID >= fromHeight; n = n.parent {
+		blocks = append(blocks, n.block)
+	}
+	reverse(blocks)
+
+	if len(blocks) > limit {
+		blocks = blocks[:limit]
+	}
+	return blocks
+}
+
+// OrphanCount returns the number of blocks waiting for their parent
+func (c *Chain) OrphanCount() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.orphanCount
+}
+
+// commonAncestor returns the last block shared by both branches
+func commonAncestor(a, b *node) *node {
+	for a != nil && b != nil && a != b {
+		if a.block.ID >= b.block.ID {
+			a = a.parent
+		} else {
+			b = b.parent
+		}
+	}
+
+	if a == nil || b == nil {
+		return nil
+	}
+	return a
+}
+
+func reverse(blocks []*block.Block) {
+	for i, j := 0, len(blocks)-1; i < j; i, j = i+1, j-1 {
+		blocks[i], blocks[j] = blocks[j], blocks[i]
+	}
+}
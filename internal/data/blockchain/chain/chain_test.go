@@ -0,0 +1,264 @@
+package chain_test
+
+import (
+	"errors"
+	"sender/internal/data/blockchain/block"
+	"sender/internal/data/blockchain/chain"
+	"testing"
+	"time"
+)
+
+// newBlock создает блок, хеш которого начинается ровно с zeros нулей
+func newBlock(t *testing.T, id int, previousHash string, zeros int) (*block.Block, string) {
+	t.Helper()
+	return mineBlock(t, &block.Block{ID: id, TimeCreated: block.NewTimestamp(time.Unix(1745089962, 0)), PreviousHash: previousHash}, zeros)
+}
+
+func mineBlock(t *testing.T, b *block.Block, zeros int) (*block.Block, string) {
+	t.Helper()
+	for nonce := uint64(0); nonce < 1_000_000; nonce++ {
+		b.Nonce = nonce
+		hash, err := b.Hash()
+		if err != nil {
+			t.Fatalf("Hash failed: %v", err)
+		}
+		if block.LeadingZeros(hash) == zeros {
+			return b, hash
+		}
+	}
+	t.Fatal("Failed to mine block")
+	return nil, ""
+}
+
+func mustAdd(t *testing.T, c *chain.Chain, b *block.Block) chain.Result {
+	t.Helper()
+	result, err := c.Add(b)
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	return result
+}
+
+func TestAddExtendsChain(t *testing.T) {
+	c := chain.New()
+	genesis, genesisHash := newBlock(t, 1, "", 1)
+	second, secondHash := newBlock(t, 2, genesisHash, 1)
+
+	if result := mustAdd(t, c, genesis); result.Status != chain.Extended || len(result.Applied) != 1 {
+		t.Fatalf("Expected genesis to extend chain, got %v", result.Status)
+	}
+	if result := mustAdd(t, c, second); result.Status != chain.Extended || result.Applied[0] != second {
+		t.Fatalf("Expected block to extend chain, got %v", result.Status)
+	}
+
+	tip, ok := c.Tip()
+	if !ok || tip.Hash != secondHash || tip.Height != 2 {
+		t.Errorf("Unexpected tip: %+v", tip)
+	}
+	if result := mustAdd(t, c, second); result.Status != chain.Duplicate {
+		t.Errorf("Expected duplicate, got %v", result.Status)
+	}
+}
+
+func TestOrphanIsConnectedWhenParentArrives(t *testing.T) {
+	c := chain.New()
+	genesis, genesisHash := newBlock(t, 1, "", 1)
+	second, secondHash := newBlock(t, 2, genesisHash, 1)
+	third, thirdHash := newBlock(t, 3, secondHash, 1)
+
+	mustAdd(t, c, genesis)
+	if result := mustAdd(t, c, third); result.Status != chain.Orphaned {
+		t.Fatalf("Expected orphan, got %v", result.Status)
+	}
+	if c.OrphanCount() != 1 {
+		t.Fatalf("Expected 1 orphan, got %d", c.OrphanCount())
+	}
+
+	result := mustAdd(t, c, second)
+	if result.Status != chain.Extended {
+		t.Fatalf("Expected extended, got %v", result.Status)
+	}
+	if len(result.Applied) != 2 || result.Applied[0] != second || result.Applied[1] != third {
+		t.Errorf("Expected parent and orphan to be applied in order, got %v", result.Applied)
+	}
+	if tip, _ := c.Tip(); tip.Hash != thirdHash {
+		t.Errorf("Expected tip %s, got %s", thirdHash, tip.Hash)
+	}
+	if c.OrphanCount() != 0 {
+		t.Errorf("Expected orphan pool to be empty, got %d", c.OrphanCount())
+	}
+}
+
+func TestFirstBlockIsNotRootWithoutGenesis(t *testing.T) {
+	c := chain.New()
+	genesis, genesisHash := newBlock(t, 1, "", 1)
+	second, secondHash := newBlock(t, 2, genesisHash, 1)
+	third, thirdHash := newBlock(t, 3, secondHash, 1)
+	fourth, fourthHash := newBlock(t, 4, thirdHash, 1)
+
+	if result := mustAdd(t, c, third); result.Status != chain.Orphaned {
+		t.Fatalf("Expected block without genesis to be orphaned, got %v", result.Status)
+	}
+	mustAdd(t, c, genesis)
+	mustAdd(t, c, second)
+	if result := mustAdd(t, c, third); result.Status != chain.Duplicate {
+		t.Fatalf("Expected duplicate, got %v", result.Status)
+	}
+	if result := mustAdd(t, c, fourth); result.Status != chain.Extended {
+		t.Fatalf("Expected extended, got %v", result.Status)
+	}
+
+	tip, _ := c.Tip()
+	if tip.Hash != fourthHash || tip.Height != 4 {
+		t.Errorf("Expected tip at height 4, got %+v", tip)
+	}
+	if forks := c.Forks(); len(forks) != 1 || forks[0].ForkHeight != 4 {
+		t.Errorf("Expected a single branch, got %+v", forks)
+	}
+}
+
+func TestCheckpointIsReparentedWhenAncestorsArrive(t *testing.T) {
+	c := chain.New()
+	genesis, genesisHash := newBlock(t, 1, "", 1)
+	second, secondHash := newBlock(t, 2, genesisHash, 1)
+	third, thirdHash := newBlock(t, 3, secondHash, 1)
+	fourth, fourthHash := newBlock(t, 4, thirdHash, 1)
+	c.SetCheckpoints([]string{thirdHash})
+
+	if result := mustAdd(t, c, third); result.Status != chain.Extended {
+		t.Fatalf("Expected checkpoint to start the tree, got %v", result.Status)
+	}
+	mustAdd(t, c, fourth)
+	mustAdd(t, c, genesis)
+	mustAdd(t, c, second)
+
+	tip, _ := c.Tip()
+	if tip.Hash != fourthHash {
+		t.Fatalf("Expected tip %s, got %s", fourthHash, tip.Hash)
+	}
+	if forks := c.Forks(); len(forks) != 1 || forks[0].Work != "64" {
+		t.Errorf("Expected a single branch with the work of 4 blocks, got %+v", forks)
+	}
+	if blocks := c.Range(0, "", 10); len(blocks) != 4 || blocks[0] != genesis {
+		t.Errorf("Expected the whole chain from genesis, got %v", blocks)
+	}
+}
+
+func TestReorganizationToMostWork(t *testing.T) {
+	c := chain.New()
+	genesis, genesisHash := newBlock(t, 1, "", 1)
+	mainBlock, _ := newBlock(t, 2, genesisHash, 1)
+	forkBlock, forkHash := mineBlock(t, &block.Block{ID: 2, TimeCreated: block.NewTimestamp(time.Unix(1745090000, 0)), PreviousHash: genesisHash}, 1)
+	forkNext, forkNextHash := newBlock(t, 3, forkHash, 1)
+
+	mustAdd(t, c, genesis)
+	mustAdd(t, c, mainBlock)
+
+	// равная работа не меняет лучшую цепочку
+	if result := mustAdd(t, c, forkBlock); result.Status != chain.SideBranch {
+		t.Fatalf("Expected side branch, got %v", result.Status)
+	}
+	if forks := c.Forks(); len(forks) != 2 || forks[1].ForkHeight != 1 {
+		t.Fatalf("Expected 2 forks diverging at 1, got %+v", forks)
+	}
+
+	result := mustAdd(t, c, forkNext)
+	if result.Status != chain.Reorganized {
+		t.Fatalf("Expected reorganization, got %v", result.Status)
+	}
+	if len(result.Reverted) != 1 || result.Reverted[0] != mainBlock {
+		t.Errorf("Expected main block to be reverted, got %v", result.Reverted)
+	}
+	if len(result.Applied) != 2 || result.Applied[0] != forkBlock || result.Applied[1] != forkNext {
+		t.Errorf("Expected fork blocks to be applied, got %v", result.Applied)
+	}
+	if tip, _ := c.Tip(); tip.Hash != forkNextHash {
+		t.Errorf("Expected tip %s, got %s", forkNextHash, tip.Hash)
+	}
+}
+
+func TestMoreWorkWinsOverLength(t *testing.T) {
+	c := chain.New()
+	genesis, genesisHash := newBlock(t, 1, "", 1)
+	second, secondHash := newBlock(t, 2, genesisHash, 1)
+	third, _ := newBlock(t, 3, secondHash, 1)
+	heavy, heavyHash := newBlock(t, 2, genesisHash, 3)
+
+	mustAdd(t, c, genesis)
+	mustAdd(t, c, second)
+	mustAdd(t, c, third)
+
+	result := mustAdd(t, c, heavy)
+	if result.Status != chain.Reorganized || len(result.Reverted) != 2 {
+		t.Fatalf("Expected heavier branch to win, got %v reverted %d", result.Status, len(result.Reverted))
+	}
+	if tip, _ := c.Tip(); tip.Hash != heavyHash {
+		t.Errorf("Expected tip %s, got %s", heavyHash, tip.Hash)
+	}
+}
+
+func TestInvalidHeightIsRejected(t *testing.T) {
+	c := chain.New()
+	genesis, genesisHash := newBlock(t, 1, "", 1)
+	wrong, _ := newBlock(t, 5, genesisHash, 1)
+
+	mustAdd(t, c, genesis)
+	if _, err := c.Add(wrong); !errors.Is(err, chain.ErrInvalidHeight) {
+		t.Errorf("Expected ErrInvalidHeight, got %v", err)
+	}
+}
+
+func TestOrphanPoolEvictsOldest(t *testing.T) {
+	c := chain.New()
+	c.SetMaxOrphans(1)
+	genesis, genesisHash := newBlock(t, 1, "", 1)
+	second, secondHash := newBlock(t, 2, genesisHash, 1)
+	third, thirdHash := newBlock(t, 3, secondHash, 1)
+	stale, _ := newBlock(t, 3, "aa", 1)
+
+	mustAdd(t, c, genesis)
+	mustAdd(t, c, stale)
+	if result := mustAdd(t, c, third); result.Status != chain.Orphaned {
+		t.Fatalf("Expected orphan to be accepted into the full pool, got %v", result.Status)
+	}
+	if c.OrphanCount() != 1 {
+		t.Fatalf("Expected 1 orphan, got %d", c.OrphanCount())
+	}
+
+	mustAdd(t, c, second)
+	if tip, _ := c.Tip(); tip.Hash != thirdHash {
+		t.Errorf("Expected the newest orphan to be kept and connected, got tip %s", tip.Hash)
+	}
+	if c.OrphanCount() != 0 {
+		t.Errorf("Expected the oldest orphan to be evicted, got %d orphans", c.OrphanCount())
+	}
+}
+
+func TestRange(t *testing.T) {
+	c := chain.New()
+	genesis, genesisHash := newBlock(t, 1, "", 1)
+	second, secondHash := newBlock(t, 2, genesisHash, 1)
+	third, _ := newBlock(t, 3, secondHash, 1)
+	mustAdd(t, c, genesis)
+	mustAdd(t, c, second)
+	mustAdd(t, c, third)
+
+	blocks := c.Range(0, "", 10)
+	if len(blocks) != 3 || blocks[0] != genesis || blocks[2] != third {
+		t.Fatalf("Expected whole chain, got %v", blocks)
+	}
+
+	blocks = c.Range(2, "", 1)
+	if len(blocks) != 1 || blocks[0] != second {
+		t.Fatalf("Expected second block only, got %v", blocks)
+	}
+
+	blocks = c.Range(0, genesisHash, 10)
+	if len(blocks) != 2 || blocks[0] != second {
+		t.Fatalf("Expected blocks after genesis, got %v", blocks)
+	}
+
+	if blocks := c.Range(4, "", 10); len(blocks) != 0 {
+		t.Errorf("Expected no blocks above tip, got %v", blocks)
+	}
+}
@@ -0,0 +1,293 @@
+package chain
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sender/internal/data/blockchain/block"
+	"sync"
+)
+
+// DefaultConfirmationDepth is the number of blocks (including the block itself)
+// that must be on the best chain before its transactions are reported as confirmed
+const DefaultConfirmationDepth = 3
+
+// announcedWindow is how many blocks below the tip the confirmer remembers.
+// Reorganizations deeper than this are not reported.
+const announcedWindow = 1000
+
+// ConfirmedBlock is a block together with its number of confirmations
+type ConfirmedBlock struct {
+	Hash          string
+	Block         *block.Block
+	Confirmations int
+}
+
+// Confirmer reports blocks once they reach the confirmation depth and
+// reports them again when they drop out of the best chain
+type Confirmer struct {
+	mutex sync.Mutex
+	chain *Chain
+	depth int
+
+	// hash -> height of blocks already reported as confirmed
+	announced map[string]int
+	// blocks at or below this height are final and never revisited
+	finalHeight int
+
+	// File the last announced block is persisted to, empty for an in-memory confirmer
+	path string
+	// last announced block loaded from path, nil if nothing was saved yet
+	saved *announcement
+}
+
+// announcement is the highest block whose events were published
+type announcement struct {
+	Hash   string `json:"hash"`
+	Height int    `json:"height"`
+}
+
+// NewConfirmer creates a confirmer for the given chain
+func NewConfirmer(c *Chain, depth int) *Confirmer {
+	if depth < 1 {
+		depth = 1
+	}
+
+	return &Confirmer{
+		chain:       c,
+		depth:       depth,
+		announced:   make(map[string]int),
+		finalHeight: -1,
+	}
+}
+
+// OpenConfirmer creates a confirmer that remembers the last announced block in the given file
+func OpenConfirmer(c *Chain, depth int, path string) (*Confirmer, error) {
+	cf := NewConfirmer(c, depth)
+	cf.path = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cf, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var saved announcement
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, err
+	}
+	cf.saved = &saved
+	return cf, nil
+}
+
+// Depth returns the configured confirmation depth
+func (cf *Confirmer) Depth() int {
+	return cf.depth
+}
+
+// Prime marks blocks that were reported before the restart.
+// It is used after the chain is restored from disk so nothing is reported twice.
+// With a saved announcement only the best chain up to that block is marked and
+// deeper blocks above it are returned by Pending, without one every block that
+// is already deep enough is marked.
+func (cf *Confirmer) Prime() {
+	cf.mutex.Lock()
+	defer cf.mutex.Unlock()
+
+	height := cf.savedHeight()
+	for _, confirmed := range cf.collectConfirmed() {
+		if height >= 0 && confirmed.Block.ID > height {
+			break
+		}
+		cf.announced[confirmed.Hash] = confirmed.Block.ID
+	}
+	cf.prune()
+}
+
+// savedHeight returns the height of the best chain up to which blocks were announced,
+// -1 when there is no saved announcement
+func (cf *Confirmer) savedHeight() int {
+	if cf.saved == nil {
+		return -1
+	}
+
+	cf.chain.mutex.RLock()
+	defer cf.chain.mutex.RUnlock()
+
+	saved, exists := cf.chain.nodes[cf.saved.Hash]
+	if !exists {
+		return cf.saved.Height
+	}
+	// the announced block may have left the best chain before the restart
+	if fork := commonAncestor(saved, cf.chain.best); fork != nil {
+		return fork.block.ID
+	}
+	return 0
+}
+
+// Pending returns not yet reported blocks that are deep enough and marks them as reported
+func (cf *Confirmer) Pending() []ConfirmedBlock {
+	cf.mutex.Lock()
+	defer cf.mutex.Unlock()
+
+	return cf.announce()
+}
+
+// Forget marks the blocks as not reported, they are returned again once the chain grows
+func (cf *Confirmer) Forget(blocks []ConfirmedBlock) {
+	cf.mutex.Lock()
+	defer cf.mutex.Unlock()
+
+	for _, confirmed := range blocks {
+		delete(cf.announced, confirmed.Hash)
+	}
+}
+
+// Save persists the highest reported block, it is a no-op for in-memory confirmers.
+// It must be called after the events of the reported blocks are stored.
+func (cf *Confirmer) Save() error {
+	if cf.path == "" {
+		return nil
+	}
+
+	cf.mutex.Lock()
+	defer cf.mutex.Unlock()
+
+	last := announcement{Height: -1}
+	for hash, height := range cf.announced {
+		if height > last.Height {
+			last = announcement{Hash: hash, Height: height}
+		}
+	}
+	if last.Height < 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(last)
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(cf.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+
+	tmpPath := cf.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, cf.path); err != nil {
+		return err
+	}
+
+	cf.saved = &last
+	return nil
+}
+
+// Process takes the result of Chain.Add and returns the blocks that became
+// confirmed and the previously confirmed blocks that left the best chain
+func (cf *Confirmer) Process(result Result) (confirmed []ConfirmedBlock, reverted []ConfirmedBlock) {
+	cf.mutex.Lock()
+	defer cf.mutex.Unlock()
+
+	for _, revertedBlock := range result.Reverted {
+		hash, err := revertedBlock.Hash()
+		if err != nil {
+			continue
+		}
+		if _, exists := cf.announced[hash]; !exists {
+			continue
+		}
+
+		delete(cf.announced, hash)
+		reverted = append(reverted, ConfirmedBlock{Hash: hash, Block: revertedBlock})
+	}
+
+	if len(result.Applied) == 0 {
+		return nil, reverted
+	}
+
+	return cf.announce(), reverted
+}
+
+// announce collects the blocks that became confirmed and marks them as reported
+func (cf *Confirmer) announce() []ConfirmedBlock {
+	confirmed := cf.collectConfirmed()
+	for _, confirmedBlock := range confirmed {
+		cf.announced[confirmedBlock.Hash] = confirmedBlock.Block.ID
+	}
+	cf.prune()
+
+	return confirmed
+}
+
+// collectConfirmed walks the best chain down from the tip and returns
+// not yet reported blocks with enough confirmations, parent first
+func (cf *Confirmer) collectConfirmed() []ConfirmedBlock {
+	cf.chain.mutex.RLock()
+	defer cf.chain.mutex.RUnlock()
+
+	tip := cf.chain.best
+	if tip == nil {
+		return nil
+	}
+
+	var confirmed []ConfirmedBlock
+	for n := tip; n != nil && n.block.ID > cf.finalHeight; n = n.parent {
+		if _, exists := cf.announced[n.hash]; exists {
+			break
+		}
+
+		confirmations := tip.block.ID - n.block.ID + 1
+		if confirmations < cf.depth {
+			continue
+		}
+
+		confirmed = append(confirmed, ConfirmedBlock{
+			Hash:          n.hash,
+			Block:         n.block,
+			Confirmations: confirmations,
+		})
+	}
+
+	for i, j := 0, len(confirmed)-1; i < j; i, j = i+1, j-1 {
+		confirmed[i], confirmed[j] = confirmed[j], confirmed[i]
+	}
+	return confirmed
+}
+
+// prune forgets reported blocks far below the tip
+func (cf *Confirmer) prune() {
+	tip, ok := cf.chain.Tip()
+	if !ok {
+		return
+	}
+
+	limit := tip.Height - announcedWindow
+	if limit <= cf.finalHeight {
+		return
+	}
+
+	for hash, height := range cf.announced {
+		if height <= limit {
+			delete(cf.announced, hash)
+		}
+	}
+	cf.finalHeight = limit
+}
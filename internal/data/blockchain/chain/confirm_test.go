@@ -0,0 +1,136 @@
+package chain_test
+
+import (
+	"path/filepath"
+	"sender/internal/data/blockchain/block"
+	"sender/internal/data/blockchain/chain"
+	"testing"
+)
+
+func TestConfirmerReportsAtDepth(t *testing.T) {
+	c := chain.New()
+	confirmer := chain.NewConfirmer(c, 2)
+
+	genesis, genesisHash := newBlock(t, 1, "", 1)
+	second, secondHash := newBlock(t, 2, genesisHash, 1)
+	third, _ := newBlock(t, 3, secondHash, 1)
+
+	confirmed, _ := confirmer.Process(mustAdd(t, c, genesis))
+	if len(confirmed) != 0 {
+		t.Fatalf("Expected nothing confirmed at depth 1, got %d", len(confirmed))
+	}
+
+	confirmed, _ = confirmer.Process(mustAdd(t, c, second))
+	if len(confirmed) != 1 || confirmed[0].Hash != genesisHash || confirmed[0].Confirmations != 2 {
+		t.Fatalf("Expected genesis to be confirmed, got %+v", confirmed)
+	}
+
+	confirmed, _ = confirmer.Process(mustAdd(t, c, third))
+	if len(confirmed) != 1 || confirmed[0].Hash != secondHash {
+		t.Fatalf("Expected second block to be confirmed, got %+v", confirmed)
+	}
+}
+
+func TestConfirmerReportsRevertedBlocks(t *testing.T) {
+	c := chain.New()
+	confirmer := chain.NewConfirmer(c, 2)
+
+	genesis, genesisHash := newBlock(t, 1, "", 1)
+	second, secondHash := newBlock(t, 2, genesisHash, 1)
+	third, _ := newBlock(t, 3, secondHash, 1)
+	heavy, heavyHash := newBlock(t, 2, genesisHash, 4)
+	heavyNext, _ := newBlock(t, 3, heavyHash, 1)
+
+	confirmer.Process(mustAdd(t, c, genesis))
+	confirmer.Process(mustAdd(t, c, second))
+	confirmer.Process(mustAdd(t, c, third))
+
+	confirmed, reverted := confirmer.Process(mustAdd(t, c, heavy))
+	if len(reverted) != 1 || reverted[0].Hash != secondHash {
+		t.Fatalf("Expected confirmed second block to be reverted, got %+v", reverted)
+	}
+	if len(confirmed) != 0 {
+		t.Fatalf("Expected nothing new confirmed, got %+v", confirmed)
+	}
+
+	confirmed, _ = confirmer.Process(mustAdd(t, c, heavyNext))
+	if len(confirmed) != 1 || confirmed[0].Hash != heavyHash {
+		t.Fatalf("Expected heavy block to be confirmed, got %+v", confirmed)
+	}
+}
+
+func TestConfirmerForgetReportsAgain(t *testing.T) {
+	c := chain.New()
+	confirmer := chain.NewConfirmer(c, 2)
+
+	genesis, genesisHash := newBlock(t, 1, "", 1)
+	second, secondHash := newBlock(t, 2, genesisHash, 1)
+	third, _ := newBlock(t, 3, secondHash, 1)
+
+	confirmer.Process(mustAdd(t, c, genesis))
+	confirmed, _ := confirmer.Process(mustAdd(t, c, second))
+	// события блока не удалось сохранить
+	confirmer.Forget(confirmed)
+
+	confirmed, _ = confirmer.Process(mustAdd(t, c, third))
+	if len(confirmed) != 2 || confirmed[0].Hash != genesisHash || confirmed[1].Hash != secondHash {
+		t.Fatalf("Expected forgotten block to be reported again, got %+v", confirmed)
+	}
+}
+
+func TestConfirmerPrimeSkipsRestoredBlocks(t *testing.T) {
+	c := chain.New()
+	genesis, genesisHash := newBlock(t, 1, "", 1)
+	second, secondHash := newBlock(t, 2, genesisHash, 1)
+	third, _ := newBlock(t, 3, secondHash, 1)
+	mustAdd(t, c, genesis)
+	mustAdd(t, c, second)
+
+	confirmer := chain.NewConfirmer(c, 2)
+	confirmer.Prime()
+
+	confirmed, _ := confirmer.Process(mustAdd(t, c, third))
+	if len(confirmed) != 1 || confirmed[0].Hash != secondHash {
+		t.Fatalf("Expected only the new confirmation after prime, got %+v", confirmed)
+	}
+}
+
+func TestConfirmerPrimeFromSavedAnnouncement(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "confirmed.json")
+	genesis, genesisHash := newBlock(t, 1, "", 1)
+	second, secondHash := newBlock(t, 2, genesisHash, 1)
+	third, thirdHash := newBlock(t, 3, secondHash, 1)
+	fourth, _ := newBlock(t, 4, thirdHash, 1)
+
+	c := chain.New()
+	confirmer, err := chain.OpenConfirmer(c, 2, path)
+	if err != nil {
+		t.Fatalf("OpenConfirmer failed: %v", err)
+	}
+	confirmer.Process(mustAdd(t, c, genesis))
+	confirmer.Process(mustAdd(t, c, second))
+	if err := confirmer.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	// узел упал после записи блоков, но до публикации их событий
+	confirmer.Process(mustAdd(t, c, third))
+	confirmer.Process(mustAdd(t, c, fourth))
+
+	restored := chain.New()
+	for _, b := range []*block.Block{genesis, second, third, fourth} {
+		mustAdd(t, restored, b)
+	}
+	confirmer, err = chain.OpenConfirmer(restored, 2, path)
+	if err != nil {
+		t.Fatalf("OpenConfirmer failed: %v", err)
+	}
+	confirmer.Prime()
+
+	pending := confirmer.Pending()
+	if len(pending) != 2 || pending[0].Hash != secondHash || pending[1].Hash != thirdHash {
+		t.Fatalf("Expected blocks above the saved announcement, got %+v", pending)
+	}
+	if pending := confirmer.Pending(); len(pending) != 0 {
+		t.Errorf("Expected pending blocks to be reported once, got %+v", pending)
+	}
+}
@@ -0,0 +1,322 @@
+package mempool
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sender/internal/data/blockchain/block"
+	"sender/internal/data/blockchain/replay"
+	"sender/internal/data/blockchain/transaction"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultRebroadcastInterval = 30 * time.Second
+	DefaultMaxAge              = 30 * time.Minute
+)
+
+// Entry is a pending transaction waiting to be included in a block
+type Entry struct {
+	ID            string                   `json:"id"`
+	Transaction   *transaction.Transaction `json:"transaction"`
+	AddedAt       time.Time                `json:"added_at"`
+	LastBroadcast time.Time                `json:"last_broadcast"`
+	Broadcasts    int                      `json:"broadcasts"`
+}
+
+// Mempool keeps pending transactions keyed by transaction ID
+type Mempool struct {
+	mutex   sync.Mutex
+	entries map[string]*Entry
+
+	// Transactions already included in blocks, replays of them are rejected
+	included *replay.Guard
+
+	rebroadcastInterval time.Duration
+	maxAge              time.Duration
+
+	// File the pool is persisted to, empty for an in-memory pool
+	path string
+}
+
+// New creates an in-memory mempool
+func New(rebroadcastInterval, maxAge time.Duration) *Mempool {
+	return &Mempool{
+		entries:             make(map[string]*Entry),
+		included:            replay.New(maxAge),
+		rebroadcastInterval: rebroadcastInterval,
+		maxAge:              maxAge,
+	}
+}
+
+// Open creates a mempool persisted to the given file and loads its entries
+func Open(path string, rebroadcastInterval, maxAge time.Duration) (*Mempool, error) {
+	m := New(rebroadcastInterval, maxAge)
+	m.path = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*Entry
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, entry := range entries {
+		if entry.Transaction == nil {
+			continue
+		}
+		// файлы старого формата хранили хеш вместо ID
+		if entry.ID, err = entry.Transaction.ID(); err != nil {
+			continue
+		}
+		m.entries[entry.ID] = entry
+	}
+	return m, nil
+}
+
+// Persistent reports whether added transactions survive a restart
+func (m *Mempool) Persistent() bool {
+	return m.path != ""
+}
+
+// Add puts the transaction into the pool. It returns false if the
+// transaction is already pending and an error if it replays a transaction
+// already included in a block, has a stale nonce or is too old.
+func (m *Mempool) Add(tx *transaction.Transaction) (string, bool, error) {
+	id, err := tx.ID()
+	if err != nil {
+		return "", false, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, exists := m.entries[id]; exists {
+		return id, false, nil
+	}
+	if _, err := m.included.Check(tx, time.Now()); err != nil {
+		return id, false, err
+	}
+
+	return id, true, m.insert(id, tx)
+}
+
+// Restore puts a transaction of a block that left the best chain back into
+// the pool, bypassing the replay checks
+func (m *Mempool) Restore(tx *transaction.Transaction) (string, error) {
+	id, err := tx.ID()
+	if err != nil {
+		return "", err
+	}
+
+	m.included.Forget(tx)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, exists := m.entries[id]; exists {
+		return id, nil
+	}
+	return id, m.insert(id, tx)
+}
+
+// insert adds the entry and persists the pool, the entry is dropped again if it could not be saved
+func (m *Mempool) insert(id string, tx *transaction.Transaction) error {
+	m.entries[id] = &Entry{
+		ID:          id,
+		Transaction: tx,
+		AddedAt:     time.Now(),
+	}
+	if err := m.save(); err != nil {
+		delete(m.entries, id)
+		return err
+	}
+	return nil
+}
+
+// MarkBroadcast records that the transaction was sent to peers
+func (m *Mempool) MarkBroadcast(id string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if entry, exists := m.entries[id]; exists {
+		entry.LastBroadcast = time.Now()
+		entry.Broadcasts++
+		m.save()
+	}
+}
+
+// Has reports whether the transaction is pending
+func (m *Mempool) Has(id string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	_, exists := m.entries[id]
+	return exists
+}
+
+// Remove drops the transaction from the pool
+func (m *Mempool) Remove(id string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, exists := m.entries[id]; !exists {
+		return false
+	}
+	delete(m.entries, id)
+	m.save()
+	return true
+}
+
+// PruneBlock removes every transaction included in the block, remembers
+// them to reject replays and returns the IDs of the removed transactions
+func (m *Mempool) PruneBlock(b *block.Block) []string {
+	now := time.Now()
+	for i := range b.Transactions {
+		m.included.Record(&b.Transactions[i], now)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var pruned []string
+	for i := range b.Transactions {
+		id, err := b.Transactions[i].ID()
+		if err != nil {
+			continue
+		}
+		if _, exists := m.entries[id]; exists {
+			delete(m.entries, id)
+			pruned = append(pruned, id)
+		}
+	}
+
+	if len(pruned) > 0 {
+		m.save()
+	}
+	return pruned
+}
+
+// DueForRebroadcast returns the transactions not broadcast within the
+// rebroadcast interval and marks them as broadcast
+func (m *Mempool) DueForRebroadcast(now time.Time) []*transaction.Transaction {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var due []*transaction.Transaction
+	for _, entry := range m.sortedEntries() {
+		if now.Sub(entry.LastBroadcast) < m.rebroadcastInterval {
+			continue
+		}
+		entry.LastBroadcast = now
+		entry.Broadcasts++
+		due = append(due, entry.Transaction)
+	}
+
+	if len(due) > 0 {
+		m.save()
+	}
+	return due
+}
+
+// Expire removes and returns the entries older than the maximum age
+func (m *Mempool) Expire(now time.Time) []*Entry {
+	m.included.Prune(now)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var expired []*Entry
+	for _, entry := range m.sortedEntries() {
+		if now.Sub(entry.AddedAt) < m.maxAge {
+			continue
+		}
+		delete(m.entries, entry.ID)
+		expired = append(expired, entry)
+	}
+
+	if len(expired) > 0 {
+		m.save()
+	}
+	return expired
+}
+
+// Entries returns a snapshot of the pending entries, oldest first
+func (m *Mempool) Entries() []Entry {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	entries := make([]Entry, 0, len(m.entries))
+	for _, entry := range m.sortedEntries() {
+		entries = append(entries, *entry)
+	}
+	return entries
+}
+
+// Len returns the number of pending transactions
+func (m *Mempool) Len() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return len(m.entries)
+}
+
+func (m *Mempool) sortedEntries() []*Entry {
+	entries := make([]*Entry, 0, len(m.entries))
+	for _, entry := range m.entries {
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].AddedAt.Before(entries[j].AddedAt)
+	})
+	return entries
+}
+
+// save writes the pool to disk atomically, it is a no-op for in-memory pools
+func (m *Mempool) save() error {
+	if m.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(m.sortedEntries())
+	if err != nil {
+		return err
+	}
+
+	if dir := filepath.Dir(m.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+
+	// the pool is synced before the rename, an added transaction is durable once save returns
+	tmpPath := m.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, m.path)
+}
@@ -0,0 +1,150 @@
+package mempool_test
+
+import (
+	"errors"
+	"path/filepath"
+	"sender/internal/data/blockchain/block"
+	"sender/internal/data/blockchain/mempool"
+	"sender/internal/data/blockchain/replay"
+	"sender/internal/data/blockchain/transaction"
+	"testing"
+	"time"
+)
+
+func newTestTransaction(signature string) *transaction.Transaction {
+	return &transaction.Transaction{
+		Sender:      "test_sender",
+		DealMessage: `{"id":"` + signature + `"}`,
+		Signature:   signature,
+	}
+}
+
+// newSignedTransaction creates a version 2 transaction with the given nonce and creation time
+func newSignedTransaction(nonce uint64, created time.Time) *transaction.Transaction {
+	return &transaction.Transaction{
+		Version:     transaction.PayloadV2,
+		Sender:      "test_sender",
+		DealMessage: `{"id":1}`,
+		Nonce:       nonce,
+		CreatedAt:   created.UnixMilli(),
+		Signature:   "sig",
+	}
+}
+
+func TestAddDeduplicates(t *testing.T) {
+	pool := mempool.New(time.Minute, time.Hour)
+
+	hash, added, err := pool.Add(newTestTransaction("sig-1"))
+	if err != nil || !added {
+		t.Fatalf("Expected transaction to be added, got %v, %v", added, err)
+	}
+
+	sameHash, added, _ := pool.Add(newTestTransaction("sig-1"))
+	if added || sameHash != hash {
+		t.Errorf("Expected duplicate to be ignored")
+	}
+	if pool.Len() != 1 {
+		t.Errorf("Expected 1 pending transaction, got %d", pool.Len())
+	}
+}
+
+func TestPruneBlock(t *testing.T) {
+	pool := mempool.New(time.Minute, time.Hour)
+	mined := newTestTransaction("sig-1")
+	pending := newTestTransaction("sig-2")
+	minedHash, _, _ := pool.Add(mined)
+	pool.Add(pending)
+
+	pruned := pool.PruneBlock(&block.Block{ID: 1, Transactions: []transaction.Transaction{*mined}})
+	if len(pruned) != 1 || pruned[0] != minedHash {
+		t.Fatalf("Expected mined transaction to be pruned, got %v", pruned)
+	}
+	if pool.Has(minedHash) || pool.Len() != 1 {
+		t.Errorf("Expected only pending transaction to remain")
+	}
+}
+
+func TestRebroadcastAndExpire(t *testing.T) {
+	pool := mempool.New(time.Minute, time.Hour)
+	hash, _, _ := pool.Add(newTestTransaction("sig-1"))
+	pool.MarkBroadcast(hash)
+
+	now := time.Now()
+	if due := pool.DueForRebroadcast(now); len(due) != 0 {
+		t.Fatalf("Expected nothing due right after broadcast, got %d", len(due))
+	}
+	if due := pool.DueForRebroadcast(now.Add(2 * time.Minute)); len(due) != 1 {
+		t.Fatalf("Expected transaction to be due, got %d", len(due))
+	}
+	if due := pool.DueForRebroadcast(now.Add(2 * time.Minute)); len(due) != 0 {
+		t.Fatalf("Expected rebroadcast to be recorded, got %d", len(due))
+	}
+
+	if expired := pool.Expire(now.Add(30 * time.Minute)); len(expired) != 0 {
+		t.Fatalf("Expected nothing expired, got %d", len(expired))
+	}
+	expired := pool.Expire(now.Add(2 * time.Hour))
+	if len(expired) != 1 || expired[0].ID != hash || expired[0].Broadcasts != 2 {
+		t.Fatalf("Expected transaction to expire, got %+v", expired)
+	}
+	if pool.Len() != 0 {
+		t.Errorf("Expected empty pool after expiry, got %d", pool.Len())
+	}
+}
+
+func TestOpenRestoresPersistedEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mempool.json")
+
+	pool, err := mempool.Open(path, time.Minute, time.Hour)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if !pool.Persistent() || mempool.New(time.Minute, time.Hour).Persistent() {
+		t.Error("Expected only the opened pool to be persistent")
+	}
+	hash, _, _ := pool.Add(newTestTransaction("sig-1"))
+	pool.Add(newTestTransaction("sig-2"))
+	pool.Remove(hash)
+
+	restored, err := mempool.Open(path, time.Minute, time.Hour)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	if restored.Len() != 1 || restored.Has(hash) {
+		t.Fatalf("Expected only the remaining transaction to be restored, got %d", restored.Len())
+	}
+	if entries := restored.Entries(); entries[0].Transaction.Signature != "sig-2" {
+		t.Errorf("Unexpected restored entry: %+v", entries[0])
+	}
+}
+
+func TestAddRejectsReplays(t *testing.T) {
+	pool := mempool.New(time.Minute, time.Hour)
+	now := time.Now()
+
+	mined := newSignedTransaction(10, now)
+	pool.Add(mined)
+	pool.PruneBlock(&block.Block{ID: 1, Transactions: []transaction.Transaction{*mined}})
+
+	// уже включенная в блок транзакция не возвращается в пул
+	if _, added, err := pool.Add(mined); added || !errors.Is(err, replay.ErrDuplicate) {
+		t.Errorf("Expected ErrDuplicate, got %v, %v", added, err)
+	}
+	if _, _, err := pool.Add(newSignedTransaction(10, now.Add(time.Second))); !errors.Is(err, replay.ErrStaleNonce) {
+		t.Errorf("Expected ErrStaleNonce, got %v", err)
+	}
+	if _, _, err := pool.Add(newSignedTransaction(11, now.Add(-2*time.Hour))); !errors.Is(err, replay.ErrExpired) {
+		t.Errorf("Expected ErrExpired, got %v", err)
+	}
+	if _, added, err := pool.Add(newSignedTransaction(11, now)); !added || err != nil {
+		t.Errorf("Expected next nonce to be accepted, got %v, %v", added, err)
+	}
+
+	// транзакции отмененного блока возвращаются без проверок
+	if _, err := pool.Restore(mined); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if pool.Len() != 2 {
+		t.Errorf("Expected 2 pending transactions, got %d", pool.Len())
+	}
+}
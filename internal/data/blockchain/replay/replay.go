@@ -0,0 +1,182 @@
+package replay
+
+import (
+	"errors"
+	"fmt"
+	"sender/internal/data/blockchain/transaction"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultWindow is how long a transaction is accepted after its creation
+	DefaultWindow = time.Hour
+	// maxFutureDrift tolerates clock differences between nodes
+	maxFutureDrift = 5 * time.Minute
+)
+
+var (
+	ErrDuplicate      = errors.New("transaction already seen")
+	ErrStaleNonce     = errors.New("transaction nonce is already used by the sender")
+	ErrExpired        = errors.New("transaction is older than the replay window")
+	ErrFromFuture     = errors.New("transaction is created in the future")
+	ErrNilTransaction = errors.New("transaction is nil")
+)
+
+// Guard remembers transaction IDs and the used nonces of every sender within
+// a time window. Transactions older than the window are rejected, so IDs and
+// nonces can be forgotten once the window has passed. Gossip does not keep
+// the order of transactions, so a lower nonce than the last one is accepted
+// as long as it was not used.
+type Guard struct {
+	mutex  sync.Mutex
+	window time.Duration
+
+	// transaction id -> time the id may be forgotten
+	seen map[string]time.Time
+	// sender -> used nonce -> time the nonce may be forgotten
+	nonces map[string]map[uint64]time.Time
+}
+
+// New creates a guard that accepts transactions created within the window
+func New(window time.Duration) *Guard {
+	return &Guard{
+		window: window,
+		seen:   make(map[string]time.Time),
+		nonces: make(map[string]map[uint64]time.Time),
+	}
+}
+
+// Check returns the transaction ID and an error if the transaction is a replay
+func (g *Guard) Check(tx *transaction.Transaction, now time.Time) (string, error) {
+	if tx == nil {
+		return "", ErrNilTransaction
+	}
+
+	id, err := tx.ID()
+	if err != nil {
+		return "", err
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	return id, g.check(id, tx, now)
+}
+
+// Accept checks the transaction and records it when it is not a replay
+func (g *Guard) Accept(tx *transaction.Transaction, now time.Time) (string, error) {
+	if tx == nil {
+		return "", ErrNilTransaction
+	}
+
+	id, err := tx.ID()
+	if err != nil {
+		return "", err
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if err := g.check(id, tx, now); err != nil {
+		return id, err
+	}
+	g.record(id, tx, now)
+	return id, nil
+}
+
+// Record remembers the transaction without checking it
+func (g *Guard) Record(tx *transaction.Transaction, now time.Time) {
+	id, err := tx.ID()
+	if err != nil {
+		return
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.record(id, tx, now)
+}
+
+// Forget drops the transaction ID so it can be accepted again.
+// The nonce of the sender is kept.
+func (g *Guard) Forget(tx *transaction.Transaction) {
+	id, err := tx.ID()
+	if err != nil {
+		return
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	delete(g.seen, id)
+}
+
+// Prune forgets transaction IDs and nonces whose window has passed
+func (g *Guard) Prune(now time.Time) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	for id, forgetAt := range g.seen {
+		if now.After(forgetAt) {
+			delete(g.seen, id)
+		}
+	}
+	for sender, used := range g.nonces {
+		for nonce, forgetAt := range used {
+			if now.After(forgetAt) {
+				delete(used, nonce)
+			}
+		}
+		if len(used) == 0 {
+			delete(g.nonces, sender)
+		}
+	}
+}
+
+// Len returns the number of remembered transaction IDs
+func (g *Guard) Len() int {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	return len(g.seen)
+}
+
+func (g *Guard) check(id string, tx *transaction.Transaction, now time.Time) error {
+	if _, exists := g.seen[id]; exists {
+		return ErrDuplicate
+	}
+
+	// Транзакции без времени создания (до версии 2) проверяются только по ID
+	if created := tx.CreatedTime(); !created.IsZero() {
+		if now.Sub(created) > g.window {
+			return fmt.Errorf("%w: created %s", ErrExpired, created.UTC().Format(time.RFC3339))
+		}
+		if created.Sub(now) > maxFutureDrift {
+			return fmt.Errorf("%w: created %s", ErrFromFuture, created.UTC().Format(time.RFC3339))
+		}
+	}
+
+	if _, used := g.nonces[tx.Sender][tx.Nonce]; tx.Nonce != 0 && used {
+		return fmt.Errorf("%w: %d", ErrStaleNonce, tx.Nonce)
+	}
+	return nil
+}
+
+func (g *Guard) record(id string, tx *transaction.Transaction, now time.Time) {
+	forgetAt := now.Add(g.window)
+	if created := tx.CreatedTime(); !created.IsZero() {
+		forgetAt = created.Add(g.window)
+	}
+	g.seen[id] = forgetAt
+
+	if tx.Nonce == 0 {
+		return
+	}
+	used, exists := g.nonces[tx.Sender]
+	if !exists {
+		used = make(map[uint64]time.Time)
+		g.nonces[tx.Sender] = used
+	}
+	used[tx.Nonce] = forgetAt
+}
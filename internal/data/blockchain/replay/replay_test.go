@@ -0,0 +1,131 @@
+package replay_test
+
+import (
+	"errors"
+	"sender/internal/data/blockchain/replay"
+	"sender/internal/data/blockchain/transaction"
+	"testing"
+	"time"
+)
+
+func newTransaction(sender string, nonce uint64, created time.Time) *transaction.Transaction {
+	return &transaction.Transaction{
+		Version:     transaction.PayloadV2,
+		Sender:      sender,
+		DealMessage: `{"id":1}`,
+		Nonce:       nonce,
+		CreatedAt:   created.UnixMilli(),
+		Signature:   "sig",
+	}
+}
+
+func TestAcceptRejectsReplays(t *testing.T) {
+	guard := replay.New(time.Hour)
+	now := time.Now()
+
+	tx := newTransaction("alice", 5, now)
+	if _, err := guard.Accept(tx, now); err != nil {
+		t.Fatalf("Accept failed: %v", err)
+	}
+
+	// подпись не входит в ID, перекодированная подпись - тот же ID
+	resigned := *tx
+	resigned.Signature = "other"
+	if _, err := guard.Accept(&resigned, now); !errors.Is(err, replay.ErrDuplicate) {
+		t.Errorf("Expected ErrDuplicate, got %v", err)
+	}
+
+	cases := map[string]struct {
+		tx  *transaction.Transaction
+		err error
+	}{
+		"stale nonce": {newTransaction("alice", 5, now.Add(time.Second)), replay.ErrStaleNonce},
+		"expired":     {newTransaction("alice", 6, now.Add(-2*time.Hour)), replay.ErrExpired},
+		"future":      {newTransaction("alice", 6, now.Add(time.Hour)), replay.ErrFromFuture},
+	}
+	for name, c := range cases {
+		if _, err := guard.Accept(c.tx, now); !errors.Is(err, c.err) {
+			t.Errorf("%s: expected %v, got %v", name, c.err, err)
+		}
+	}
+
+	// nonce считается для каждого отправителя отдельно
+	if _, err := guard.Accept(newTransaction("bob", 1, now), now); err != nil {
+		t.Errorf("Expected other sender to be accepted, got %v", err)
+	}
+	if _, err := guard.Accept(newTransaction("alice", 6, now), now); err != nil {
+		t.Errorf("Expected next nonce to be accepted, got %v", err)
+	}
+}
+
+func TestAcceptOutOfOrderNonces(t *testing.T) {
+	guard := replay.New(time.Hour)
+	now := time.Now()
+
+	if _, err := guard.Accept(newTransaction("alice", 10, now), now); err != nil {
+		t.Fatalf("Accept failed: %v", err)
+	}
+
+	// gossip не сохраняет порядок: неиспользованный меньший nonce принимается
+	if _, err := guard.Accept(newTransaction("alice", 9, now), now); err != nil {
+		t.Errorf("Expected unused lower nonce to be accepted, got %v", err)
+	}
+	if _, err := guard.Accept(newTransaction("alice", 9, now.Add(time.Second)), now); !errors.Is(err, replay.ErrStaleNonce) {
+		t.Errorf("Expected reused nonce to be rejected, got %v", err)
+	}
+
+	// nonce забывается вместе с окном, старая транзакция отсекается по времени
+	guard.Prune(now.Add(2 * time.Hour))
+	if _, err := guard.Check(newTransaction("alice", 9, now.Add(2*time.Hour)), now.Add(2*time.Hour)); err != nil {
+		t.Errorf("Expected nonce to be forgotten after the window, got %v", err)
+	}
+}
+
+func TestCheckDoesNotRecord(t *testing.T) {
+	guard := replay.New(time.Hour)
+	now := time.Now()
+	tx := newTransaction("alice", 1, now)
+
+	guard.Check(tx, now)
+	if _, err := guard.Check(tx, now); err != nil {
+		t.Errorf("Check must not record the transaction, got %v", err)
+	}
+	if guard.Len() != 0 {
+		t.Errorf("Expected empty guard, got %d", guard.Len())
+	}
+}
+
+func TestPruneAndForget(t *testing.T) {
+	guard := replay.New(time.Hour)
+	now := time.Now()
+
+	old := newTransaction("alice", 1, now)
+	guard.Record(old, now)
+	guard.Prune(now.Add(2 * time.Hour))
+	if guard.Len() != 0 {
+		t.Fatalf("Expected ID to be pruned after the window, got %d", guard.Len())
+	}
+
+	tx := newTransaction("alice", 2, now)
+	guard.Record(tx, now)
+	guard.Forget(tx)
+	if _, err := guard.Check(tx, now); !errors.Is(err, replay.ErrStaleNonce) {
+		t.Errorf("Forget must keep the nonce, got %v", err)
+	}
+}
+
+func TestTransactionID(t *testing.T) {
+	now := time.Now()
+	first := newTransaction("alice", 1, now)
+	second := newTransaction("alice", 2, now)
+
+	firstID, _ := first.ID()
+	secondID, _ := second.ID()
+	if firstID == "" || firstID == secondID {
+		t.Errorf("Expected distinct IDs, got %q and %q", firstID, secondID)
+	}
+	again, _ := first.ID()
+	if again != firstID {
+		t.Error("ID must be deterministic")
+	}
+}
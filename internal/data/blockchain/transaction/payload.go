@@ -0,0 +1,158 @@
+package transaction
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// LegacyVersion - transactions signed over "sender:message:transfer"
+	LegacyVersion = 0
+	// PayloadV1 - length-prefixed binary payload, see SigningPayload
+	PayloadV1 = 1
+	// PayloadV2 - PayloadV1 followed by the nonce and the creation time
+	PayloadV2 = 2
+	// PayloadV3 - PayloadV2 with the exact decimal transfer instead of float bits
+	PayloadV3 = 3
+
+	// CurrentVersion is used for newly signed transactions
+	CurrentVersion = PayloadV3
+)
+
+var (
+	// ErrLegacySignature is returned for versions before PayloadV3, they are
+	// signed over float transfer bits and versions 0-1 carry no nonce to stop replays
+	ErrLegacySignature    = errors.New("legacy transaction signatures are disabled")
+	ErrUnsupportedVersion = errors.New("unsupported transaction version")
+)
+
+var legacySignatures atomic.Bool
+
+// SetLegacySignatures allows verification of transactions signed with the
+// legacy "sender:message:transfer" payload and payload versions 1 and 2.
+// It is disabled by default.
+func SetLegacySignatures(allow bool) {
+	legacySignatures.Store(allow)
+}
+
+// LegacySignaturesAllowed reports whether legacy payloads are accepted
+func LegacySignaturesAllowed() bool {
+	return legacySignatures.Load()
+}
+
+// SigningPayload returns the bytes signed for the transaction version.
+//
+// Version 1 layout, every integer is big-endian:
+//
+//	u8   version (1)
+//	u32  len(algorithm) | algorithm
+//	u32  len(sender)    | sender
+//	u32  len(buyer)     | buyer
+//	u32  len(seller)    | seller
+//	u32  len(message)   | message
+//	u64  IEEE-754 bits of transfer
+//
+// Version 2 starts with version byte 2, has the same fields and appends:
+//
+//	u64  nonce
+//	i64  created_at, unix milliseconds
+//
+// Version 3 starts with version byte 3 and replaces the transfer float bits with
+//
+//	u32  len(transfer) | transfer
+//
+// where transfer is the canonical decimal string: plain notation without
+// exponent and trailing zeros ("5000", "0.15", "-1.5").
+// Versions 0-2 use the nearest float64 of the transfer, as they were signed.
+//
+// Strings are UTF-8 bytes exactly as they appear in the transaction JSON.
+func (t *Transaction) SigningPayload() ([]byte, error) {
+	switch t.Version {
+	case LegacyVersion:
+		return []byte(fmt.Sprintf("%s:%s:%v", t.Sender, t.DealMessage, t.Transfer.Float64())), nil
+	case PayloadV1:
+		return t.binaryPayload(PayloadV1), nil
+	case PayloadV2, PayloadV3:
+		payload := t.binaryPayload(byte(t.Version))
+		payload = binary.BigEndian.AppendUint64(payload, t.Nonce)
+		payload = binary.BigEndian.AppendUint64(payload, uint64(t.CreatedAt))
+		return payload, nil
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, t.Version)
+	}
+}
+
+// ID returns the hex encoded SHA-256 hash of the signing payload.
+// It does not depend on the signature, so re-encoded signatures keep the same ID.
+func (t *Transaction) ID() (string, error) {
+	if t == nil {
+		return "", errors.New("transaction is nil")
+	}
+
+	payload, err := t.SigningPayload()
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// CreatedTime returns the signed creation time, zero for transactions before version 2
+func (t *Transaction) CreatedTime() time.Time {
+	if t.CreatedAt == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(t.CreatedAt)
+}
+
+func (t *Transaction) binaryPayload(version byte) []byte {
+	fields := []string{string(t.Algorithm), t.Sender, t.BuyerPublicKey, t.SellerPublicKey, t.DealMessage}
+	transfer := t.Transfer.Canonical()
+
+	size := 1 + 4 + len(transfer) + 8 + 16
+	for _, field := range fields {
+		size += 4 + len(field)
+	}
+
+	payload := make([]byte, 0, size)
+	payload = append(payload, version)
+	for _, field := range fields {
+		payload = binary.BigEndian.AppendUint32(payload, uint32(len(field)))
+		payload = append(payload, field...)
+	}
+	if version >= PayloadV3 {
+		payload = binary.BigEndian.AppendUint32(payload, uint32(len(transfer)))
+		payload = append(payload, transfer...)
+	} else {
+		payload = binary.BigEndian.AppendUint64(payload, math.Float64bits(t.Transfer.Float64()))
+	}
+	return payload
+}
+
+// Последний выданный nonce для каждого отправителя
+var (
+	nonceMutex sync.Mutex
+	lastNonces = make(map[string]uint64)
+)
+
+// nextNonce returns a nonce greater than any previous nonce of the sender.
+// It starts from the current time so nonces keep growing across restarts.
+func nextNonce(sender string, now time.Time) uint64 {
+	nonceMutex.Lock()
+	defer nonceMutex.Unlock()
+
+	nonce := uint64(now.UnixNano())
+	if last := lastNonces[sender]; nonce <= last {
+		nonce = last + 1
+	}
+	lastNonces[sender] = nonce
+	return nonce
+}
@@ -0,0 +1,179 @@
+package transaction_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"sender/internal/data/blockchain/transaction"
+	"sender/internal/data/blockchain/wallet"
+	"sender/internal/data/deal"
+	"sender/internal/data/decimal"
+	"sender/internal/data/order"
+	"testing"
+)
+
+// Эталонный вектор, по нему сверяются реализации на других языках
+func TestSigningPayloadV1Vector(t *testing.T) {
+	tx := transaction.Transaction{
+		Version:         transaction.PayloadV1,
+		Algorithm:       wallet.Ed25519,
+		Sender:          "s",
+		BuyerPublicKey:  "b",
+		SellerPublicKey: "c",
+		DealMessage:     "{}",
+		Transfer:        decimal.MustParse("1.5"),
+	}
+
+	payload, err := tx.SigningPayload()
+	if err != nil {
+		t.Fatalf("SigningPayload failed: %v", err)
+	}
+
+	expected := "01" +
+		"00000007" + hex.EncodeToString([]byte("ed25519")) +
+		"00000001" + "73" +
+		"00000001" + "62" +
+		"00000001" + "63" +
+		"00000002" + "7b7d" +
+		"3ff8000000000000"
+	if hex.EncodeToString(payload) != expected {
+		t.Errorf("Unexpected payload:\n got %x\nwant %s", payload, expected)
+	}
+}
+
+func TestSigningPayloadV3Vector(t *testing.T) {
+	tx := transaction.Transaction{
+		Version:         transaction.PayloadV3,
+		Algorithm:       wallet.Ed25519,
+		Sender:          "s",
+		BuyerPublicKey:  "b",
+		SellerPublicKey: "c",
+		DealMessage:     "{}",
+		Transfer:        decimal.MustParse("15.0750"),
+		Nonce:           1,
+		CreatedAt:       2,
+	}
+
+	payload, err := tx.SigningPayload()
+	if err != nil {
+		t.Fatalf("SigningPayload failed: %v", err)
+	}
+
+	expected := "03" +
+		"00000007" + hex.EncodeToString([]byte("ed25519")) +
+		"00000001" + "73" +
+		"00000001" + "62" +
+		"00000001" + "63" +
+		"00000002" + "7b7d" +
+		"00000006" + hex.EncodeToString([]byte("15.075")) +
+		"0000000000000001" +
+		"0000000000000002"
+	if hex.EncodeToString(payload) != expected {
+		t.Errorf("Unexpected payload:\n got %x\nwant %s", payload, expected)
+	}
+}
+
+func TestSigningPayloadIsUnambiguous(t *testing.T) {
+	first := transaction.Transaction{Version: transaction.PayloadV1, Sender: "a:b", DealMessage: "c"}
+	second := transaction.Transaction{Version: transaction.PayloadV1, Sender: "a", DealMessage: "b:c"}
+
+	firstPayload, _ := first.SigningPayload()
+	secondPayload, _ := second.SigningPayload()
+	if bytes.Equal(firstPayload, secondPayload) {
+		t.Error("Different transactions produced the same payload")
+	}
+
+	unknown := transaction.Transaction{Version: 99}
+	if _, err := unknown.SigningPayload(); !errors.Is(err, transaction.ErrUnsupportedVersion) {
+		t.Errorf("Expected ErrUnsupportedVersion, got %v", err)
+	}
+}
+
+func TestSignatureCoversCounterparties(t *testing.T) {
+	w, _ := wallet.NewWithAlgorithm(wallet.Ed25519)
+	d := &deal.Deal{
+		ID:        1,
+		BuyOrder:  &order.Order{ID: 1, UserHashPublicKey: "buyer-public-key", Quantity: decimal.New(1, 0), UnitPrice: decimal.New(10, 0)},
+		SellOrder: &order.Order{ID: 2, UserHashPublicKey: "seller-public-key"},
+	}
+	tx, _ := transaction.New(w, d)
+	if err := tx.Sign(); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if tx.Version != transaction.CurrentVersion {
+		t.Errorf("Expected version %d, got %d", transaction.CurrentVersion, tx.Version)
+	}
+
+	tx.BuyerPublicKey, tx.SellerPublicKey = tx.SellerPublicKey, tx.BuyerPublicKey
+	if valid, _ := tx.Verify(w.PublicKey); valid {
+		t.Error("Swapped counterparties must not verify")
+	}
+}
+
+func TestSignAssignsNonceAndCreationTime(t *testing.T) {
+	w, _ := wallet.NewWithAlgorithm(wallet.Ed25519)
+	d := &deal.Deal{
+		ID:        1,
+		BuyOrder:  &order.Order{ID: 1, UserHashPublicKey: "buyer-public-key"},
+		SellOrder: &order.Order{ID: 2, UserHashPublicKey: "seller-public-key"},
+	}
+
+	first, _ := transaction.New(w, d)
+	second, _ := transaction.New(w, d)
+	first.Sign()
+	second.Sign()
+
+	if first.CreatedAt == 0 || first.Nonce == 0 {
+		t.Fatalf("Expected nonce and creation time, got %d, %d", first.Nonce, first.CreatedAt)
+	}
+	if second.Nonce <= first.Nonce {
+		t.Errorf("Expected increasing nonces, got %d after %d", second.Nonce, first.Nonce)
+	}
+
+	// одна и та же сделка, подписанная дважды, дает разные ID
+	firstID, _ := first.ID()
+	secondID, _ := second.ID()
+	if firstID == secondID {
+		t.Error("Expected distinct transaction IDs")
+	}
+
+	if valid, err := second.Verify(w.PublicKey); !valid || err != nil {
+		t.Errorf("Verify failed: %v", err)
+	}
+	second.Nonce++
+	if valid, _ := second.Verify(w.PublicKey); valid {
+		t.Error("Nonce must be covered by the signature")
+	}
+}
+
+func TestPayloadV1RejectedByDefault(t *testing.T) {
+	w, _ := wallet.NewWithAlgorithm(wallet.Ed25519)
+	tx := transaction.Transaction{
+		Version:         transaction.PayloadV1,
+		Algorithm:       wallet.Ed25519,
+		Sender:          w.Serialize().PublicKey,
+		BuyerPublicKey:  "buyer-public-key",
+		SellerPublicKey: "seller-public-key",
+		DealMessage:     "{}",
+		Transfer:        decimal.MustParse("1.5"),
+	}
+	payload, _ := tx.SigningPayload()
+	signature, err := w.Sign(payload)
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	tx.Signature = base64.RawStdEncoding.EncodeToString(signature)
+
+	// без nonce подпись v1 можно повторять, она принимается только в режиме совместимости
+	if _, err := tx.Verify(w.PublicKey); !errors.Is(err, transaction.ErrLegacySignature) {
+		t.Fatalf("Expected ErrLegacySignature, got %v", err)
+	}
+
+	transaction.SetLegacySignatures(true)
+	defer transaction.SetLegacySignatures(false)
+
+	if valid, err := tx.Verify(w.PublicKey); !valid || err != nil {
+		t.Fatalf("V1 transaction failed to verify in legacy mode: %v", err)
+	}
+}
@@ -1,44 +1,55 @@
 package transaction
 
 import (
-	"crypto/rand"
-	"crypto/rsa"
+	"crypto"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
-	"fmt"
 	"sender/internal/data/blockchain/wallet"
 	"sender/internal/data/deal"
+	"sender/internal/data/decimal"
 	"sender/internal/jsonutil"
+	"time"
 )
 
+// ErrMissingCounterparty is returned for deals without a buyer or seller public key
+var ErrMissingCounterparty = errors.New("deal has no buyer or seller public key")
+
 type Transaction struct {
-	Sender          string  `json:"sender"`
-	BuyerPublicKey  string  `json:"buyer"`
-	SellerPublicKey string  `json:"seller"`
-	DealMessage     string  `json:"message"`
-	Transfer        float64 `json:"transfer"`
-	Signature       string  `json:"signature"`
-	wallet          *wallet.Wallet
-	deal            *deal.Deal
+	Sender          string          `json:"sender"`
+	BuyerPublicKey  string          `json:"buyer"`
+	SellerPublicKey string          `json:"seller"`
+	DealMessage     string          `json:"message"`
+	Transfer        decimal.Decimal `json:"transfer"`
+	// Signing payload version, see SigningPayload
+	Version int `json:"version,omitempty"`
+	// Per-sender increasing number, makes every signed transaction unique
+	Nonce uint64 `json:"nonce,omitempty"`
+	// Creation time in unix milliseconds
+	CreatedAt int64 `json:"created_at,omitempty"`
+	// Signature scheme, empty for transactions signed before schemes were introduced (RSA)
+	Algorithm wallet.Algorithm `json:"algorithm,omitempty"`
+	Signature string           `json:"signature"`
+	wallet    *wallet.Wallet
+	deal      *deal.Deal
 }
 
-// New creates a new transaction and initializes it with data
+// New creates a new transaction and initializes it with data.
+// The deal should be checked with validation.ValidateDeal first.
 func New(walletKeys *wallet.Wallet, deal *deal.Deal) (Transaction, error) {
+	if deal == nil || deal.BuyOrder == nil || deal.SellOrder == nil ||
+		deal.BuyOrder.UserHashPublicKey == "" || deal.SellOrder.UserHashPublicKey == "" {
+		return Transaction{}, ErrMissingCounterparty
+	}
 	serializeWallet := walletKeys.Sereliaze()
 
-	// Calculate transfer amount
-	transfer := deal.BuyOrder.Quantity * deal.BuyOrder.UnitPrice
+	// Calculate transfer amount exactly, the same way as the deal service does
+	transfer := deal.Amount()
 	jsonData, _ := deal.ToJson()
 	dataString := string(jsonData)
 	seller := deal.SellOrder.UserHashPublicKey
-	if len(seller) < 10 {
-		seller = wallet.New().Sereliaze().PublicKey
-	}
 	buyer := deal.BuyOrder.UserHashPublicKey
-	if len(buyer) < 10 {
-		buyer = wallet.New().Sereliaze().PublicKey
-	}
 
 	return Transaction{
 		Sender:          serializeWallet.PublicKey,
@@ -57,19 +68,24 @@ func (t *Transaction) Sign() error {
 	if t == nil {
 		return errors.New("transaction is nil")
 	}
+	if t.wallet == nil {
+		return errors.New("transaction has no wallet")
+	}
 
-	// Format the data to sign (Sender, Message, Transfer)
-	dataToSign := fmt.Sprintf("%s:%s:%v", t.Sender, t.DealMessage, t.Transfer)
-	messageBytes := []byte(dataToSign)
-
-	// Hash the data
-	hasher := sha256.New()
-	hasher.Write(messageBytes)
-	hashedMessage := hasher.Sum(nil)
+	t.Version = CurrentVersion
+	t.Algorithm = t.wallet.Algorithm
+	if t.CreatedAt == 0 {
+		now := time.Now()
+		t.CreatedAt = now.UnixMilli()
+		t.Nonce = nextNonce(t.Sender, now)
+	}
+	payload, err := t.SigningPayload()
+	if err != nil {
+		return err
+	}
 
 	// Create the signature
-	privateKey := t.wallet.PrivateKey
-	signatureBytes, err := rsa.SignPKCS1v15(rand.Reader, privateKey, 0, hashedMessage)
+	signatureBytes, err := t.wallet.Sign(payload)
 	if err != nil {
 		return errors.New("failed to sign transaction: " + err.Error())
 	}
@@ -79,20 +95,19 @@ func (t *Transaction) Sign() error {
 	return nil
 }
 
-// Verify verifies the signature of the transaction
-func (t *Transaction) Verify(publicKey *rsa.PublicKey) (bool, error) {
+// Verify verifies the signature of the transaction with the scheme it was signed with
+func (t *Transaction) Verify(publicKey crypto.PublicKey) (bool, error) {
 	if t == nil {
 		return false, errors.New("transaction is nil")
 	}
 
-	// Format the data to verify (Sender, Message, Transfer)
-	dataToVerify := fmt.Sprintf("%s:%s:%v", t.Sender, t.DealMessage, t.Transfer)
-	messageBytes := []byte(dataToVerify)
-
-	// Hash the data
-	hasher := sha256.New()
-	hasher.Write(messageBytes)
-	hashedMessage := hasher.Sum(nil)
+	if t.Version < PayloadV3 && !LegacySignaturesAllowed() {
+		return false, ErrLegacySignature
+	}
+	payload, err := t.SigningPayload()
+	if err != nil {
+		return false, err
+	}
 
 	// Decode the signature from Base64
 	signatureBytes, err := base64.RawStdEncoding.DecodeString(t.Signature)
@@ -101,7 +116,7 @@ func (t *Transaction) Verify(publicKey *rsa.PublicKey) (bool, error) {
 	}
 
 	// Verify the signature
-	err = rsa.VerifyPKCS1v15(publicKey, 0, hashedMessage, signatureBytes)
+	err = wallet.Verify(t.Algorithm, publicKey, payload, signatureBytes)
 	if err != nil {
 		return false, errors.New("signature verification failed: " + err.Error())
 	}
@@ -109,15 +124,68 @@ func (t *Transaction) Verify(publicKey *rsa.PublicKey) (bool, error) {
 	return true, nil
 }
 
+// SenderKey decodes the public key in the Sender field
+// (base64 PKCS#1 for RSA wallets, any format accepted by wallet.ParsePublicKey)
+func (t *Transaction) SenderKey() (crypto.PublicKey, error) {
+	return wallet.ParsePublicKey(t.Sender)
+}
+
+// VerifySender verifies the signature with the key in the Sender field
+func (t *Transaction) VerifySender() error {
+	if t == nil {
+		return errors.New("transaction is nil")
+	}
+
+	publicKey, err := t.SenderKey()
+	if err != nil {
+		return errors.New("invalid sender key: " + err.Error())
+	}
+
+	_, err = t.Verify(publicKey)
+	return err
+}
+
 // ToJson serializes the transaction to JSON
 func (t *Transaction) ToJson() ([]byte, error) {
 	return jsonutil.ToJSON(t)
 }
 
+// Hash returns the hex encoded SHA-256 hash of the serialized transaction
+func (t *Transaction) Hash() (string, error) {
+	if t == nil {
+		return "", errors.New("transaction is nil")
+	}
+
+	transactionJson, err := t.ToJson()
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(transactionJson)
+	return hex.EncodeToString(sum[:]), nil
+}
+
 func (t *Transaction) GetDeal() *deal.Deal {
 	return t.deal
 }
 
+// DealID returns the ID of the deal in the message field,
+// false if the message is not a deal
+func (t *Transaction) DealID() (int, bool) {
+	if t == nil {
+		return 0, false
+	}
+
+	d := t.deal
+	if d == nil {
+		var err error
+		if d, err = deal.FromJson([]byte(t.DealMessage)); err != nil {
+			return 0, false
+		}
+	}
+	return d.ID, d.ID != 0
+}
+
 // FromJson deserializes a transaction from JSON
 func FromJson(jsonData []byte) (*Transaction, error) {
 	var transaction Transaction
@@ -1,9 +1,16 @@
 package transaction_test
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"sender/internal/data/blockchain/transaction"
 	"sender/internal/data/blockchain/wallet"
 	"sender/internal/data/deal"
+	"sender/internal/data/decimal"
 	"sender/internal/data/order"
 	"testing"
 )
@@ -18,16 +25,16 @@ func TestTransactionCreation(t *testing.T) {
 		UserHashPublicKey:  w.Sereliaze().PublicKey,
 		CryptocurrencyCode: "BTC",
 		TypeName:           "buy",
-		UnitPrice:          50000.0,
-		Quantity:           0.1,
+		UnitPrice:          decimal.MustParse("50000.0"),
+		Quantity:           decimal.MustParse("0.1"),
 	}
 	sellOrder := &order.Order{
 		ID:                 2,
 		UserHashPublicKey:  w.Sereliaze().PublicKey,
 		CryptocurrencyCode: "BTC",
 		TypeName:           "sell",
-		UnitPrice:          50000.0,
-		Quantity:           0.1,
+		UnitPrice:          decimal.MustParse("50000.0"),
+		Quantity:           decimal.MustParse("0.1"),
 	}
 
 	// Создаем сделку
@@ -50,11 +57,52 @@ func TestTransactionCreation(t *testing.T) {
 	if tx.Sender == "" || tx.BuyerPublicKey == "" || tx.SellerPublicKey == "" {
 		t.Fatal("Transaction fields are not properly initialized")
 	}
-	if tx.Transfer != 5000.0 {
+	if !tx.Transfer.Equal(decimal.New(5000, 0)) {
 		t.Fatalf("Expected transfer amount to be 5000.0, got %v", tx.Transfer)
 	}
 }
 
+func TestTransactionTransferIsExact(t *testing.T) {
+	w, _ := wallet.NewWithAlgorithm(wallet.Ed25519)
+	d := &deal.Deal{
+		ID:        1,
+		BuyOrder:  &order.Order{ID: 1, UserHashPublicKey: "buyer-public-key", Quantity: decimal.MustParse("0.1"), UnitPrice: decimal.MustParse("150.75")},
+		SellOrder: &order.Order{ID: 2, UserHashPublicKey: "seller-public-key"},
+	}
+
+	tx, _ := transaction.New(w, d)
+	// во float64 0.1 * 150.75 = 15.075000000000001
+	if tx.Transfer.String() != "15.075" {
+		t.Fatalf("Expected transfer 15.075, got %s", tx.Transfer)
+	}
+
+	tx.Sign()
+	jsonData, _ := tx.ToJson()
+	received, _ := transaction.FromJson(jsonData)
+	if !received.Transfer.Equal(tx.Transfer) {
+		t.Errorf("Transfer changed after JSON round trip: %s", received.Transfer)
+	}
+	if valid, err := received.Verify(w.PublicKey); !valid || err != nil {
+		t.Errorf("Verify failed: %v", err)
+	}
+}
+
+func TestTransactionRequiresCounterparties(t *testing.T) {
+	w, _ := wallet.NewWithAlgorithm(wallet.Ed25519)
+	d := &deal.Deal{
+		ID:        1,
+		BuyOrder:  &order.Order{ID: 1},
+		SellOrder: &order.Order{ID: 2, UserHashPublicKey: "seller-public-key"},
+	}
+
+	if _, err := transaction.New(w, d); !errors.Is(err, transaction.ErrMissingCounterparty) {
+		t.Errorf("Expected ErrMissingCounterparty, got %v", err)
+	}
+	if _, err := transaction.New(w, &deal.Deal{ID: 1}); !errors.Is(err, transaction.ErrMissingCounterparty) {
+		t.Errorf("Expected ErrMissingCounterparty for deal without orders, got %v", err)
+	}
+}
+
 func TestTransactionSigningAndVerification(t *testing.T) {
 	// Создаем кошелек и транзакцию
 	w := wallet.New()
@@ -124,11 +172,25 @@ func TestTransactionFromJson(t *testing.T) {
 	if tx.Sender != "TestSender" || tx.BuyerPublicKey != "TestBuyerPublicKey" {
 		t.Fatal("Deserialized transaction contains incorrect data")
 	}
-	if tx.Transfer != 1000.0 {
+	if !tx.Transfer.Equal(decimal.New(1000, 0)) {
 		t.Fatalf("Expected transfer amount to be 1000.0, got %v", tx.Transfer)
 	}
 }
 
+func TestTransactionDealID(t *testing.T) {
+	// транзакция из блока или mempool приходит без разобранной сделки
+	var tx transaction.Transaction
+	tx.DealMessage = `{"id":42,"buyOrder":{"id":1},"sellOrder":{"id":2}}`
+	if dealID, ok := tx.DealID(); !ok || dealID != 42 {
+		t.Fatalf("Expected deal 42, got %d %v", dealID, ok)
+	}
+
+	tx.DealMessage = "not a deal"
+	if _, ok := tx.DealID(); ok {
+		t.Error("Expected message without a deal to have no deal ID")
+	}
+}
+
 func TestInvalidTransactionSigning(t *testing.T) {
 	var tx *transaction.Transaction
 
@@ -148,3 +210,107 @@ func TestInvalidTransactionVerification(t *testing.T) {
 		t.Fatal("Expected error when verifying nil transaction")
 	}
 }
+
+func TestTransactionSignatureSchemes(t *testing.T) {
+	for _, algorithm := range []wallet.Algorithm{wallet.Ed25519, wallet.ECDSAP256} {
+		w, err := wallet.NewWithAlgorithm(algorithm)
+		if err != nil {
+			t.Fatalf("%s: NewWithAlgorithm failed: %v", algorithm, err)
+		}
+		d := &deal.Deal{
+			ID:        1,
+			BuyOrder:  &order.Order{ID: 1, UserHashPublicKey: w.Serialize().PublicKey},
+			SellOrder: &order.Order{ID: 2, UserHashPublicKey: w.Serialize().PublicKey},
+		}
+		tx, _ := transaction.New(w, d)
+
+		if err := tx.Sign(); err != nil {
+			t.Fatalf("%s: Sign failed: %v", algorithm, err)
+		}
+		if tx.Algorithm != algorithm {
+			t.Errorf("%s: transaction tagged with %q", algorithm, tx.Algorithm)
+		}
+
+		// алгоритм должен пережить передачу по сети
+		jsonData, _ := tx.ToJson()
+		received, err := transaction.FromJson(jsonData)
+		if err != nil {
+			t.Fatalf("%s: FromJson failed: %v", algorithm, err)
+		}
+		if valid, err := received.Verify(w.PublicKey); !valid || err != nil {
+			t.Errorf("%s: Verify failed: %v", algorithm, err)
+		}
+
+		received.Transfer = received.Transfer.Add(decimal.New(1, 0))
+		if valid, _ := received.Verify(w.PublicKey); valid {
+			t.Errorf("%s: tampered transaction verified", algorithm)
+		}
+	}
+}
+
+func TestLegacyRSATransactionVerifies(t *testing.T) {
+	w := wallet.New()
+
+	// транзакция, подписанная до появления поля algorithm
+	tx := transaction.Transaction{
+		Sender:      w.Serialize().PublicKey,
+		DealMessage: `{"id":1}`,
+		Transfer:    decimal.New(1000, 0),
+	}
+	hashed := sha256.Sum256([]byte(fmt.Sprintf("%s:%s:%v", tx.Sender, tx.DealMessage, tx.Transfer.Float64())))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, w.PrivateKey.(*rsa.PrivateKey), 0, hashed[:])
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	tx.Signature = base64.RawStdEncoding.EncodeToString(signature)
+
+	// по умолчанию старый формат подписи отклоняется
+	if _, err := tx.Verify(w.PublicKey); !errors.Is(err, transaction.ErrLegacySignature) {
+		t.Fatalf("Expected ErrLegacySignature, got %v", err)
+	}
+
+	transaction.SetLegacySignatures(true)
+	defer transaction.SetLegacySignatures(false)
+
+	if valid, err := tx.Verify(w.PublicKey); !valid || err != nil {
+		t.Fatalf("Legacy transaction failed to verify: %v", err)
+	}
+}
+
+func TestVerifySender(t *testing.T) {
+	w := wallet.New()
+	d := &deal.Deal{
+		ID:        1,
+		BuyOrder:  &order.Order{ID: 1, UserHashPublicKey: w.Serialize().PublicKey},
+		SellOrder: &order.Order{ID: 2, UserHashPublicKey: w.Serialize().PublicKey},
+	}
+	tx, _ := transaction.New(w, d)
+	tx.Sign()
+
+	// ключ берется из поля Sender (base64 PKCS#1)
+	received, _ := transaction.FromJson(mustJson(t, &tx))
+	if err := received.VerifySender(); err != nil {
+		t.Fatalf("VerifySender failed: %v", err)
+	}
+
+	other := wallet.New()
+	received.Sender = other.Serialize().PublicKey
+	if err := received.VerifySender(); err == nil {
+		t.Error("Expected signature of another sender to fail")
+	}
+
+	received.Sender = "not-a-key"
+	if err := received.VerifySender(); err == nil {
+		t.Error("Expected invalid sender key to fail")
+	}
+}
+
+func mustJson(t *testing.T, tx *transaction.Transaction) []byte {
+	t.Helper()
+
+	data, err := tx.ToJson()
+	if err != nil {
+		t.Fatalf("ToJson failed: %v", err)
+	}
+	return data
+}
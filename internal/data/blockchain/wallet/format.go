@@ -0,0 +1,246 @@
+package wallet
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeyFormat is the encoding used to import and export wallet keys
+type KeyFormat string
+
+const (
+	// FormatRaw - base64 DER without padding (WalletSerialize): PKCS#1 for RSA keys,
+	// PKIX / PKCS#8 for the other algorithms
+	FormatRaw KeyFormat = "raw"
+	// FormatPKCS1 - PEM "RSA PRIVATE KEY" and "RSA PUBLIC KEY", RSA only
+	FormatPKCS1 KeyFormat = "pkcs1"
+	// FormatPKCS8 - PEM "PRIVATE KEY" (PKCS#8) and "PUBLIC KEY" (PKIX), what OpenSSL and Java expect
+	FormatPKCS8 KeyFormat = "pkcs8"
+)
+
+// PEM block types
+const (
+	pemRSAPrivateKey = "RSA PRIVATE KEY"
+	pemRSAPublicKey  = "RSA PUBLIC KEY"
+	pemPrivateKey    = "PRIVATE KEY"
+	pemPublicKey     = "PUBLIC KEY"
+)
+
+var (
+	ErrUnknownFormat  = errors.New("unknown key format")
+	ErrUnsupportedKey = errors.New("unsupported key type")
+)
+
+// ParseFormat converts a format name, empty means FormatRaw
+func ParseFormat(name string) (KeyFormat, error) {
+	switch format := KeyFormat(strings.ToLower(name)); format {
+	case "":
+		return FormatRaw, nil
+	case FormatRaw, FormatPKCS1, FormatPKCS8:
+		return format, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownFormat, name)
+	}
+}
+
+// Export encodes both keys in the given format
+func (w *Wallet) Export(format KeyFormat) (*WalletSerialize, error) {
+	publicKey, err := EncodePublicKey(w.PublicKey, format)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, err := EncodePrivateKey(w.PrivateKey, format)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WalletSerialize{
+		PublicKey:  publicKey,
+		PrivateKey: privateKey,
+	}, nil
+}
+
+// Import restores a wallet from keys in any supported format.
+// The public key may be empty, then it is taken from the private key.
+func Import(publicKey string, privateKey string) (*Wallet, error) {
+	private, err := ParsePrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var public crypto.PublicKey
+	if publicKey != "" {
+		public, err = ParsePublicKey(publicKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return fromKeys(public, private)
+}
+
+// EncodePublicKey encodes the public key in the given format
+func EncodePublicKey(key crypto.PublicKey, format KeyFormat) (string, error) {
+	if _, err := AlgorithmOf(key); err != nil {
+		return "", err
+	}
+	rsaKey, isRSA := key.(*rsa.PublicKey)
+
+	switch format {
+	case FormatRaw:
+		if isRSA {
+			return base64.RawStdEncoding.EncodeToString(x509.MarshalPKCS1PublicKey(rsaKey)), nil
+		}
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			return "", err
+		}
+		return base64.RawStdEncoding.EncodeToString(der), nil
+	case FormatPKCS1:
+		if !isRSA {
+			return "", fmt.Errorf("%w: %T in %s", ErrUnsupportedKey, key, format)
+		}
+		return encodePEM(pemRSAPublicKey, x509.MarshalPKCS1PublicKey(rsaKey)), nil
+	case FormatPKCS8:
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			return "", err
+		}
+		return encodePEM(pemPublicKey, der), nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+}
+
+// EncodePrivateKey encodes the private key in the given format
+func EncodePrivateKey(key crypto.Signer, format KeyFormat) (string, error) {
+	if _, err := AlgorithmOf(key.Public()); err != nil {
+		return "", err
+	}
+	rsaKey, isRSA := key.(*rsa.PrivateKey)
+
+	switch format {
+	case FormatRaw:
+		if isRSA {
+			return base64.RawStdEncoding.EncodeToString(x509.MarshalPKCS1PrivateKey(rsaKey)), nil
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return "", err
+		}
+		return base64.RawStdEncoding.EncodeToString(der), nil
+	case FormatPKCS1:
+		if !isRSA {
+			return "", fmt.Errorf("%w: %T in %s", ErrUnsupportedKey, key, format)
+		}
+		return encodePEM(pemRSAPrivateKey, x509.MarshalPKCS1PrivateKey(rsaKey)), nil
+	case FormatPKCS8:
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return "", err
+		}
+		return encodePEM(pemPrivateKey, der), nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+}
+
+// ParsePublicKey decodes a public key, detecting PEM or base64 DER
+// with either PKCS#1 or PKIX contents
+func ParsePublicKey(data string) (crypto.PublicKey, error) {
+	der, pemType, err := decodeKey(data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch pemType {
+	case pemRSAPublicKey:
+		return x509.ParsePKCS1PublicKey(der)
+	case pemPublicKey:
+		return parsePKIXPublicKey(der)
+	case "":
+		if key, err := x509.ParsePKCS1PublicKey(der); err == nil {
+			return key, nil
+		}
+		return parsePKIXPublicKey(der)
+	default:
+		return nil, fmt.Errorf("%w: PEM block %q", ErrUnsupportedKey, pemType)
+	}
+}
+
+// ParsePrivateKey decodes a private key, detecting PEM or base64 DER
+// with either PKCS#1 or PKCS#8 contents
+func ParsePrivateKey(data string) (crypto.Signer, error) {
+	der, pemType, err := decodeKey(data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch pemType {
+	case pemRSAPrivateKey:
+		return x509.ParsePKCS1PrivateKey(der)
+	case pemPrivateKey:
+		return parsePKCS8PrivateKey(der)
+	case "":
+		if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+			return key, nil
+		}
+		return parsePKCS8PrivateKey(der)
+	default:
+		return nil, fmt.Errorf("%w: PEM block %q", ErrUnsupportedKey, pemType)
+	}
+}
+
+func parsePKIXPublicKey(der []byte) (crypto.PublicKey, error) {
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := AlgorithmOf(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func parsePKCS8PrivateKey(der []byte) (crypto.Signer, error) {
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, key)
+	}
+	if _, err := AlgorithmOf(signer.Public()); err != nil {
+		return nil, err
+	}
+	return signer, nil
+}
+
+// decodeKey returns the DER bytes of a PEM block or of a base64 string
+// (padded or not) together with the PEM block type
+func decodeKey(data string) ([]byte, string, error) {
+	data = strings.TrimSpace(data)
+
+	if block, _ := pem.Decode([]byte(data)); block != nil {
+		return block.Bytes, block.Type, nil
+	}
+
+	der, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(data, "="))
+	if err != nil {
+		return nil, "", err
+	}
+	return der, "", nil
+}
+
+func encodePEM(blockType string, der []byte) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}))
+}
@@ -0,0 +1,94 @@
+package wallet_test
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"sender/internal/data/blockchain/wallet"
+	"strings"
+	"testing"
+)
+
+func TestExportImportFormats(t *testing.T) {
+	w := wallet.New()
+
+	cases := map[wallet.KeyFormat][2]string{
+		wallet.FormatPKCS1: {"RSA PUBLIC KEY", "RSA PRIVATE KEY"},
+		wallet.FormatPKCS8: {"PUBLIC KEY", "PRIVATE KEY"},
+	}
+	for format, pemTypes := range cases {
+		exported, err := w.Export(format)
+		if err != nil {
+			t.Fatalf("%s: Export failed: %v", format, err)
+		}
+
+		publicBlock, _ := pem.Decode([]byte(exported.PublicKey))
+		privateBlock, _ := pem.Decode([]byte(exported.PrivateKey))
+		if publicBlock == nil || publicBlock.Type != pemTypes[0] {
+			t.Errorf("%s: unexpected public PEM %v", format, publicBlock)
+		}
+		if privateBlock == nil || privateBlock.Type != pemTypes[1] {
+			t.Errorf("%s: unexpected private PEM %v", format, privateBlock)
+		}
+
+		imported, err := wallet.Deserialize(exported)
+		if err != nil {
+			t.Fatalf("%s: Deserialize failed: %v", format, err)
+		}
+		if !imported.PrivateKey.(*rsa.PrivateKey).Equal(w.PrivateKey) || !imported.PublicKey.(*rsa.PublicKey).Equal(w.PublicKey) {
+			t.Errorf("%s: keys changed after round trip", format)
+		}
+	}
+}
+
+func TestParseDetectsBase64DER(t *testing.T) {
+	w := wallet.New()
+
+	// Java отдает ключи в base64 с паддингом: X.509 для публичного и PKCS#8 для приватного
+	pkix, _ := x509.MarshalPKIXPublicKey(w.PublicKey)
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(w.PrivateKey)
+
+	imported, err := wallet.Import(base64.StdEncoding.EncodeToString(pkix), base64.StdEncoding.EncodeToString(pkcs8))
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if !imported.PublicKey.(*rsa.PublicKey).Equal(w.PublicKey) {
+		t.Error("Public key does not match")
+	}
+
+	// старый формат WalletSerialize продолжает читаться
+	raw := w.Serialize()
+	if strings.HasPrefix(raw.PublicKey, "-----") {
+		t.Fatal("Serialize must keep the raw format")
+	}
+	if _, err := wallet.Deserialize(raw); err != nil {
+		t.Errorf("Deserialize of raw wallet failed: %v", err)
+	}
+}
+
+func TestImportWithoutPublicKey(t *testing.T) {
+	w := wallet.New()
+	exported, _ := w.Export(wallet.FormatPKCS8)
+
+	imported, err := wallet.Import("", exported.PrivateKey)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if !imported.PublicKey.(*rsa.PublicKey).Equal(w.PublicKey) {
+		t.Error("Public key must be derived from the private key")
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if format, err := wallet.ParseFormat(""); err != nil || format != wallet.FormatRaw {
+		t.Errorf("Expected raw by default, got %q, %v", format, err)
+	}
+	if format, err := wallet.ParseFormat("PKCS8"); err != nil || format != wallet.FormatPKCS8 {
+		t.Errorf("Expected pkcs8, got %q, %v", format, err)
+	}
+	if _, err := wallet.ParseFormat("jwk"); !errors.Is(err, wallet.ErrUnknownFormat) {
+		t.Errorf("Expected ErrUnknownFormat, got %v", err)
+	}
+}
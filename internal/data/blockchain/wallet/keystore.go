@@ -0,0 +1,218 @@
+package wallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Параметры scrypt для шифрования приватного ключа
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = 32
+	saltLength   = 16
+)
+
+var (
+	ErrPasswordRequired = errors.New("wallet is encrypted, password required")
+	ErrInvalidPassword  = errors.New("invalid wallet password")
+	ErrKeyMismatch      = errors.New("public key does not match private key")
+)
+
+// encryptedKey is the private key encrypted with AES-GCM under a scrypt derived key
+type encryptedKey struct {
+	KDF        string `json:"kdf"`
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	Salt       string `json:"salt"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+// keystoreFile is the on-disk wallet. Without a password it has the same
+// layout as WalletSerialize, with a password private_key is replaced by crypto.
+type keystoreFile struct {
+	PublicKey  string        `json:"public_key"`
+	PrivateKey string        `json:"private_key,omitempty"`
+	Crypto     *encryptedKey `json:"crypto,omitempty"`
+}
+
+// Marshal encodes the wallet for storage, the private key is encrypted when password is not empty
+func Marshal(w *Wallet, password string) ([]byte, error) {
+	serialized := w.Serialize()
+	file := keystoreFile{PublicKey: serialized.PublicKey}
+
+	if password == "" {
+		file.PrivateKey = serialized.PrivateKey
+	} else {
+		encrypted, err := encryptKey([]byte(serialized.PrivateKey), password)
+		if err != nil {
+			return nil, err
+		}
+		file.Crypto = encrypted
+	}
+
+	return json.MarshalIndent(file, "", "  ")
+}
+
+// Unmarshal restores a wallet encoded with Marshal
+func Unmarshal(data []byte, password string) (*Wallet, error) {
+	var file keystoreFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	serialized := &WalletSerialize{
+		PublicKey:  file.PublicKey,
+		PrivateKey: file.PrivateKey,
+	}
+
+	if file.Crypto != nil {
+		if password == "" {
+			return nil, ErrPasswordRequired
+		}
+		privateKey, err := decryptKey(file.Crypto, password)
+		if err != nil {
+			return nil, err
+		}
+		serialized.PrivateKey = string(privateKey)
+	}
+
+	return Deserialize(serialized)
+}
+
+// Save writes the wallet to the file, the private key is encrypted when password is not empty
+func Save(path string, w *Wallet, password string) error {
+	data, err := Marshal(w, password)
+	if err != nil {
+		return err
+	}
+
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return err
+		}
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// Load reads the wallet saved with Save
+func Load(path string, password string) (*Wallet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Unmarshal(data, password)
+}
+
+// LoadOrCreate loads the wallet from the file or, on first run,
+// generates a new one with the given algorithm and saves it there
+func LoadOrCreate(path string, password string, algorithm Algorithm) (*Wallet, error) {
+	w, err := Load(path, password)
+	if err == nil {
+		return w, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("load wallet %s: %w", path, err)
+	}
+
+	w, err = NewWithAlgorithm(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if err := Save(path, w, password); err != nil {
+		return nil, fmt.Errorf("save wallet %s: %w", path, err)
+	}
+	log.Printf("New wallet saved to %s", path)
+	return w, nil
+}
+
+func encryptKey(plaintext []byte, password string) (*encryptedKey, error) {
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(password, salt, scryptN, scryptR, scryptP)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return &encryptedKey{
+		KDF:        "scrypt",
+		N:          scryptN,
+		R:          scryptR,
+		P:          scryptP,
+		Salt:       base64.StdEncoding.EncodeToString(salt),
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		Ciphertext: base64.StdEncoding.EncodeToString(gcm.Seal(nil, nonce, plaintext, nil)),
+	}, nil
+}
+
+func decryptKey(encrypted *encryptedKey, password string) ([]byte, error) {
+	if encrypted.KDF != "scrypt" {
+		return nil, fmt.Errorf("unsupported kdf %q", encrypted.KDF)
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(encrypted.Salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := base64.StdEncoding.DecodeString(encrypted.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(encrypted.Ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(password, salt, encrypted.N, encrypted.R, encrypted.P)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, errors.New("invalid nonce length")
+	}
+
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrInvalidPassword
+	}
+	return plaintext, nil
+}
+
+func newGCM(password string, salt []byte, n, r, p int) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(password), salt, n, r, p, scryptKeyLen)
+	if err != nil {
+		return nil, err
+	}
+
+	blockCipher, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(blockCipher)
+}
@@ -0,0 +1,80 @@
+package wallet_test
+
+import (
+	"crypto/rsa"
+	"errors"
+	"os"
+	"path/filepath"
+	"sender/internal/data/blockchain/wallet"
+	"strings"
+	"testing"
+)
+
+func TestLoadOrCreatePersistsWallet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "node", "wallet.json")
+
+	created, err := wallet.LoadOrCreate(path, "", wallet.RSA)
+	if err != nil {
+		t.Fatalf("LoadOrCreate failed: %v", err)
+	}
+
+	// при повторном запуске ключ должен остаться прежним
+	loaded, err := wallet.LoadOrCreate(path, "", wallet.RSA)
+	if err != nil {
+		t.Fatalf("LoadOrCreate of existing wallet failed: %v", err)
+	}
+	if !loaded.PublicKey.(*rsa.PublicKey).Equal(created.PublicKey) {
+		t.Fatal("Wallet changed between runs")
+	}
+
+	// без пароля файл совместим с WalletSerialize
+	data, _ := os.ReadFile(path)
+	serialized, err := wallet.FromJson(data)
+	if err != nil {
+		t.Fatalf("Failed to read wallet as WalletSerialize: %v", err)
+	}
+	if serialized.PublicKey != created.Serialize().PublicKey {
+		t.Error("Stored public key does not match")
+	}
+}
+
+func TestEncryptedWallet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallet.json")
+	w := wallet.New()
+
+	if err := wallet.Save(path, w, "secret"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), w.Serialize().PrivateKey) {
+		t.Fatal("Private key stored in plain text")
+	}
+
+	if _, err := wallet.Load(path, ""); !errors.Is(err, wallet.ErrPasswordRequired) {
+		t.Errorf("Expected ErrPasswordRequired, got %v", err)
+	}
+	if _, err := wallet.Load(path, "wrong"); !errors.Is(err, wallet.ErrInvalidPassword) {
+		t.Errorf("Expected ErrInvalidPassword, got %v", err)
+	}
+
+	loaded, err := wallet.Load(path, "secret")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !loaded.PrivateKey.(*rsa.PrivateKey).Equal(w.PrivateKey) {
+		t.Fatal("Decrypted private key does not match")
+	}
+}
+
+func TestUnmarshalRejectsMismatchedKeys(t *testing.T) {
+	first := wallet.New().Serialize()
+	second := wallet.New().Serialize()
+
+	mixed := &wallet.WalletSerialize{PublicKey: first.PublicKey, PrivateKey: second.PrivateKey}
+	data, _ := mixed.ToJson()
+
+	if _, err := wallet.Unmarshal(data, ""); !errors.Is(err, wallet.ErrKeyMismatch) {
+		t.Errorf("Expected ErrKeyMismatch, got %v", err)
+	}
+}
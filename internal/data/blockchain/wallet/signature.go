@@ -0,0 +1,185 @@
+package wallet
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// Algorithm is the signature scheme of a wallet and of the transactions it signs
+type Algorithm string
+
+const (
+	// RSA - RSA-2048, PKCS#1 v1.5 over SHA-256. Transactions without an algorithm use it.
+	// The digest is signed without the DigestInfo prefix, as the node always did.
+	RSA Algorithm = "rsa"
+	// Ed25519 - pure Ed25519 over the message
+	Ed25519 Algorithm = "ed25519"
+	// ECDSAP256 - ECDSA on the P-256 curve over SHA-256, ASN.1 DER signature
+	ECDSAP256 Algorithm = "ecdsa-p256"
+)
+
+// DefaultAlgorithm is used for new wallets when no algorithm is configured
+const DefaultAlgorithm = RSA
+
+const rsaKeyBits = 2048
+
+var (
+	ErrUnknownAlgorithm = errors.New("unknown signature algorithm")
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+// Scheme creates keys, signs and verifies messages for a single algorithm
+type Scheme interface {
+	Algorithm() Algorithm
+	GenerateKey() (crypto.Signer, error)
+	Sign(key crypto.Signer, message []byte) ([]byte, error)
+	Verify(key crypto.PublicKey, message []byte, signature []byte) error
+}
+
+var schemes = map[Algorithm]Scheme{
+	RSA:       rsaScheme{},
+	Ed25519:   ed25519Scheme{},
+	ECDSAP256: ecdsaScheme{},
+}
+
+// ParseAlgorithm converts an algorithm name, empty means RSA
+func ParseAlgorithm(name string) (Algorithm, error) {
+	algorithm := Algorithm(name)
+	if algorithm == "" {
+		return RSA, nil
+	}
+	if _, exists := schemes[algorithm]; !exists {
+		return "", fmt.Errorf("%w: %q", ErrUnknownAlgorithm, name)
+	}
+	return algorithm, nil
+}
+
+// SchemeFor returns the implementation of the algorithm, empty means RSA
+func SchemeFor(algorithm Algorithm) (Scheme, error) {
+	algorithm, err := ParseAlgorithm(string(algorithm))
+	if err != nil {
+		return nil, err
+	}
+	return schemes[algorithm], nil
+}
+
+// AlgorithmOf detects the algorithm of a public key
+func AlgorithmOf(key crypto.PublicKey) (Algorithm, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return RSA, nil
+	case ed25519.PublicKey:
+		return Ed25519, nil
+	case *ecdsa.PublicKey:
+		if k.Curve == elliptic.P256() {
+			return ECDSAP256, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %T", ErrUnsupportedKey, key)
+}
+
+// Verify checks the signature of the message with the scheme of the algorithm
+func Verify(algorithm Algorithm, key crypto.PublicKey, message []byte, signature []byte) error {
+	scheme, err := SchemeFor(algorithm)
+	if err != nil {
+		return err
+	}
+	return scheme.Verify(key, message, signature)
+}
+
+type rsaScheme struct{}
+
+func (rsaScheme) Algorithm() Algorithm { return RSA }
+
+func (rsaScheme) GenerateKey() (crypto.Signer, error) {
+	return rsa.GenerateKey(rand.Reader, rsaKeyBits)
+}
+
+func (rsaScheme) Sign(key crypto.Signer, message []byte) ([]byte, error) {
+	privateKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T for %s", ErrUnsupportedKey, key, RSA)
+	}
+
+	hashed := sha256.Sum256(message)
+	return rsa.SignPKCS1v15(rand.Reader, privateKey, 0, hashed[:])
+}
+
+func (rsaScheme) Verify(key crypto.PublicKey, message []byte, signature []byte) error {
+	publicKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("%w: %T for %s", ErrUnsupportedKey, key, RSA)
+	}
+
+	hashed := sha256.Sum256(message)
+	if err := rsa.VerifyPKCS1v15(publicKey, 0, hashed[:], signature); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	return nil
+}
+
+type ed25519Scheme struct{}
+
+func (ed25519Scheme) Algorithm() Algorithm { return Ed25519 }
+
+func (ed25519Scheme) GenerateKey() (crypto.Signer, error) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	return privateKey, err
+}
+
+func (ed25519Scheme) Sign(key crypto.Signer, message []byte) ([]byte, error) {
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T for %s", ErrUnsupportedKey, key, Ed25519)
+	}
+	return ed25519.Sign(privateKey, message), nil
+}
+
+func (ed25519Scheme) Verify(key crypto.PublicKey, message []byte, signature []byte) error {
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return fmt.Errorf("%w: %T for %s", ErrUnsupportedKey, key, Ed25519)
+	}
+	if !ed25519.Verify(publicKey, message, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+type ecdsaScheme struct{}
+
+func (ecdsaScheme) Algorithm() Algorithm { return ECDSAP256 }
+
+func (ecdsaScheme) GenerateKey() (crypto.Signer, error) {
+	return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+}
+
+func (ecdsaScheme) Sign(key crypto.Signer, message []byte) ([]byte, error) {
+	privateKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok || privateKey.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("%w: %T for %s", ErrUnsupportedKey, key, ECDSAP256)
+	}
+
+	hashed := sha256.Sum256(message)
+	return ecdsa.SignASN1(rand.Reader, privateKey, hashed[:])
+}
+
+func (ecdsaScheme) Verify(key crypto.PublicKey, message []byte, signature []byte) error {
+	publicKey, ok := key.(*ecdsa.PublicKey)
+	if !ok || publicKey.Curve != elliptic.P256() {
+		return fmt.Errorf("%w: %T for %s", ErrUnsupportedKey, key, ECDSAP256)
+	}
+
+	hashed := sha256.Sum256(message)
+	if !ecdsa.VerifyASN1(publicKey, hashed[:], signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package wallet_test
+
+import (
+	"errors"
+	"sender/internal/data/blockchain/wallet"
+	"testing"
+)
+
+func TestSchemesSignAndVerify(t *testing.T) {
+	message := []byte("sender:message:5000")
+
+	for _, algorithm := range []wallet.Algorithm{wallet.RSA, wallet.Ed25519, wallet.ECDSAP256} {
+		w, err := wallet.NewWithAlgorithm(algorithm)
+		if err != nil {
+			t.Fatalf("%s: NewWithAlgorithm failed: %v", algorithm, err)
+		}
+		if w.Algorithm != algorithm {
+			t.Errorf("%s: wallet has algorithm %s", algorithm, w.Algorithm)
+		}
+
+		signature, err := w.Sign(message)
+		if err != nil {
+			t.Fatalf("%s: Sign failed: %v", algorithm, err)
+		}
+		if err := w.Verify(message, signature); err != nil {
+			t.Errorf("%s: Verify failed: %v", algorithm, err)
+		}
+		if err := w.Verify([]byte("tampered"), signature); err == nil {
+			t.Errorf("%s: tampered message verified", algorithm)
+		}
+
+		// ключ восстанавливается из сериализованного вида вместе с алгоритмом
+		restored, err := wallet.Deserialize(w.Serialize())
+		if err != nil {
+			t.Fatalf("%s: Deserialize failed: %v", algorithm, err)
+		}
+		if restored.Algorithm != algorithm {
+			t.Errorf("%s: restored wallet has algorithm %s", algorithm, restored.Algorithm)
+		}
+		if err := restored.Verify(message, signature); err != nil {
+			t.Errorf("%s: restored wallet failed to verify: %v", algorithm, err)
+		}
+	}
+}
+
+func TestVerifyWithWrongKeyType(t *testing.T) {
+	signer, _ := wallet.NewWithAlgorithm(wallet.Ed25519)
+	other, _ := wallet.NewWithAlgorithm(wallet.ECDSAP256)
+
+	signature, _ := signer.Sign([]byte("message"))
+	err := wallet.Verify(wallet.Ed25519, other.PublicKey, []byte("message"), signature)
+	if !errors.Is(err, wallet.ErrUnsupportedKey) {
+		t.Errorf("Expected ErrUnsupportedKey, got %v", err)
+	}
+}
+
+func TestParseAlgorithm(t *testing.T) {
+	if algorithm, err := wallet.ParseAlgorithm(""); err != nil || algorithm != wallet.RSA {
+		t.Errorf("Expected rsa by default, got %q, %v", algorithm, err)
+	}
+	if _, err := wallet.ParseAlgorithm("dsa"); !errors.Is(err, wallet.ErrUnknownAlgorithm) {
+		t.Errorf("Expected ErrUnknownAlgorithm, got %v", err)
+	}
+}
+
+func TestExportNonRSAKeys(t *testing.T) {
+	w, _ := wallet.NewWithAlgorithm(wallet.Ed25519)
+
+	exported, err := w.Export(wallet.FormatPKCS8)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	imported, err := wallet.Import(exported.PublicKey, exported.PrivateKey)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if imported.Algorithm != wallet.Ed25519 {
+		t.Errorf("Expected ed25519, got %s", imported.Algorithm)
+	}
+
+	// PKCS#1 существует только для RSA
+	if _, err := w.Export(wallet.FormatPKCS1); !errors.Is(err, wallet.ErrUnsupportedKey) {
+		t.Errorf("Expected ErrUnsupportedKey, got %v", err)
+	}
+}
@@ -1,73 +1,110 @@
 package wallet
 
 import (
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/x509"
-	"encoding/base64"
+	"crypto"
 	"log"
 )
 
 type Wallet struct {
-	PublicKey  *rsa.PublicKey
-	PrivateKey *rsa.PrivateKey
+	Algorithm  Algorithm
+	PublicKey  crypto.PublicKey
+	PrivateKey crypto.Signer
 }
 
+// New creates an RSA wallet
 func New() *Wallet {
-	// Генерация приватного ключа
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	w, err := NewWithAlgorithm(RSA)
 	if err != nil {
 		log.Fatalf("Не удалось сгенерировать приватный ключ: %v", err)
 	}
-	log.Println("Приватный ключ успешно сгенерирован.")
+	return w
+}
 
-	// Получение публичного ключа из приватного
-	publicKey := &privateKey.PublicKey
-	log.Println("Публичный ключ успешно сгенерирован из приватного ключа.")
+// NewWithAlgorithm creates a wallet for the given signature scheme
+func NewWithAlgorithm(algorithm Algorithm) (*Wallet, error) {
+	scheme, err := SchemeFor(algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	// Генерация приватного ключа
+	privateKey, err := scheme.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Приватный ключ %s успешно сгенерирован.", scheme.Algorithm())
 
 	return &Wallet{
-		PublicKey:  publicKey,
+		Algorithm:  scheme.Algorithm(),
+		PublicKey:  privateKey.Public(),
 		PrivateKey: privateKey,
-	}
+	}, nil
 }
 
-func (w *Wallet) Sereliaze() *WalletSerialize {
-	publicKeyPKCS := x509.MarshalPKCS1PublicKey(w.PublicKey)
-	privateKeyPKCS := x509.MarshalPKCS1PrivateKey(w.PrivateKey)
-
-	publicKeyBase64 := base64.RawStdEncoding.EncodeToString(publicKeyPKCS)
-	privateKeyBase64 := base64.RawStdEncoding.EncodeToString(privateKeyPKCS)
+// fromKeys builds a wallet and checks that both keys belong together
+func fromKeys(publicKey crypto.PublicKey, privateKey crypto.Signer) (*Wallet, error) {
+	algorithm, err := AlgorithmOf(privateKey.Public())
+	if err != nil {
+		return nil, err
+	}
 
-	return &WalletSerialize{
-		PublicKey:  publicKeyBase64,
-		PrivateKey: privateKeyBase64,
+	if publicKey == nil {
+		publicKey = privateKey.Public()
+	}
+	if !publicKeysEqual(publicKey, privateKey.Public()) {
+		return nil, ErrKeyMismatch
 	}
+
+	return &Wallet{
+		Algorithm:  algorithm,
+		PublicKey:  publicKey,
+		PrivateKey: privateKey,
+	}, nil
 }
 
-func Deserialize(serialized *WalletSerialize) (*Wallet, error) {
-	// Декодирование публичного ключа из base64
-	publicKeyBytes, err := base64.RawStdEncoding.DecodeString(serialized.PublicKey)
-	if err != nil {
-		return nil, err
-	}
-	publicKey, err := x509.ParsePKCS1PublicKey(publicKeyBytes)
+// Sign signs the message with the scheme of the wallet
+func (w *Wallet) Sign(message []byte) ([]byte, error) {
+	scheme, err := SchemeFor(w.Algorithm)
 	if err != nil {
 		return nil, err
 	}
+	return scheme.Sign(w.PrivateKey, message)
+}
+
+// Verify checks a signature made by this wallet
+func (w *Wallet) Verify(message []byte, signature []byte) error {
+	return Verify(w.Algorithm, w.PublicKey, message, signature)
+}
+
+func publicKeysEqual(a, b crypto.PublicKey) bool {
+	comparable, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && comparable.Equal(b)
+}
 
-	// Декодирование приватного ключа из base64
-	privateKeyBytes, err := base64.RawStdEncoding.DecodeString(serialized.PrivateKey)
+// Sereliaze is the original (misspelled) name of Serialize
+func (w *Wallet) Sereliaze() *WalletSerialize {
+	return w.Serialize()
+}
+
+// Serialize encodes both keys as base64 DER, PKCS#1 for RSA wallets
+// and PKIX / PKCS#8 for the others
+func (w *Wallet) Serialize() *WalletSerialize {
+	serialized, _ := w.Export(FormatRaw)
+	return serialized
+}
+
+// Deserialize restores a wallet, the keys may be in any supported format
+func Deserialize(serialized *WalletSerialize) (*Wallet, error) {
+	publicKey, err := ParsePublicKey(serialized.PublicKey)
 	if err != nil {
 		return nil, err
 	}
-	privateKey, err := x509.ParsePKCS1PrivateKey(privateKeyBytes)
+
+	privateKey, err := ParsePrivateKey(serialized.PrivateKey)
 	if err != nil {
 		return nil, err
 	}
 
 	// Восстановление объекта Wallet
-	return &Wallet{
-		PublicKey:  publicKey,
-		PrivateKey: privateKey,
-	}, nil
+	return fromKeys(publicKey, privateKey)
 }
@@ -2,6 +2,7 @@ package wallet_test
 
 import (
 	"bytes"
+	"crypto/rsa"
 	"sender/internal/data/blockchain/wallet"
 	"testing"
 )
@@ -20,12 +21,12 @@ func TestWalletSerializationDeserialization(t *testing.T) {
 	}
 
 	// Проверяем равенство публичных ключей
-	if deserializedWallet.PublicKey.N.Cmp(w.PublicKey.N) != 0 {
+	if !deserializedWallet.PublicKey.(*rsa.PublicKey).Equal(w.PublicKey) {
 		t.Fatal("Deserialized wallet public key does not match the original")
 	}
 
 	// Проверяем равенство приватных ключей
-	if deserializedWallet.PrivateKey.D.Cmp(w.PrivateKey.D) != 0 {
+	if !deserializedWallet.PrivateKey.(*rsa.PrivateKey).Equal(w.PrivateKey) {
 		t.Fatal("Deserialized wallet private key does not match the original")
 	}
 }
@@ -1,6 +1,7 @@
 package deal
 
 import (
+	"sender/internal/data/decimal"
 	"sender/internal/data/order"
 	"sender/internal/jsonutil"
 )
@@ -14,6 +15,14 @@ type Deal struct {
 	LastStatusChange string       `json:"lastStatusChange"`
 }
 
+// Amount returns the exact transfer amount of the deal, the total of the buy order
+func (d *Deal) Amount() decimal.Decimal {
+	if d.BuyOrder == nil {
+		return decimal.Decimal{}
+	}
+	return d.BuyOrder.Total()
+}
+
 func (d *Deal) ToJson() ([]byte, error) {
 	return jsonutil.ToJSON(d)
 }
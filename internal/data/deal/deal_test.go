@@ -2,6 +2,7 @@ package deal_test
 
 import (
 	"sender/internal/data/deal"
+	"sender/internal/data/decimal"
 	"sender/internal/data/order"
 	"strings"
 	"testing"
@@ -14,8 +15,8 @@ func TestDealToJson(t *testing.T) {
 		UserHashPublicKey:  "buyer_public_key",
 		CryptocurrencyCode: "BTC",
 		TypeName:           "buy",
-		UnitPrice:          50000.5,
-		Quantity:           1.0,
+		UnitPrice:          decimal.MustParse("50000.5"),
+		Quantity:           decimal.MustParse("1.0"),
 		CreatedAt:          "2023-01-01T10:00:00Z",
 		LastStatusChange:   "2023-01-01T10:05:00Z",
 	}
@@ -25,8 +26,8 @@ func TestDealToJson(t *testing.T) {
 		UserHashPublicKey:  "seller_public_key",
 		CryptocurrencyCode: "BTC",
 		TypeName:           "sell",
-		UnitPrice:          50000.5,
-		Quantity:           1.0,
+		UnitPrice:          decimal.MustParse("50000.5"),
+		Quantity:           decimal.MustParse("1.0"),
 		CreatedAt:          "2023-01-01T09:50:00Z",
 		LastStatusChange:   "2023-01-01T10:00:00Z",
 	}
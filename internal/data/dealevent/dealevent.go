@@ -0,0 +1,147 @@
+package dealevent
+
+import (
+	"encoding/json"
+	"errors"
+	"sender/internal/data/blockchain/block"
+	"sender/internal/data/blockchain/transaction"
+	"sender/internal/data/validation"
+	"time"
+)
+
+// Kind is the type of event published for a deal
+type Kind string
+
+const (
+	// Confirmed - the deal transaction reached the confirmation depth
+	Confirmed Kind = "DealConfirmed"
+	// Reverted - a confirmed deal transaction dropped out of the best chain
+	Reverted Kind = "DealReverted"
+	// Failed - the deal transaction was not included in a block in time
+	Failed Kind = "DealFailed"
+	// Rejected - the deal read from kafka could not be parsed or is invalid, nothing was signed
+	Rejected Kind = "DealRejected"
+)
+
+// Event describes a change of a deal state on the chain
+type Event struct {
+	Kind          Kind
+	Transaction   transaction.Transaction
+	Block         *block.Block
+	BlockHash     string
+	Confirmations int
+	Reason        string
+	CreatedAt     time.Time
+	// ID of the inbound GoGetDeal message the deal came from
+	CorrelationID string
+	// Set for rejected deals only
+	DealID int
+	Errors []validation.FieldError
+}
+
+// FromBlock creates an event of the given kind for every transaction in the block
+func FromBlock(kind Kind, b *block.Block, blockHash string, confirmations int) []Event {
+	now := time.Now().UTC()
+
+	events := make([]Event, 0, len(b.Transactions))
+	for _, tr := range b.Transactions {
+		events = append(events, Event{
+			Kind:          kind,
+			Transaction:   tr,
+			Block:         b,
+			BlockHash:     blockHash,
+			Confirmations: confirmations,
+			CreatedAt:     now,
+		})
+	}
+	return events
+}
+
+// NewFailed creates an event for a transaction that could not be settled
+func NewFailed(tx transaction.Transaction, reason string) Event {
+	return Event{
+		Kind:        Failed,
+		Transaction: tx,
+		Reason:      reason,
+		CreatedAt:   time.Now().UTC(),
+	}
+}
+
+// NewRejected creates an event for a deal message that was not signed.
+// The message is kept as is, dealID is 0 if the message could not be parsed.
+func NewRejected(dealID int, message []byte, err error) Event {
+	event := Event{
+		Kind:        Rejected,
+		Transaction: transaction.Transaction{DealMessage: string(message)},
+		Reason:      err.Error(),
+		CreatedAt:   time.Now().UTC(),
+		DealID:      dealID,
+	}
+
+	var fieldErrors validation.Errors
+	if errors.As(err, &fieldErrors) {
+		event.Errors = fieldErrors
+	}
+	return event
+}
+
+// statusPayload is the legacy message published when a deal is reverted, failed or rejected
+type statusPayload struct {
+	Type      Kind            `json:"type"`
+	Deal      json.RawMessage `json:"deal"`
+	BlockID   int             `json:"block_id,omitempty"`
+	BlockHash string          `json:"block_hash,omitempty"`
+	Signature string          `json:"signature"`
+	// Stable key to deduplicate events of the same transaction
+	TransactionID string                  `json:"transaction_id,omitempty"`
+	Reason        string                  `json:"reason,omitempty"`
+	DealID        int                     `json:"deal_id,omitempty"`
+	Errors        []validation.FieldError `json:"errors,omitempty"`
+}
+
+// Payload returns the kafka message value for the event: the versioned
+// envelope, or the legacy payload if legacy payloads are enabled.
+func (e *Event) Payload() ([]byte, error) {
+	if LegacyPayloads() {
+		return e.legacyPayload()
+	}
+	return json.Marshal(e.envelope())
+}
+
+// legacyPayload publishes confirmed deals as the raw deal message and the
+// other events as statusPayload, as before the envelope was introduced
+func (e *Event) legacyPayload() ([]byte, error) {
+	if e.Kind == Confirmed {
+		return []byte(e.Transaction.DealMessage), nil
+	}
+
+	dealJson := e.dealJson()
+
+	payload := statusPayload{
+		Type:      e.Kind,
+		Deal:      dealJson,
+		BlockHash: e.BlockHash,
+		Signature: e.Transaction.Signature,
+		Reason:    e.Reason,
+		DealID:    e.DealID,
+		Errors:    e.Errors,
+	}
+	// у отклоненной сделки нет подписанной транзакции
+	if e.Kind != Rejected {
+		payload.TransactionID, _ = e.Transaction.ID()
+	}
+	if e.Block != nil {
+		payload.BlockID = e.Block.ID
+	}
+
+	return json.Marshal(payload)
+}
+
+// dealJson returns the deal message as JSON, a message that is not JSON becomes a string
+func (e *Event) dealJson() json.RawMessage {
+	dealJson := json.RawMessage(e.Transaction.DealMessage)
+	if !json.Valid(dealJson) {
+		dealJson, _ = json.Marshal(e.Transaction.DealMessage)
+	}
+	return dealJson
+}
@@ -0,0 +1,144 @@
+package dealevent_test
+
+import (
+	"encoding/json"
+	"errors"
+	"sender/internal/data/blockchain/block"
+	"sender/internal/data/blockchain/transaction"
+	"sender/internal/data/dealevent"
+	"sender/internal/data/decimal"
+	"sender/internal/data/validation"
+	"testing"
+	"time"
+)
+
+func TestFromBlockAndLegacyPayload(t *testing.T) {
+	dealevent.SetLegacyPayloads(true)
+	defer dealevent.SetLegacyPayloads(false)
+
+	b := &block.Block{
+		ID: 7,
+		Transactions: []transaction.Transaction{
+			{DealMessage: `{"id":1}`, Signature: "sig-1"},
+			{DealMessage: `{"id":2}`, Signature: "sig-2"},
+		},
+	}
+
+	events := dealevent.FromBlock(dealevent.Confirmed, b, "hash", 3)
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+
+	payload, err := events[0].Payload()
+	if err != nil {
+		t.Fatalf("Payload failed: %v", err)
+	}
+	if string(payload) != `{"id":1}` {
+		t.Errorf("Expected raw deal message, got %s", payload)
+	}
+
+	reverted := dealevent.FromBlock(dealevent.Reverted, b, "hash", 0)
+	payload, err = reverted[1].Payload()
+	if err != nil {
+		t.Fatalf("Payload failed: %v", err)
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(payload, &result); err != nil {
+		t.Fatalf("Reverted payload is not JSON: %v", err)
+	}
+	if result["type"] != string(dealevent.Reverted) || result["block_hash"] != "hash" || result["signature"] != "sig-2" {
+		t.Errorf("Unexpected reverted payload: %s", payload)
+	}
+	if deal, ok := result["deal"].(map[string]interface{}); !ok || deal["id"] != float64(2) {
+		t.Errorf("Expected deal to be embedded as JSON, got %v", result["deal"])
+	}
+}
+
+func TestRejectedPayload(t *testing.T) {
+	reason := validation.Errors{{Field: "buyOrder", Message: "is missing"}}
+	event := dealevent.NewRejected(5, []byte(`{"id":5}`), reason)
+
+	payload, err := event.Payload()
+	if err != nil {
+		t.Fatalf("Payload failed: %v", err)
+	}
+
+	var result struct {
+		Type          string                  `json:"type"`
+		DealID        int                     `json:"deal_id"`
+		Deal          map[string]any          `json:"deal"`
+		TransactionID string                  `json:"transaction_id"`
+		Reason        string                  `json:"reason"`
+		Errors        []validation.FieldError `json:"errors"`
+	}
+	if err := json.Unmarshal(payload, &result); err != nil {
+		t.Fatalf("Rejected payload is not JSON: %v", err)
+	}
+	if result.Type != string(dealevent.Rejected) || result.DealID != 5 || result.Deal["id"] != float64(5) {
+		t.Errorf("Unexpected rejected payload: %s", payload)
+	}
+	if result.TransactionID != "" || result.Reason != reason.Error() {
+		t.Errorf("Unexpected rejected payload: %s", payload)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Field != "buyOrder" {
+		t.Errorf("Expected field errors, got %v", result.Errors)
+	}
+
+	// нечитаемое сообщение передается строкой
+	unparsed := dealevent.NewRejected(0, []byte("{broken"), errors.New("deal read error"))
+	payload, _ = unparsed.Payload()
+	var raw map[string]any
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		t.Fatalf("Rejected payload is not JSON: %v", err)
+	}
+	if raw["deal"] != "{broken" {
+		t.Errorf("Expected raw message as string, got %v", raw["deal"])
+	}
+}
+
+func TestEnvelopePayload(t *testing.T) {
+	created := time.UnixMilli(1735732800000)
+	tx := transaction.Transaction{
+		Version:     transaction.CurrentVersion,
+		Sender:      "sender-key",
+		DealMessage: `{"id":3}`,
+		Transfer:    decimal.MustParse("15.075"),
+		CreatedAt:   created.UnixMilli(),
+		Signature:   "sig-3",
+	}
+	b := &block.Block{ID: 9, TimeCreated: block.NewTimestamp(time.Unix(1735732860, 0)), PreviousHash: "previous", Transactions: []transaction.Transaction{tx}}
+
+	event := dealevent.FromBlock(dealevent.Confirmed, b, "hash", 6)[0]
+	payload, err := event.Payload()
+	if err != nil {
+		t.Fatalf("Payload failed: %v", err)
+	}
+
+	var envelope dealevent.Envelope
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		t.Fatalf("Envelope is not JSON: %v", err)
+	}
+	if envelope.SchemaVersion != dealevent.SchemaVersion || envelope.Type != dealevent.Confirmed || envelope.DealID != 3 {
+		t.Errorf("Unexpected envelope header: %s", payload)
+	}
+	if string(envelope.Deal) != `{"id":3}` || envelope.Confirmations != 6 {
+		t.Errorf("Unexpected envelope: %s", payload)
+	}
+
+	transactionID, _ := tx.ID()
+	info := envelope.Transaction
+	if info == nil || info.ID != transactionID || info.Signature != "sig-3" || info.Sender != "sender-key" {
+		t.Fatalf("Unexpected transaction info: %s", payload)
+	}
+	if !info.Transfer.Equal(tx.Transfer) || info.CreatedAt == nil || !info.CreatedAt.Equal(created) {
+		t.Errorf("Unexpected transaction info: %s", payload)
+	}
+
+	if envelope.Block == nil || envelope.Block.ID != 9 || envelope.Block.Hash != "hash" || envelope.Block.PreviousHash != "previous" {
+		t.Fatalf("Unexpected block info: %s", payload)
+	}
+	if !envelope.Block.CreatedAt.Equal(time.Unix(1735732860, 0)) {
+		t.Errorf("Unexpected block time: %s", envelope.Block.CreatedAt)
+	}
+}
@@ -0,0 +1,116 @@
+package dealevent
+
+import (
+	"encoding/json"
+	"sender/internal/data/blockchain/wallet"
+	"sender/internal/data/decimal"
+	"sender/internal/data/validation"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// LegacySchemaVersion - raw deal message for confirmed deals, statusPayload for the rest
+	LegacySchemaVersion = 1
+	// SchemaVersion - every event is an Envelope
+	SchemaVersion = 2
+)
+
+var legacyPayloads atomic.Bool
+
+// SetLegacyPayloads makes Payload return the legacy payloads for consumers
+// that do not understand the envelope yet. It is disabled by default.
+func SetLegacyPayloads(legacy bool) {
+	legacyPayloads.Store(legacy)
+}
+
+// LegacyPayloads reports whether legacy payloads are published
+func LegacyPayloads() bool {
+	return legacyPayloads.Load()
+}
+
+// PayloadVersion returns the schema version of the payloads currently published
+func PayloadVersion() int {
+	if LegacyPayloads() {
+		return LegacySchemaVersion
+	}
+	return SchemaVersion
+}
+
+// Envelope is the event published on SpringGetDeal
+type Envelope struct {
+	SchemaVersion int             `json:"schema_version"`
+	Type          Kind            `json:"type"`
+	DealID        int             `json:"deal_id,omitempty"`
+	Deal          json.RawMessage `json:"deal"`
+	// Missing for rejected deals, nothing was signed
+	Transaction *TransactionInfo `json:"transaction,omitempty"`
+	// Missing for failed and rejected deals
+	Block         *BlockInfo              `json:"block,omitempty"`
+	Confirmations int                     `json:"confirmations"`
+	Reason        string                  `json:"reason,omitempty"`
+	Errors        []validation.FieldError `json:"errors,omitempty"`
+	CorrelationID string                  `json:"correlation_id,omitempty"`
+	// When the event happened
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TransactionInfo describes the signed deal transaction
+type TransactionInfo struct {
+	ID        string           `json:"id,omitempty"`
+	Signature string           `json:"signature"`
+	Sender    string           `json:"sender"`
+	Algorithm wallet.Algorithm `json:"algorithm,omitempty"`
+	Transfer  decimal.Decimal  `json:"transfer"`
+	// Zero for transactions signed before version 2
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+// BlockInfo describes the block the transaction is in
+type BlockInfo struct {
+	ID           int       `json:"id"`
+	Hash         string    `json:"hash"`
+	PreviousHash string    `json:"previous_hash"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (e *Event) envelope() Envelope {
+	envelope := Envelope{
+		SchemaVersion: SchemaVersion,
+		Type:          e.Kind,
+		DealID:        e.dealID(),
+		Deal:          e.dealJson(),
+		Confirmations: e.Confirmations,
+		Reason:        e.Reason,
+		Errors:        e.Errors,
+		CorrelationID: e.CorrelationID,
+		CreatedAt:     e.CreatedAt,
+	}
+
+	if e.Kind != Rejected {
+		tx := &e.Transaction
+		info := &TransactionInfo{
+			Signature: tx.Signature,
+			Sender:    tx.Sender,
+			Algorithm: tx.Algorithm,
+			Transfer:  tx.Transfer,
+		}
+		info.ID, _ = tx.ID()
+		if created := tx.CreatedTime(); !created.IsZero() {
+			created = created.UTC()
+			info.CreatedAt = &created
+		}
+		envelope.Transaction = info
+	}
+
+	if e.Block != nil {
+		created, _ := e.Block.TimeCreated.Time()
+		envelope.Block = &BlockInfo{
+			ID:           e.Block.ID,
+			Hash:         e.BlockHash,
+			PreviousHash: e.Block.PreviousHash,
+			CreatedAt:    created.UTC(),
+		}
+	}
+	return envelope
+}
@@ -0,0 +1,67 @@
+package dealevent
+
+import (
+	"sender/internal/data/deal"
+	"strconv"
+)
+
+// Kafka headers attached to every published event
+const (
+	HeaderEventType     = "event-type"
+	HeaderDealID        = "deal-id"
+	HeaderTransactionID = "transaction-id"
+	HeaderBlockHeight   = "block-height"
+	HeaderBlockHash     = "block-hash"
+	HeaderSchemaVersion = "schema-version"
+	// Carried from the inbound GoGetDeal message, see Event.CorrelationID
+	HeaderCorrelationID = "correlation-id"
+)
+
+// Key returns the kafka message key: the deal ID, so all events of a deal
+// go to the same partition in order. It is empty if the deal is unknown.
+func (e *Event) Key() string {
+	if dealID := e.dealID(); dealID != 0 {
+		return strconv.Itoa(dealID)
+	}
+	return ""
+}
+
+// Headers returns the kafka headers of the event, empty values are omitted
+func (e *Event) Headers() map[string]string {
+	headers := map[string]string{
+		HeaderEventType:     string(e.Kind),
+		HeaderSchemaVersion: strconv.Itoa(PayloadVersion()),
+	}
+
+	if key := e.Key(); key != "" {
+		headers[HeaderDealID] = key
+	}
+	if e.Kind != Rejected {
+		if id, err := e.Transaction.ID(); err == nil {
+			headers[HeaderTransactionID] = id
+		}
+	}
+	if e.Block != nil {
+		headers[HeaderBlockHeight] = strconv.Itoa(e.Block.ID)
+	}
+	if e.BlockHash != "" {
+		headers[HeaderBlockHash] = e.BlockHash
+	}
+	if e.CorrelationID != "" {
+		headers[HeaderCorrelationID] = e.CorrelationID
+	}
+	return headers
+}
+
+// dealID returns the ID of the deal, read from the signed deal message if needed
+func (e *Event) dealID() int {
+	if e.DealID != 0 {
+		return e.DealID
+	}
+
+	signedDeal, err := deal.FromJson([]byte(e.Transaction.DealMessage))
+	if err != nil {
+		return 0
+	}
+	return signedDeal.ID
+}
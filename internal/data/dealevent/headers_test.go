@@ -0,0 +1,68 @@
+package dealevent_test
+
+import (
+	"errors"
+	"sender/internal/data/blockchain/block"
+	"sender/internal/data/blockchain/transaction"
+	"sender/internal/data/dealevent"
+	"strconv"
+	"testing"
+)
+
+func TestKeyAndHeaders(t *testing.T) {
+	tx := transaction.Transaction{Version: transaction.CurrentVersion, DealMessage: `{"id":42}`, Signature: "sig"}
+	b := &block.Block{ID: 7, Transactions: []transaction.Transaction{tx}}
+
+	event := dealevent.FromBlock(dealevent.Confirmed, b, "block-hash", 3)[0]
+	event.CorrelationID = "GoGetDeal-0-15"
+
+	if key := event.Key(); key != "42" {
+		t.Errorf("Expected deal ID as key, got %q", key)
+	}
+
+	transactionID, _ := tx.ID()
+	expected := map[string]string{
+		dealevent.HeaderEventType:     string(dealevent.Confirmed),
+		dealevent.HeaderDealID:        "42",
+		dealevent.HeaderTransactionID: transactionID,
+		dealevent.HeaderBlockHeight:   "7",
+		dealevent.HeaderBlockHash:     "block-hash",
+		dealevent.HeaderSchemaVersion: strconv.Itoa(dealevent.SchemaVersion),
+		dealevent.HeaderCorrelationID: "GoGetDeal-0-15",
+	}
+	headers := event.Headers()
+	for name, value := range expected {
+		if headers[name] != value {
+			t.Errorf("Header %s = %q, want %q", name, headers[name], value)
+		}
+	}
+}
+
+func TestRejectedKey(t *testing.T) {
+	rejected := dealevent.NewRejected(5, []byte(`{"id":5}`), errors.New("invalid"))
+	if rejected.Key() != "5" {
+		t.Errorf("Expected key 5, got %q", rejected.Key())
+	}
+	if _, exists := rejected.Headers()[dealevent.HeaderTransactionID]; exists {
+		t.Error("Rejected deal has no transaction")
+	}
+
+	// нечитаемое сообщение публикуется без ключа
+	unparsed := dealevent.NewRejected(0, []byte("{broken"), errors.New("deal read error"))
+	if unparsed.Key() != "" {
+		t.Errorf("Expected empty key, got %q", unparsed.Key())
+	}
+}
+
+func TestSchemaVersionHeader(t *testing.T) {
+	event := dealevent.NewRejected(5, []byte(`{"id":5}`), errors.New("invalid"))
+	if version := event.Headers()[dealevent.HeaderSchemaVersion]; version != "2" {
+		t.Errorf("Expected envelope schema version, got %s", version)
+	}
+
+	dealevent.SetLegacyPayloads(true)
+	defer dealevent.SetLegacyPayloads(false)
+	if version := event.Headers()[dealevent.HeaderSchemaVersion]; version != "1" {
+		t.Errorf("Expected legacy schema version, got %s", version)
+	}
+}
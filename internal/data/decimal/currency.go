@@ -0,0 +1,28 @@
+package decimal
+
+import "strings"
+
+// DefaultScale is used for currencies missing from the table
+const DefaultScale int32 = 8
+
+// Number of digits after the point each currency supports
+var currencyScales = map[string]int32{
+	"BTC":  8,
+	"LTC":  8,
+	"ETH":  18,
+	"TON":  9,
+	"SOL":  9,
+	"USDT": 6,
+	"USDC": 6,
+	"USD":  2,
+	"EUR":  2,
+	"RUB":  2,
+}
+
+// ScaleOf returns the number of digits after the point supported by the currency
+func ScaleOf(currencyCode string) int32 {
+	if scale, exists := currencyScales[strings.ToUpper(currencyCode)]; exists {
+		return scale
+	}
+	return DefaultScale
+}
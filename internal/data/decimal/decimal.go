@@ -0,0 +1,274 @@
+package decimal
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// maxExponent limits exponents like 1e100000 that would allocate huge numbers
+const maxExponent = 1000
+
+var ErrInvalidDecimal = errors.New("invalid decimal")
+
+var ten = big.NewInt(10)
+
+// Decimal is an exact fixed-point number: coef * 10^-scale.
+// The zero value is 0. Values are immutable, every operation returns a new Decimal.
+type Decimal struct {
+	// nil means zero, never modified after construction
+	coef  *big.Int
+	scale int32
+}
+
+// New creates coef * 10^-scale
+func New(coef int64, scale int32) Decimal {
+	return newDecimal(big.NewInt(coef), scale)
+}
+
+func newDecimal(coef *big.Int, scale int32) Decimal {
+	if scale < 0 {
+		coef = new(big.Int).Mul(coef, pow10(-scale))
+		scale = 0
+	}
+	return Decimal{coef: coef, scale: scale}
+}
+
+// Parse reads a decimal like "150.75", "-0.1", "1.5E-7" or "50000"
+func Parse(value string) (Decimal, error) {
+	s := strings.TrimSpace(value)
+	if s == "" {
+		return Decimal{}, fmt.Errorf("%w: empty string", ErrInvalidDecimal)
+	}
+
+	exponent := int64(0)
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		var err error
+		exponent, err = strconv.ParseInt(s[i+1:], 10, 32)
+		if err != nil || exponent > maxExponent || exponent < -maxExponent {
+			return Decimal{}, fmt.Errorf("%w: %q", ErrInvalidDecimal, value)
+		}
+		s = s[:i]
+	}
+
+	sign := ""
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		sign, s = s[:1], s[1:]
+	}
+
+	integer, fraction, _ := strings.Cut(s, ".")
+	if integer == "" && fraction == "" || !isDigits(integer) || !isDigits(fraction) {
+		return Decimal{}, fmt.Errorf("%w: %q", ErrInvalidDecimal, value)
+	}
+
+	coef, ok := new(big.Int).SetString(sign+integer+fraction, 10)
+	if !ok {
+		return Decimal{}, fmt.Errorf("%w: %q", ErrInvalidDecimal, value)
+	}
+	return newDecimal(coef, int32(int64(len(fraction))-exponent)), nil
+}
+
+// MustParse is Parse that panics on error, for constants and tests
+func MustParse(value string) Decimal {
+	d, err := Parse(value)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// FromFloat converts a float using its shortest decimal representation,
+// so 0.1 becomes exactly 0.1
+func FromFloat(value float64) (Decimal, error) {
+	return Parse(strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+// Scale returns the number of digits after the decimal point
+func (d Decimal) Scale() int32 {
+	return d.scale
+}
+
+// Sign returns -1, 0 or 1
+func (d Decimal) Sign() int {
+	if d.coef == nil {
+		return 0
+	}
+	return d.coef.Sign()
+}
+
+// IsZero reports whether the value is 0
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+// Cmp compares the values regardless of scale
+func (d Decimal) Cmp(other Decimal) int {
+	a, b := align(d, other)
+	return a.Cmp(b)
+}
+
+// Equal reports whether both values are numerically equal (1.50 == 1.5)
+func (d Decimal) Equal(other Decimal) bool {
+	return d.Cmp(other) == 0
+}
+
+// Add returns d + other
+func (d Decimal) Add(other Decimal) Decimal {
+	a, b := align(d, other)
+	return Decimal{coef: new(big.Int).Add(a, b), scale: max(d.scale, other.scale)}
+}
+
+// Sub returns d - other
+func (d Decimal) Sub(other Decimal) Decimal {
+	a, b := align(d, other)
+	return Decimal{coef: new(big.Int).Sub(a, b), scale: max(d.scale, other.scale)}
+}
+
+// Mul returns the exact product, its scale is the sum of both scales
+func (d Decimal) Mul(other Decimal) Decimal {
+	return Decimal{coef: new(big.Int).Mul(d.bigCoef(), other.bigCoef()), scale: d.scale + other.scale}
+}
+
+// Round rounds half away from zero to the given number of digits after the point
+func (d Decimal) Round(scale int32) Decimal {
+	if scale < 0 {
+		scale = 0
+	}
+	if scale >= d.scale {
+		return d.Rescale(scale)
+	}
+
+	divisor := pow10(d.scale - scale)
+	quotient, remainder := new(big.Int).QuoRem(d.bigCoef(), divisor, new(big.Int))
+
+	// |remainder| * 2 >= divisor -> округляем от нуля
+	remainder.Abs(remainder).Lsh(remainder, 1)
+	if remainder.Cmp(divisor) >= 0 {
+		if d.Sign() < 0 {
+			quotient.Sub(quotient, big.NewInt(1))
+		} else {
+			quotient.Add(quotient, big.NewInt(1))
+		}
+	}
+	return Decimal{coef: quotient, scale: scale}
+}
+
+// Rescale adds trailing zeros up to the given scale, it never drops digits
+func (d Decimal) Rescale(scale int32) Decimal {
+	if scale <= d.scale {
+		return d
+	}
+	return Decimal{coef: new(big.Int).Mul(d.bigCoef(), pow10(scale-d.scale)), scale: scale}
+}
+
+// Normalize removes trailing zeros after the point (1.500 -> 1.5)
+func (d Decimal) Normalize() Decimal {
+	coef := d.bigCoef()
+	scale := d.scale
+	if coef.Sign() == 0 {
+		return Decimal{}
+	}
+
+	coef = new(big.Int).Set(coef)
+	remainder := new(big.Int)
+	for scale > 0 {
+		quotient, mod := new(big.Int).QuoRem(coef, ten, remainder)
+		if mod.Sign() != 0 {
+			break
+		}
+		coef = quotient
+		scale--
+	}
+	return Decimal{coef: coef, scale: scale}
+}
+
+// FitsScale reports whether the value has no more than scale significant digits after the point
+func (d Decimal) FitsScale(scale int32) bool {
+	return d.Normalize().scale <= scale
+}
+
+// String returns the plain notation keeping the scale ("5000.00")
+func (d Decimal) String() string {
+	digits := new(big.Int).Abs(d.bigCoef()).String()
+	sign := ""
+	if d.Sign() < 0 {
+		sign = "-"
+	}
+
+	if d.scale == 0 {
+		return sign + digits
+	}
+	if pad := int(d.scale) + 1 - len(digits); pad > 0 {
+		digits = strings.Repeat("0", pad) + digits
+	}
+	point := len(digits) - int(d.scale)
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+// Canonical returns the normalized plain notation, the same for equal values
+func (d Decimal) Canonical() string {
+	return d.Normalize().String()
+}
+
+// Float64 returns the nearest float, for legacy code only
+func (d Decimal) Float64() float64 {
+	value, _ := strconv.ParseFloat(d.String(), 64)
+	return value
+}
+
+// MarshalJSON writes the value as a JSON number in canonical form
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.Canonical()), nil
+}
+
+// UnmarshalJSON reads a JSON number or a string with a number, null is 0
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		*d = Decimal{}
+		return nil
+	}
+
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		unquoted, err := strconv.Unquote(s)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidDecimal, s)
+		}
+		s = unquoted
+	}
+
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+func (d Decimal) bigCoef() *big.Int {
+	if d.coef == nil {
+		return new(big.Int)
+	}
+	return d.coef
+}
+
+// align returns both coefficients at the larger scale
+func align(a, b Decimal) (*big.Int, *big.Int) {
+	scale := max(a.scale, b.scale)
+	return a.Rescale(scale).bigCoef(), b.Rescale(scale).bigCoef()
+}
+
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(ten, big.NewInt(int64(n)), nil)
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
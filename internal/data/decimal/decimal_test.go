@@ -0,0 +1,106 @@
+package decimal_test
+
+import (
+	"encoding/json"
+	"errors"
+	"sender/internal/data/decimal"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := map[string]string{
+		"150.75":  "150.75",
+		"-0.1":    "-0.1",
+		"+2":      "2",
+		".5":      "0.5",
+		"5.":      "5",
+		"1.5E-7":  "0.00000015",
+		"1.0E+3":  "1000",
+		"0.00100": "0.00100",
+	}
+	for input, expected := range cases {
+		d, err := decimal.Parse(input)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", input, err)
+			continue
+		}
+		if d.String() != expected {
+			t.Errorf("Parse(%q) = %s, want %s", input, d, expected)
+		}
+	}
+
+	for _, input := range []string{"", ".", "-", "1.2.3", "abc", "1e", "1e99999", "0x10"} {
+		if _, err := decimal.Parse(input); !errors.Is(err, decimal.ErrInvalidDecimal) {
+			t.Errorf("Parse(%q): expected ErrInvalidDecimal, got %v", input, err)
+		}
+	}
+}
+
+func TestArithmeticIsExact(t *testing.T) {
+	quantity := decimal.MustParse("0.1")
+	price := decimal.MustParse("150.75")
+
+	if total := quantity.Mul(price); total.String() != "15.075" {
+		t.Errorf("Expected 15.075, got %s", total)
+	}
+	if sum := decimal.MustParse("0.1").Add(decimal.MustParse("0.2")); !sum.Equal(decimal.MustParse("0.3")) {
+		t.Errorf("Expected 0.3, got %s", sum)
+	}
+	if diff := decimal.New(1, 0).Sub(decimal.MustParse("1.25")); diff.String() != "-0.25" {
+		t.Errorf("Expected -0.25, got %s", diff)
+	}
+	if decimal.MustParse("1.50").Cmp(decimal.MustParse("1.5")) != 0 {
+		t.Error("Expected 1.50 to equal 1.5")
+	}
+}
+
+func TestRound(t *testing.T) {
+	cases := []struct {
+		value    string
+		scale    int32
+		expected string
+	}{
+		{"15.075", 2, "15.08"},
+		{"15.074", 2, "15.07"},
+		{"-15.075", 2, "-15.08"},
+		{"2.5", 0, "3"},
+		{"1.5", 3, "1.500"},
+	}
+	for _, c := range cases {
+		if rounded := decimal.MustParse(c.value).Round(c.scale); rounded.String() != c.expected {
+			t.Errorf("Round(%s, %d) = %s, want %s", c.value, c.scale, rounded, c.expected)
+		}
+	}
+
+	if !decimal.MustParse("0.12345678").FitsScale(decimal.ScaleOf("btc")) {
+		t.Error("Expected 8 decimals to fit BTC")
+	}
+	if decimal.MustParse("0.001").FitsScale(decimal.ScaleOf("USD")) {
+		t.Error("Expected 3 decimals not to fit USD")
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var amounts struct {
+		Number decimal.Decimal `json:"number"`
+		String decimal.Decimal `json:"string"`
+		Null   decimal.Decimal `json:"null"`
+	}
+	data := `{"number":50000.50,"string":"0.1","null":null}`
+	if err := json.Unmarshal([]byte(data), &amounts); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if amounts.Number.String() != "50000.50" || amounts.String.String() != "0.1" || !amounts.Null.IsZero() {
+		t.Fatalf("Unexpected values: %+v", amounts)
+	}
+
+	// числа пишутся так же, как раньше писались float64
+	encoded, _ := json.Marshal(amounts)
+	if string(encoded) != `{"number":50000.5,"string":0.1,"null":0}` {
+		t.Errorf("Unexpected JSON: %s", encoded)
+	}
+
+	if err := json.Unmarshal([]byte(`{"number":"abc"}`), &amounts); err == nil {
+		t.Error("Expected error for invalid string")
+	}
+}
@@ -1,18 +1,29 @@
 package order
 
 import (
+	"sender/internal/data/decimal"
 	"sender/internal/jsonutil"
 )
 
 type Order struct {
-	ID                 int     `json:"id"`
-	UserHashPublicKey  string  `json:"userHashPublicKey"`
-	CryptocurrencyCode string  `json:"cryptocurrencyCode"`
-	TypeName           string  `json:"typeName"`
-	UnitPrice          float64 `json:"unitPrice"`
-	Quantity           float64 `json:"quantity"`
-	CreatedAt          string  `json:"createdAt"`
-	LastStatusChange   string  `json:"lastStatusChange"`
+	ID                 int             `json:"id"`
+	UserHashPublicKey  string          `json:"userHashPublicKey"`
+	CryptocurrencyCode string          `json:"cryptocurrencyCode"`
+	TypeName           string          `json:"typeName"`
+	UnitPrice          decimal.Decimal `json:"unitPrice"`
+	Quantity           decimal.Decimal `json:"quantity"`
+	CreatedAt          string          `json:"createdAt"`
+	LastStatusChange   string          `json:"lastStatusChange"`
+}
+
+// Total returns the exact price of the order, Quantity * UnitPrice
+func (o *Order) Total() decimal.Decimal {
+	return o.Quantity.Mul(o.UnitPrice)
+}
+
+// QuantityScale returns the number of digits after the point the order currency supports
+func (o *Order) QuantityScale() int32 {
+	return decimal.ScaleOf(o.CryptocurrencyCode)
 }
 
 func (o *Order) ToJson() ([]byte, error) {
@@ -1,6 +1,7 @@
 package order_test
 
 import (
+	"sender/internal/data/decimal"
 	"sender/internal/data/order"
 	"strings"
 	"testing"
@@ -13,8 +14,8 @@ func TestOrderToJson(t *testing.T) {
 		UserHashPublicKey:  "user_public_key",
 		CryptocurrencyCode: "BTC",
 		TypeName:           "buy",
-		UnitPrice:          50000.50,
-		Quantity:           2.5,
+		UnitPrice:          decimal.MustParse("50000.50"),
+		Quantity:           decimal.MustParse("2.5"),
 		CreatedAt:          "2023-01-01T10:00:00Z",
 		LastStatusChange:   "2023-01-01T12:00:00Z",
 	}
@@ -76,11 +77,11 @@ func TestOrderFromJson(t *testing.T) {
 	if order.TypeName != "buy" {
 		t.Errorf("Expected TypeName 'buy', got %s", order.TypeName)
 	}
-	if order.UnitPrice != 50000.5 {
-		t.Errorf("Expected UnitPrice 50000.5, got %f", order.UnitPrice)
+	if !order.UnitPrice.Equal(decimal.MustParse("50000.5")) {
+		t.Errorf("Expected UnitPrice 50000.5, got %s", order.UnitPrice)
 	}
-	if order.Quantity != 2.5 {
-		t.Errorf("Expected Quantity 2.5, got %f", order.Quantity)
+	if !order.Quantity.Equal(decimal.MustParse("2.5")) {
+		t.Errorf("Expected Quantity 2.5, got %s", order.Quantity)
 	}
 	if order.CreatedAt != "2023-01-01T10:00:00Z" {
 		t.Errorf("Expected CreatedAt '2023-01-01T10:00:00Z', got %s", order.CreatedAt)
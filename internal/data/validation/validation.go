@@ -0,0 +1,205 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"sender/internal/data/blockchain/wallet"
+	"sender/internal/data/deal"
+	"sender/internal/data/order"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	BuyType  = "buy"
+	SellType = "sell"
+)
+
+// orderTypes holds the accepted typeName values of each side
+type orderTypes struct {
+	buy  []string
+	sell []string
+}
+
+// Default typeName values. The deal service sends localized names and labels
+// both orders of a matched deal with the type of the order that closed it, so the
+// sell order of a deal may also be "Покупка" (see json_example.json). Such deals
+// are accepted, an empty or unknown type is still rejected.
+var (
+	defaultBuyTypes  = []string{"Покупка", BuyType}
+	defaultSellTypes = []string{"Продажа", SellType, "Покупка"}
+)
+
+var acceptedTypes atomic.Pointer[orderTypes]
+
+// SetOrderTypes restricts the typeName of buy and sell orders to the given
+// names, compared case-insensitively. An empty list restores the default
+// names of that side.
+func SetOrderTypes(buy []string, sell []string) {
+	acceptedTypes.Store(&orderTypes{buy: buy, sell: sell})
+}
+
+// ErrInvalidDeal is matched by every error returned from ValidateDeal
+var ErrInvalidDeal = errors.New("invalid deal")
+
+// Layouts of timestamps sent by the deal service
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+}
+
+// FieldError describes a single invalid field, Field is the JSON path ("buyOrder.quantity")
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// Errors is the list of problems found in a deal
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldError := range e {
+		messages[i] = fieldError.Error()
+	}
+	return "invalid deal: " + strings.Join(messages, "; ")
+}
+
+// Is makes errors.Is(err, ErrInvalidDeal) work for validation errors
+func (e Errors) Is(target error) bool {
+	return target == ErrInvalidDeal
+}
+
+func (e *Errors) add(field string, format string, args ...any) {
+	*e = append(*e, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// ValidateDeal checks that the deal can be signed. It returns nil or Errors
+// with every invalid field.
+func ValidateDeal(d *deal.Deal) error {
+	var errs Errors
+	if d == nil {
+		errs.add("deal", "is missing")
+		return errs
+	}
+
+	if d.ID <= 0 {
+		errs.add("id", "must be positive, got %d", d.ID)
+	}
+	checkTimestamp(&errs, "createdAt", d.CreatedAt)
+	checkTimestamp(&errs, "lastStatusChange", d.LastStatusChange)
+
+	if d.BuyOrder == nil {
+		errs.add("buyOrder", "is missing")
+	} else {
+		validateOrder(&errs, "buyOrder", d.BuyOrder, acceptedTypes.Load().buyTypes())
+	}
+	if d.SellOrder == nil {
+		errs.add("sellOrder", "is missing")
+	} else {
+		validateOrder(&errs, "sellOrder", d.SellOrder, acceptedTypes.Load().sellTypes())
+	}
+
+	if d.BuyOrder != nil && d.SellOrder != nil {
+		validateSides(&errs, d.BuyOrder, d.SellOrder)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (t *orderTypes) buyTypes() []string {
+	if t == nil || len(t.buy) == 0 {
+		return defaultBuyTypes
+	}
+	return t.buy
+}
+
+func (t *orderTypes) sellTypes() []string {
+	if t == nil || len(t.sell) == 0 {
+		return defaultSellTypes
+	}
+	return t.sell
+}
+
+func validateOrder(errs *Errors, prefix string, o *order.Order, typeNames []string) {
+	field := func(name string) string {
+		return prefix + "." + name
+	}
+
+	if o.ID <= 0 {
+		errs.add(field("id"), "must be positive, got %d", o.ID)
+	}
+	if !containsFold(typeNames, o.TypeName) {
+		errs.add(field("typeName"), "must be one of %q, got %q", typeNames, o.TypeName)
+	}
+	if o.CryptocurrencyCode == "" {
+		errs.add(field("cryptocurrencyCode"), "is empty")
+	}
+
+	if o.Quantity.Sign() <= 0 {
+		errs.add(field("quantity"), "must be positive, got %s", o.Quantity)
+	} else if scale := o.QuantityScale(); !o.Quantity.FitsScale(scale) {
+		errs.add(field("quantity"), "has more than %d decimal places allowed for %s", scale, o.CryptocurrencyCode)
+	}
+	if o.UnitPrice.Sign() <= 0 {
+		errs.add(field("unitPrice"), "must be positive, got %s", o.UnitPrice)
+	}
+
+	if o.UserHashPublicKey == "" {
+		errs.add(field("userHashPublicKey"), "is empty")
+	} else if _, err := wallet.ParsePublicKey(o.UserHashPublicKey); err != nil {
+		errs.add(field("userHashPublicKey"), "is not a public key: %v", err)
+	}
+
+	checkTimestamp(errs, field("createdAt"), o.CreatedAt)
+	checkTimestamp(errs, field("lastStatusChange"), o.LastStatusChange)
+}
+
+// validateSides checks that the buy and sell orders describe the same trade
+func validateSides(errs *Errors, buy *order.Order, sell *order.Order) {
+	if buy.CryptocurrencyCode != "" && sell.CryptocurrencyCode != "" &&
+		!strings.EqualFold(buy.CryptocurrencyCode, sell.CryptocurrencyCode) {
+		errs.add("sellOrder.cryptocurrencyCode", "%q does not match buy order %q", sell.CryptocurrencyCode, buy.CryptocurrencyCode)
+	}
+	if buy.Quantity.Sign() > 0 && sell.Quantity.Sign() > 0 && !buy.Quantity.Equal(sell.Quantity) {
+		errs.add("sellOrder.quantity", "%s does not match buy order %s", sell.Quantity, buy.Quantity)
+	}
+	// покупатель не может платить меньше, чем просит продавец
+	if buy.UnitPrice.Sign() > 0 && sell.UnitPrice.Sign() > 0 && buy.UnitPrice.Cmp(sell.UnitPrice) < 0 {
+		errs.add("buyOrder.unitPrice", "%s is below sell order price %s", buy.UnitPrice, sell.UnitPrice)
+	}
+}
+
+// checkTimestamp accepts an empty value, a set value must be parseable
+func checkTimestamp(errs *Errors, field string, value string) {
+	if value == "" {
+		return
+	}
+	for _, layout := range timestampLayouts {
+		if _, err := time.Parse(layout, value); err == nil {
+			return
+		}
+	}
+	errs.add(field, "is not a valid timestamp: %q", value)
+}
+
+func containsFold(values []string, value string) bool {
+	for _, candidate := range values {
+		if strings.EqualFold(strings.TrimSpace(candidate), value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,203 @@
+package validation_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"regexp"
+	"sender/internal/data/blockchain/wallet"
+	"sender/internal/data/deal"
+	"sender/internal/data/decimal"
+	"sender/internal/data/order"
+	"sender/internal/data/validation"
+	"strings"
+	"testing"
+)
+
+func newValidDeal(t *testing.T) *deal.Deal {
+	t.Helper()
+
+	buyer, _ := wallet.NewWithAlgorithm(wallet.Ed25519)
+	seller, _ := wallet.NewWithAlgorithm(wallet.Ed25519)
+	newOrder := func(id int, typeName string, publicKey string) *order.Order {
+		return &order.Order{
+			ID:                 id,
+			UserHashPublicKey:  publicKey,
+			CryptocurrencyCode: "BTC",
+			TypeName:           typeName,
+			UnitPrice:          decimal.MustParse("150.75"),
+			Quantity:           decimal.MustParse("0.1"),
+			CreatedAt:          "2025-01-01T12:00:00.123456",
+		}
+	}
+	return &deal.Deal{
+		ID:        1,
+		BuyOrder:  newOrder(1, "buy", buyer.Serialize().PublicKey),
+		SellOrder: newOrder(2, "SELL", seller.Serialize().PublicKey),
+		CreatedAt: "2025-01-01T12:30:00Z",
+	}
+}
+
+// fields returns the invalid fields reported for the deal
+func fields(t *testing.T, d *deal.Deal) map[string]bool {
+	t.Helper()
+
+	err := validation.ValidateDeal(d)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, validation.ErrInvalidDeal) {
+		t.Fatalf("Expected ErrInvalidDeal, got %v", err)
+	}
+
+	var errs validation.Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected validation.Errors, got %T", err)
+	}
+	result := make(map[string]bool)
+	for _, fieldError := range errs {
+		result[fieldError.Field] = true
+	}
+	return result
+}
+
+func TestValidDeal(t *testing.T) {
+	if err := validation.ValidateDeal(newValidDeal(t)); err != nil {
+		t.Fatalf("Expected valid deal, got %v", err)
+	}
+}
+
+func TestMissingOrders(t *testing.T) {
+	d := newValidDeal(t)
+	d.BuyOrder = nil
+	d.SellOrder = nil
+
+	invalid := fields(t, d)
+	if !invalid["buyOrder"] || !invalid["sellOrder"] {
+		t.Errorf("Expected both orders to be reported, got %v", invalid)
+	}
+
+	if invalid := fields(t, nil); !invalid["deal"] {
+		t.Errorf("Expected nil deal to be reported, got %v", invalid)
+	}
+}
+
+func TestInvalidOrderFields(t *testing.T) {
+	validation.SetOrderTypes([]string{validation.BuyType}, []string{validation.SellType})
+	t.Cleanup(func() { validation.SetOrderTypes(nil, nil) })
+
+	d := newValidDeal(t)
+	d.BuyOrder.TypeName = "sell"
+	d.BuyOrder.UserHashPublicKey = "buyer-public-key"
+	d.SellOrder.Quantity = decimal.New(0, 0)
+	d.SellOrder.UnitPrice = decimal.MustParse("-1")
+	d.SellOrder.CreatedAt = "yesterday"
+
+	invalid := fields(t, d)
+	for _, field := range []string{
+		"buyOrder.typeName",
+		"buyOrder.userHashPublicKey",
+		"sellOrder.quantity",
+		"sellOrder.unitPrice",
+		"sellOrder.createdAt",
+	} {
+		if !invalid[field] {
+			t.Errorf("Expected %s to be reported, got %v", field, invalid)
+		}
+	}
+}
+
+func TestSidesMustMatch(t *testing.T) {
+	d := newValidDeal(t)
+	d.SellOrder.CryptocurrencyCode = "ETH"
+	d.SellOrder.Quantity = decimal.MustParse("0.2")
+	d.SellOrder.UnitPrice = decimal.MustParse("151")
+
+	invalid := fields(t, d)
+	for _, field := range []string{"sellOrder.cryptocurrencyCode", "sellOrder.quantity", "buyOrder.unitPrice"} {
+		if !invalid[field] {
+			t.Errorf("Expected %s to be reported, got %v", field, invalid)
+		}
+	}
+}
+
+func TestQuantityScale(t *testing.T) {
+	d := newValidDeal(t)
+	d.BuyOrder.Quantity = decimal.MustParse("0.000000001")
+	d.SellOrder.Quantity = d.BuyOrder.Quantity
+
+	if invalid := fields(t, d); !invalid["buyOrder.quantity"] || !invalid["sellOrder.quantity"] {
+		t.Errorf("Expected BTC quantity below satoshi to be reported, got %v", invalid)
+	}
+}
+
+// sampleDeal достает сделку из подписанной транзакции в json_example.json
+var sampleDeal = regexp.MustCompile(`"message":(\{"id":\d+,"buyOrder".*\}),\s*\n`)
+
+func loadSampleDeal(t *testing.T) *deal.Deal {
+	t.Helper()
+
+	data, err := os.ReadFile("../../../json_example.json")
+	if err != nil {
+		t.Fatalf("Failed to read json_example.json: %v", err)
+	}
+	match := sampleDeal.FindSubmatch(data)
+	if match == nil {
+		t.Fatal("No deal found in json_example.json")
+	}
+
+	var d deal.Deal
+	if err := json.Unmarshal(match[1], &d); err != nil {
+		t.Fatalf("Failed to parse sample deal: %v", err)
+	}
+
+	// в примере ключи участников уже вырезаны, в Kafka они приходят вместе со сделкой
+	buyer, _ := wallet.NewWithAlgorithm(wallet.Ed25519)
+	seller, _ := wallet.NewWithAlgorithm(wallet.Ed25519)
+	d.BuyOrder.UserHashPublicKey = buyer.Serialize().PublicKey
+	d.SellOrder.UserHashPublicKey = seller.Serialize().PublicKey
+	return &d
+}
+
+func TestSampleDeal(t *testing.T) {
+	d := loadSampleDeal(t)
+	if !strings.EqualFold(d.SellOrder.TypeName, "Покупка") || d.SellOrder.CreatedAt != "2024-11-17T14:30" {
+		t.Fatalf("Unexpected sample deal: %+v", d.SellOrder)
+	}
+
+	if err := validation.ValidateDeal(d); err != nil {
+		t.Fatalf("Expected sample deal to be valid, got %v", err)
+	}
+}
+
+func TestDefaultOrderTypes(t *testing.T) {
+	d := newValidDeal(t)
+	d.BuyOrder.TypeName = "Покупка"
+	d.SellOrder.TypeName = "Продажа"
+	if err := validation.ValidateDeal(d); err != nil {
+		t.Fatalf("Expected localized type names to be accepted, got %v", err)
+	}
+
+	d.BuyOrder.TypeName = "Продажа"
+	d.SellOrder.TypeName = ""
+	invalid := fields(t, d)
+	if !invalid["buyOrder.typeName"] || !invalid["sellOrder.typeName"] {
+		t.Errorf("Expected type names to be checked by default, got %v", invalid)
+	}
+}
+
+func TestOrderTypesMapping(t *testing.T) {
+	validation.SetOrderTypes([]string{"Покупка", validation.BuyType}, []string{"Продажа", validation.SellType})
+	t.Cleanup(func() { validation.SetOrderTypes(nil, nil) })
+
+	d := loadSampleDeal(t)
+	invalid := fields(t, d)
+	if invalid["buyOrder.typeName"] || !invalid["sellOrder.typeName"] {
+		t.Errorf("Expected only sellOrder.typeName to be reported, got %v", invalid)
+	}
+
+	d.SellOrder.TypeName = "продажа"
+	if err := validation.ValidateDeal(d); err != nil {
+		t.Errorf("Expected mapped type names to be accepted, got %v", err)
+	}
+}
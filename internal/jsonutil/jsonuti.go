@@ -1,6 +1,7 @@
 package jsonutil
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 )
@@ -13,6 +14,18 @@ func ToJSON(v interface{}) ([]byte, error) {
 	return json.Marshal(v)
 }
 
+// Marshal encodes v like json.Marshal but leaves <, > and & unescaped,
+// so raw values such as block transactions are written back byte for byte
+func Marshal(v interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buffer.Bytes(), []byte("\n")), nil
+}
+
 // FromJSON - универсальная функция для десериализации JSON в объект.
 func FromJSON(data []byte, v interface{}) error {
 	if len(data) <= 1 {
@@ -2,6 +2,7 @@ package jsonutil_test
 
 import (
 	"bytes"
+	"encoding/json"
 	"sender/internal/jsonutil"
 	"testing"
 )
@@ -84,3 +85,21 @@ func TestNilObject(t *testing.T) {
 		t.Fatalf("Failed create nil object from JSON: %v", err)
 	}
 }
+
+func TestMarshalKeepsHTMLCharacters(t *testing.T) {
+	object := struct {
+		Raw  json.RawMessage
+		Text string
+	}{
+		Raw:  json.RawMessage(`{"note": "<a&b>"}`),
+		Text: "<>",
+	}
+
+	text, err := jsonutil.Marshal(object)
+	if err != nil {
+		t.Fatalf("Failed to marshal object: %v", err)
+	}
+	if expected := `{"Raw":{"note":"<a&b>"},"Text":"<>"}`; string(text) != expected {
+		t.Fatalf("Expected %s, got %s", expected, text)
+	}
+}
@@ -0,0 +1,39 @@
+package process
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Headers added to messages written to the dead-letter topic
+const (
+	HeaderError           = "x-error"
+	HeaderSourceTopic     = "x-source-topic"
+	HeaderSourcePartition = "x-source-partition"
+	HeaderSourceOffset    = "x-source-offset"
+	HeaderSourceTimestamp = "x-source-timestamp"
+	HeaderFailedAt        = "x-failed-at"
+)
+
+// DeadLetter builds the dead-letter message for a message that could not be processed.
+// The original key, value and headers are kept, the failure is described in extra headers.
+func DeadLetter(source kafka.Message, reason error, failedAt time.Time) kafka.Message {
+	headers := make([]kafka.Header, 0, len(source.Headers)+6)
+	headers = append(headers, source.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: HeaderError, Value: []byte(reason.Error())},
+		kafka.Header{Key: HeaderSourceTopic, Value: []byte(source.Topic)},
+		kafka.Header{Key: HeaderSourcePartition, Value: []byte(strconv.Itoa(source.Partition))},
+		kafka.Header{Key: HeaderSourceOffset, Value: []byte(strconv.FormatInt(source.Offset, 10))},
+		kafka.Header{Key: HeaderSourceTimestamp, Value: []byte(source.Time.UTC().Format(time.RFC3339Nano))},
+		kafka.Header{Key: HeaderFailedAt, Value: []byte(failedAt.UTC().Format(time.RFC3339Nano))},
+	)
+
+	return kafka.Message{
+		Key:     source.Key,
+		Value:   source.Value,
+		Headers: headers,
+	}
+}
@@ -3,8 +3,8 @@ package process
 import (
 	"context"
 	"errors"
-	"fmt"
 	"log"
+	"sort"
 	"time"
 
 	"github.com/segmentio/kafka-go"
@@ -17,9 +17,14 @@ type WriterInterface interface {
 
 type ReaderInterface interface {
 	ReadMessage(ctx context.Context) (kafka.Message, error)
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
 	Close() error
 }
 
+// retryDelay is the pause before a failed message is handled again
+var retryDelay = time.Second
+
 type KafkaProcess struct {
 	BrokerAddress string
 	TopicName     string
@@ -42,7 +47,7 @@ func (kp *KafkaProcess) ConnectWriter() {
 	kp.Writer = &kafka.Writer{
 		Addr:         kafka.TCP(kp.BrokerAddress),
 		Topic:        kp.TopicName,
-		Balancer:     &kafka.LeastBytes{},
+		Balancer:     &kafka.Hash{}, // messages with the same key go to the same partition
 		RequiredAcks: kafka.RequireOne,
 	}
 	log.Println("Kafka writer connected")
@@ -96,28 +101,31 @@ func (kp *KafkaProcess) Close() {
 	log.Println("Kafka connections closed")
 }
 
-// WriteMessage sends a message to the Kafka topic.
+// WriteMessage sends a message without a key to the Kafka topic.
 func (kp *KafkaProcess) WriteMessage(ctx context.Context, message string) error {
-	if kp.Writer == nil {
-		return errors.New("Kafka writer is not initialized")
+	if err := kp.WriteKafkaMessage(ctx, NewMessage("", message, nil)); err != nil {
+		return err
 	}
 
-	msg := kafka.Message{
-		Key:   []byte(fmt.Sprintf("DeaiAdd-%d", time.Now().UnixNano())),
-		Value: []byte(message),
+	log.Printf("Message written: %s", message)
+	return nil
+}
+
+// WriteKafkaMessage sends a prepared message with its key and headers to the Kafka topic.
+func (kp *KafkaProcess) WriteKafkaMessage(ctx context.Context, msg kafka.Message) error {
+	if kp.Writer == nil {
+		return errors.New("Kafka writer is not initialized")
 	}
 
-	err := kp.Writer.WriteMessages(ctx, msg)
-	if err != nil {
+	if err := kp.Writer.WriteMessages(ctx, msg); err != nil {
 		log.Printf("Failed to write message: %v", err)
 		return err
 	}
-
-	log.Printf("Message written: %s", message)
 	return nil
 }
 
 // ReadMessages listens for messages from the Kafka topic.
+// Offsets are committed automatically as soon as a message is read.
 func (kp *KafkaProcess) ReadMessages(ctx context.Context, handleMessage func(string)) error {
 	if kp.Reader == nil {
 		return errors.New("Kafka reader is not initialized")
@@ -141,3 +149,91 @@ func (kp *KafkaProcess) ReadMessages(ctx context.Context, handleMessage func(str
 		handleMessage(message)
 	}
 }
+
+// ConsumeMessages listens for messages from the Kafka topic with at-least-once
+// semantics. The offset of a message is committed only after the handler
+// returns nil; a failed message is handled again after retryDelay, so the
+// consumer never moves past an unprocessed message. Fetch errors (the broker
+// is unavailable) are retried after the same delay.
+func (kp *KafkaProcess) ConsumeMessages(ctx context.Context, handleMessage func(kafka.Message) error) error {
+	if kp.Reader == nil {
+		return errors.New("Kafka reader is not initialized")
+	}
+
+	for {
+		msg, err := kp.Reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+				log.Println("Reader context canceled")
+				return nil
+			}
+			log.Printf("Failed to fetch message, retrying in %s: %v", retryDelay, err)
+			if !waitRetry(ctx) {
+				log.Println("Reader context canceled")
+				return nil
+			}
+			continue
+		}
+
+		log.Printf("Message received from kafka: %s", string(msg.Value))
+
+		for {
+			err := handleMessage(msg)
+			if err == nil {
+				break
+			}
+			log.Printf("Failed to handle message at partition %d offset %d, retrying: %v", msg.Partition, msg.Offset, err)
+			if !waitRetry(ctx) {
+				log.Println("Reader context canceled")
+				return nil
+			}
+		}
+
+		// при ошибке коммита сообщение будет прочитано повторно
+		if err := kp.Reader.CommitMessages(ctx, msg); err != nil {
+			log.Printf("Failed to commit offset %d of partition %d: %v", msg.Offset, msg.Partition, err)
+		}
+	}
+}
+
+// waitRetry sleeps for retryDelay, it returns false if the context was canceled first
+func waitRetry(ctx context.Context) bool {
+	timer := time.NewTimer(retryDelay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// NewMessage builds a message with the given key and headers, headers are sorted by name.
+// An empty key leaves the partition choice to the balancer.
+func NewMessage(key string, value string, headers map[string]string) kafka.Message {
+	msg := kafka.Message{Value: []byte(value)}
+	if key != "" {
+		msg.Key = []byte(key)
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		msg.Headers = append(msg.Headers, kafka.Header{Key: name, Value: []byte(headers[name])})
+	}
+	return msg
+}
+
+// HeaderValue returns the value of the first header with the given name
+func HeaderValue(msg kafka.Message, name string) string {
+	for _, header := range msg.Headers {
+		if header.Key == name {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
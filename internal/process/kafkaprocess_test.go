@@ -37,6 +37,16 @@ func (m *MockReader) ReadMessage(ctx context.Context) (kafka.Message, error) {
 	return args.Get(0).(kafka.Message), args.Error(1)
 }
 
+func (m *MockReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(kafka.Message), args.Error(1)
+}
+
+func (m *MockReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	args := m.Called(ctx, msgs)
+	return args.Error(0)
+}
+
 func (m *MockReader) Close() error {
 	args := m.Called()
 	return args.Error(0)
@@ -200,6 +210,161 @@ func TestReadMessages(t *testing.T) {
 	})
 }
 
+func TestConsumeMessagesPassesMetadata(t *testing.T) {
+	kp := NewKafkaProcess("localhost:9092", "test-topic", "test-group")
+	mockReader := new(MockReader)
+	kp.Reader = mockReader
+	ctx := context.Background()
+
+	fetched := kafka.Message{Topic: "test-topic", Partition: 2, Offset: 42, Value: []byte("broken")}
+	mockReader.On("FetchMessage", ctx).Return(fetched, nil).Once()
+	mockReader.On("FetchMessage", ctx).Return(kafka.Message{}, context.Canceled)
+	mockReader.On("CommitMessages", ctx, []kafka.Message{fetched}).Return(nil).Once()
+
+	var received kafka.Message
+	err := kp.ConsumeMessages(ctx, func(msg kafka.Message) error {
+		received = msg
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, received.Partition)
+	assert.Equal(t, int64(42), received.Offset)
+	assert.Equal(t, "broken", string(received.Value))
+	mockReader.AssertExpectations(t)
+}
+
+func TestConsumeMessagesCommitsOnlyAfterSuccess(t *testing.T) {
+	retryDelay = time.Millisecond
+	defer func() { retryDelay = time.Second }()
+
+	kp := NewKafkaProcess("localhost:9092", "test-topic", "test-group")
+	mockReader := new(MockReader)
+	kp.Reader = mockReader
+	ctx := context.Background()
+
+	fetched := kafka.Message{Partition: 0, Offset: 5, Value: []byte("deal")}
+	mockReader.On("FetchMessage", ctx).Return(fetched, nil).Once()
+	mockReader.On("FetchMessage", ctx).Return(kafka.Message{}, context.Canceled)
+
+	attempts := 0
+	mockReader.On("CommitMessages", ctx, []kafka.Message{fetched}).Run(func(mock.Arguments) {
+		// коммит только после успешной обработки
+		assert.Equal(t, 3, attempts)
+	}).Return(nil).Once()
+
+	err := kp.ConsumeMessages(ctx, func(msg kafka.Message) error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("mempool is not writable")
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+	mockReader.AssertExpectations(t)
+}
+
+func TestConsumeMessagesStopsRetryingOnCancel(t *testing.T) {
+	kp := NewKafkaProcess("localhost:9092", "test-topic", "test-group")
+	mockReader := new(MockReader)
+	kp.Reader = mockReader
+	ctx, cancel := context.WithCancel(context.Background())
+
+	mockReader.On("FetchMessage", ctx).Return(kafka.Message{Offset: 1}, nil).Once()
+
+	err := kp.ConsumeMessages(ctx, func(msg kafka.Message) error {
+		cancel()
+		return errors.New("broker is down")
+	})
+
+	// сообщение не обработано, offset не коммитится
+	assert.NoError(t, err)
+	mockReader.AssertNotCalled(t, "CommitMessages", mock.Anything, mock.Anything)
+}
+
+func TestConsumeMessagesBacksOffOnFetchError(t *testing.T) {
+	retryDelay = 20 * time.Millisecond
+	defer func() { retryDelay = time.Second }()
+
+	kp := NewKafkaProcess("localhost:9092", "test-topic", "test-group")
+	mockReader := new(MockReader)
+	kp.Reader = mockReader
+	ctx, cancel := context.WithTimeout(context.Background(), 70*time.Millisecond)
+	defer cancel()
+
+	fetches := 0
+	mockReader.On("FetchMessage", ctx).Run(func(mock.Arguments) {
+		fetches++
+	}).Return(kafka.Message{}, errors.New("broker is down"))
+
+	err := kp.ConsumeMessages(ctx, func(msg kafka.Message) error {
+		return nil
+	})
+
+	// без паузы цикл крутился бы тысячи раз за время таймаута
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, fetches, 5)
+	mockReader.AssertNotCalled(t, "CommitMessages", mock.Anything, mock.Anything)
+}
+
+func TestDeadLetter(t *testing.T) {
+	source := kafka.Message{
+		Topic:     "GoGetDeal",
+		Partition: 1,
+		Offset:    7,
+		Key:       []byte("key"),
+		Value:     []byte("{not json"),
+		Headers:   []kafka.Header{{Key: "trace", Value: []byte("abc")}},
+		Time:      time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+	failedAt := time.Date(2025, 1, 1, 12, 0, 1, 0, time.UTC)
+
+	dead := DeadLetter(source, errors.New("deal read error"), failedAt)
+
+	assert.Equal(t, source.Key, dead.Key)
+	assert.Equal(t, source.Value, dead.Value)
+	assert.Empty(t, dead.Topic)
+
+	headers := map[string]string{}
+	for _, header := range dead.Headers {
+		headers[header.Key] = string(header.Value)
+	}
+	assert.Equal(t, "abc", headers["trace"])
+	assert.Equal(t, "deal read error", headers[HeaderError])
+	assert.Equal(t, "GoGetDeal", headers[HeaderSourceTopic])
+	assert.Equal(t, "1", headers[HeaderSourcePartition])
+	assert.Equal(t, "7", headers[HeaderSourceOffset])
+	assert.Equal(t, "2025-01-01T12:00:00Z", headers[HeaderSourceTimestamp])
+	assert.Equal(t, "2025-01-01T12:00:01Z", headers[HeaderFailedAt])
+
+	// dead letter is written with the writer of the dead-letter topic
+	kp := NewKafkaProcess("localhost:9092", "GoGetDealDLQ", "")
+	mockWriter := new(MockWriter)
+	kp.Writer = mockWriter
+	mockWriter.On("WriteMessages", mock.Anything, []kafka.Message{dead}).Return(nil)
+
+	assert.NoError(t, kp.WriteKafkaMessage(context.Background(), dead))
+	mockWriter.AssertExpectations(t)
+}
+
+func TestNewMessage(t *testing.T) {
+	msg := NewMessage("42", "value", map[string]string{"schema-version": "1", "event-type": "DealConfirmed"})
+
+	assert.Equal(t, []byte("42"), msg.Key)
+	assert.Equal(t, []byte("value"), msg.Value)
+	assert.Equal(t, []kafka.Header{
+		{Key: "event-type", Value: []byte("DealConfirmed")},
+		{Key: "schema-version", Value: []byte("1")},
+	}, msg.Headers)
+	assert.Equal(t, "DealConfirmed", HeaderValue(msg, "event-type"))
+	assert.Equal(t, "", HeaderValue(msg, "missing"))
+
+	// без ключа партицию выбирает балансировщик
+	assert.Nil(t, NewMessage("", "value", nil).Key)
+}
+
 func TestClose(t *testing.T) {
 	// Arrange
 	kp := NewKafkaProcess("localhost:9092", "test-topic", "test-group")
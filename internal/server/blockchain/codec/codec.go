@@ -0,0 +1,170 @@
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Mode is the way messages are written to a peer
+type Mode int
+
+const (
+	// ModeLine - newline-delimited JSON, understood by every peer
+	ModeLine Mode = iota
+	// ModeFramed - length-prefixed frames, used once the peer announced support
+	ModeFramed
+)
+
+// Codec names announced in the hello message
+const (
+	NameLine   = "ndjson"
+	NameFramed = "framed/1"
+)
+
+func (m Mode) String() string {
+	if m == ModeFramed {
+		return NameFramed
+	}
+	return NameLine
+}
+
+// Frame types
+const (
+	// FrameMessage carries a JSON protocol message
+	FrameMessage byte = 1
+)
+
+const (
+	// DefaultMaxFrameSize limits the size of a single message in both modes
+	DefaultMaxFrameSize = 16 << 20
+
+	// frameHeaderSize is the u32 length followed by the type byte
+	frameHeaderSize = 5
+)
+
+var (
+	ErrFrameTooLarge = errors.New("frame exceeds the maximum size")
+	ErrEmptyFrame    = errors.New("frame has no type")
+	ErrNewlineInLine = errors.New("message contains a newline and cannot be sent as a line")
+)
+
+// Frame layout, integers are big-endian:
+//
+//	u32  length of the rest of the frame (type + payload)
+//	u8   frame type
+//	...  payload
+//
+// The first byte of a frame is 0x00 or 0x01 for any frame below 32 MiB, so it never
+// collides with '{' that starts a JSON line and the reader tells the modes apart.
+
+// AppendFrame appends the encoded frame to dst
+func AppendFrame(dst []byte, frameType byte, payload []byte) []byte {
+	dst = binary.BigEndian.AppendUint32(dst, uint32(len(payload)+1))
+	dst = append(dst, frameType)
+	return append(dst, payload...)
+}
+
+// Encode returns the message encoded in the given mode
+func Encode(mode Mode, frameType byte, payload []byte) ([]byte, error) {
+	if mode == ModeFramed {
+		return AppendFrame(make([]byte, 0, frameHeaderSize+len(payload)), frameType, payload), nil
+	}
+
+	if bytes.IndexByte(payload, '\n') >= 0 {
+		return nil, ErrNewlineInLine
+	}
+	line := make([]byte, 0, len(payload)+1)
+	line = append(line, payload...)
+	return append(line, '\n'), nil
+}
+
+// Reader reads messages written in either mode, the mode of every message
+// is detected by its first byte
+type Reader struct {
+	reader       *bufio.Reader
+	maxFrameSize int
+}
+
+// NewReader creates a reader, maxFrameSize <= 0 means DefaultMaxFrameSize
+func NewReader(r io.Reader, maxFrameSize int) *Reader {
+	if maxFrameSize <= 0 {
+		maxFrameSize = DefaultMaxFrameSize
+	}
+	return &Reader{
+		reader:       bufio.NewReaderSize(r, 64<<10),
+		maxFrameSize: maxFrameSize,
+	}
+}
+
+// ReadMessage returns the next message with its frame type and the mode it was written in.
+// Lines always have FrameMessage type.
+func (r *Reader) ReadMessage() (byte, []byte, Mode, error) {
+	for {
+		first, err := r.reader.Peek(1)
+		if err != nil {
+			return 0, nil, ModeLine, err
+		}
+
+		switch first[0] {
+		case '\n', '\r', ' ', '\t':
+			// пустые строки между сообщениями
+			r.reader.ReadByte()
+			continue
+		case 0x00, 0x01:
+			frameType, payload, err := r.readFrame()
+			return frameType, payload, ModeFramed, err
+		default:
+			line, err := r.readLine()
+			return FrameMessage, line, ModeLine, err
+		}
+	}
+}
+
+func (r *Reader) readFrame() (byte, []byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r.reader, header[:]); err != nil {
+		return 0, nil, err
+	}
+
+	length := int(binary.BigEndian.Uint32(header[:]))
+	if length == 0 {
+		return 0, nil, ErrEmptyFrame
+	}
+	if length-1 > r.maxFrameSize {
+		return 0, nil, fmt.Errorf("%w: %d > %d", ErrFrameTooLarge, length-1, r.maxFrameSize)
+	}
+
+	frame := make([]byte, length)
+	if _, err := io.ReadFull(r.reader, frame); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, nil, err
+	}
+	return frame[0], frame[1:], nil
+}
+
+func (r *Reader) readLine() ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := r.reader.ReadSlice('\n')
+		if len(line)+len(chunk) > r.maxFrameSize+1 {
+			return nil, fmt.Errorf("%w: line longer than %d", ErrFrameTooLarge, r.maxFrameSize)
+		}
+		line = append(line, chunk...)
+
+		if err == nil {
+			return bytes.TrimRight(line, "\r\n"), nil
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			if errors.Is(err, io.EOF) && len(line) > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+	}
+}
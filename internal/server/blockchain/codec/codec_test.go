@@ -0,0 +1,118 @@
+package codec_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"sender/internal/data/blockchain/block"
+	"sender/internal/data/blockchain/transaction"
+	"sender/internal/data/decimal"
+	"sender/internal/server/blockchain/codec"
+	"sender/internal/server/blockchain/protocol/message"
+)
+
+func TestReaderDetectsMode(t *testing.T) {
+	var stream bytes.Buffer
+	line, _ := codec.Encode(codec.ModeLine, codec.FrameMessage, []byte(`{"type":"Info"}`))
+	frame, _ := codec.Encode(codec.ModeFramed, codec.FrameMessage, []byte("{\n\"type\": \"Block\"\n}"))
+	stream.Write(line)
+	stream.WriteString("\r\n")
+	stream.Write(frame)
+	stream.Write(line)
+
+	reader := codec.NewReader(&stream, 0)
+	expected := []struct {
+		payload string
+		mode    codec.Mode
+	}{
+		{`{"type":"Info"}`, codec.ModeLine},
+		{"{\n\"type\": \"Block\"\n}", codec.ModeFramed},
+		{`{"type":"Info"}`, codec.ModeLine},
+	}
+	for i, exp := range expected {
+		frameType, payload, mode, err := reader.ReadMessage()
+		if err != nil {
+			t.Fatalf("message %d: ReadMessage failed: %v", i, err)
+		}
+		if frameType != codec.FrameMessage || string(payload) != exp.payload || mode != exp.mode {
+			t.Errorf("message %d: got type %d, mode %s, payload %q", i, frameType, mode, payload)
+		}
+	}
+
+	if _, _, _, err := reader.ReadMessage(); !errors.Is(err, io.EOF) {
+		t.Errorf("Expected EOF, got %v", err)
+	}
+}
+
+func TestReaderRejectsOversizedMessages(t *testing.T) {
+	frame := codec.AppendFrame(nil, codec.FrameMessage, bytes.Repeat([]byte("a"), 65))
+	if _, _, _, err := codec.NewReader(bytes.NewReader(frame), 64).ReadMessage(); !errors.Is(err, codec.ErrFrameTooLarge) {
+		t.Errorf("Expected ErrFrameTooLarge for a frame, got %v", err)
+	}
+
+	line := append(bytes.Repeat([]byte("a"), 100), '\n')
+	if _, _, _, err := codec.NewReader(bytes.NewReader(line), 64).ReadMessage(); !errors.Is(err, codec.ErrFrameTooLarge) {
+		t.Errorf("Expected ErrFrameTooLarge for a line, got %v", err)
+	}
+
+	truncated := codec.AppendFrame(nil, codec.FrameMessage, []byte("payload"))[:8]
+	if _, _, _, err := codec.NewReader(bytes.NewReader(truncated), 0).ReadMessage(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected ErrUnexpectedEOF for a truncated frame, got %v", err)
+	}
+
+	if _, err := codec.Encode(codec.ModeLine, codec.FrameMessage, []byte("a\nb")); !errors.Is(err, codec.ErrNewlineInLine) {
+		t.Errorf("Expected ErrNewlineInLine, got %v", err)
+	}
+}
+
+func TestLargeBlockRoundTrip(t *testing.T) {
+	transactions := make([]transaction.Transaction, 20000)
+	for i := range transactions {
+		transactions[i] = transaction.Transaction{
+			Sender:          fmt.Sprintf("sender-%d", i),
+			BuyerPublicKey:  "buyer",
+			SellerPublicKey: "seller",
+			DealMessage:     fmt.Sprintf(`{"id":%d}`, i),
+			Transfer:        decimal.New(int64(i), 2),
+			Signature:       "signature",
+		}
+	}
+	sent := message.NewBlockMessage(&block.Block{ID: 1, Transactions: transactions})
+	payload, err := json.Marshal(sent)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if len(payload) < 1<<20 {
+		t.Fatalf("Expected a block larger than 1 MiB, got %d bytes", len(payload))
+	}
+
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		data, _ := codec.Encode(codec.ModeFramed, codec.FrameMessage, payload)
+		server.Write(data)
+	}()
+
+	_, received, mode, err := codec.NewReader(client, 0).ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage failed: %v", err)
+	}
+	if mode != codec.ModeFramed || !bytes.Equal(received, payload) {
+		t.Fatalf("Block changed in transit: mode %s, %d of %d bytes", mode, len(received), len(payload))
+	}
+
+	msg, err := message.MessageFromJson(received)
+	if err != nil {
+		t.Fatalf("MessageFromJson failed: %v", err)
+	}
+	if got := len(msg.Content.(*message.BlockMessage).Block.Transactions); got != len(transactions) {
+		t.Errorf("Expected %d transactions, got %d", len(transactions), got)
+	}
+}
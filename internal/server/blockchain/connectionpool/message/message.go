@@ -3,6 +3,7 @@ package message
 import (
 	"net"
 	"sender/internal/server/blockchain/connectionpool/peer"
+	"sender/internal/server/blockchain/handshake"
 )
 
 // MessageType defines the type of pool message
@@ -14,6 +15,7 @@ const (
 	BroadcastMessage
 	GetPeers
 	PeerMessage
+	DirectMessage
 )
 
 // PoolMessage represents a message to the connection pool
@@ -21,6 +23,7 @@ type PoolMessage struct {
 	Type         MessageType
 	Addr         net.Addr
 	Conn         *peer.ProtectedConnection
+	Handshake    *handshake.Handshake // For NewPeer, nil for legacy peers
 	Message      string
 	ResponseChan chan []net.Addr // For GetPeers responses
 }
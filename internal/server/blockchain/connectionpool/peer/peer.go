@@ -1,15 +1,42 @@
 package peer
 
 import (
+	"errors"
+	"log"
 	"net"
+	"sender/internal/server/blockchain/codec"
+	"sender/internal/server/blockchain/handshake"
 	"sync"
 	"time"
 )
 
-// ProtectedConnection is a wrapper around a connection with mutex protection
+const (
+	// DefaultQueueSize is the number of outbound messages buffered per peer
+	DefaultQueueSize = 256
+
+	// writeTimeout bounds a single write so a stuck peer releases its writer
+	writeTimeout = 10 * time.Second
+)
+
+var (
+	ErrQueueFull = errors.New("outbound queue is full")
+	ErrClosed    = errors.New("connection is closed")
+)
+
+// ProtectedConnection is a wrapper around a connection with mutex protection.
+// Reads are done by the server without locking; writes go through a bounded
+// queue drained by a writer goroutine once Start is called, or directly otherwise.
 type ProtectedConnection struct {
 	Conn  net.Conn
 	Mutex *sync.Mutex
+
+	// mode is the codec used for writes, guarded by Mutex. The zero value is
+	// newline-delimited JSON that every peer understands.
+	mode codec.Mode
+
+	queue     chan string
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
 // NewProtectedConnection creates a new protected connection
@@ -20,10 +47,133 @@ func NewProtectedConnection(conn net.Conn, mutex *sync.Mutex) ProtectedConnectio
 	}
 }
 
+// Start creates the outbound queue and runs the writer goroutine
+func (c *ProtectedConnection) Start(queueSize int) {
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+	c.queue = make(chan string, queueSize)
+	c.done = make(chan struct{})
+
+	go c.runWriter()
+}
+
+// Send queues a protocol message for the peer without waiting for the network.
+// A full queue means the peer does not keep up and the message is refused.
+// Without a queue the message is written directly.
+func (c *ProtectedConnection) Send(message string) error {
+	if c.queue == nil {
+		c.Mutex.Lock()
+		defer c.Mutex.Unlock()
+
+		return c.write(codec.FrameMessage, []byte(message))
+	}
+
+	select {
+	case <-c.done:
+		return ErrClosed
+	default:
+	}
+
+	select {
+	case c.queue <- message:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Pending returns the number of queued messages not yet written
+func (c *ProtectedConnection) Pending() int {
+	return len(c.queue)
+}
+
+// Close stops the writer and closes the connection, the reader then exits on its own
+func (c *ProtectedConnection) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		if c.done != nil {
+			close(c.done)
+		}
+		err = c.Conn.Close()
+	})
+	return err
+}
+
+// runWriter writes queued messages until the connection is closed
+func (c *ProtectedConnection) runWriter() {
+	for {
+		select {
+		case <-c.done:
+			return
+		case message := <-c.queue:
+			c.Mutex.Lock()
+			c.Conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			err := c.write(codec.FrameMessage, []byte(message))
+			c.Mutex.Unlock()
+
+			if err != nil {
+				log.Printf("Error writing to peer %s: %v", c.Conn.RemoteAddr(), err)
+				c.Close()
+				return
+			}
+		}
+	}
+}
+
+// SendLine writes a payload as a JSON line right away, bypassing the queue.
+// It is used for the handshake, which has to precede any other traffic.
+func (c *ProtectedConnection) SendLine(payload []byte) error {
+	data, err := codec.Encode(codec.ModeLine, codec.FrameMessage, payload)
+	if err != nil {
+		return err
+	}
+
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+
+	_, err = c.Conn.Write(data)
+	return err
+}
+
+// SetMode switches the codec used for the following writes
+func (c *ProtectedConnection) SetMode(mode codec.Mode) {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+
+	c.mode = mode
+}
+
+// Mode returns the codec used for writes
+func (c *ProtectedConnection) Mode() codec.Mode {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+
+	return c.mode
+}
+
+func (c *ProtectedConnection) write(frameType byte, payload []byte) error {
+	data, err := codec.Encode(c.mode, frameType, payload)
+	if err != nil {
+		return err
+	}
+	_, err = c.Conn.Write(data)
+	return err
+}
+
 // PeerConnection represents a connection to a peer
 type PeerConnection struct {
 	Addr     net.Addr
 	Conn     *ProtectedConnection
 	LastSeen time.Time
-	Buffer   string
+
+	// Handshake of the peer, nil for legacy peers that do not send one
+	Handshake *handshake.Handshake
+
+	// Keepalive state: nonce of the unanswered ping, when it was sent,
+	// pings in a row left without a pong and the last measured round trip
+	PingNonce   uint64
+	PingSentAt  time.Time
+	MissedPongs int
+	RTT         time.Duration
 }
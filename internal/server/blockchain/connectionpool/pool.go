@@ -1,23 +1,44 @@
 package connectionpool
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"net"
 	"sender/internal/server/blockchain/connectionpool/message"
 	"sender/internal/server/blockchain/connectionpool/peer"
+	"sender/internal/server/blockchain/handshake"
 	protocolmessage "sender/internal/server/blockchain/protocol/message"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 )
 
+const (
+	// DefaultPingInterval is how often peers are pinged and checked for liveness
+	DefaultPingInterval = 15 * time.Second
+	// DefaultMaxMissedPongs is the number of unanswered pings after which a peer is evicted
+	DefaultMaxMissedPongs = 3
+)
+
+// prefixes of keepalive messages, checked before the message is forwarded
+var (
+	pingPrefix = `{"type":"` + string(protocolmessage.RequestPingMessage) + `"`
+	pongPrefix = `{"type":"` + string(protocolmessage.ResponsePongMessage) + `"`
+)
+
 // ConnectionPool manages all peer connections
 type ConnectionPool struct {
 	connections map[string]*peer.PeerConnection
 	mutex       sync.RWMutex
 	timeout     time.Duration
 
+	// Keepalive settings and the last ping nonce
+	pingInterval   time.Duration
+	maxMissedPongs int
+	lastNonce      uint64
+
 	// Channels for pool communication
 	poolChan chan message.PoolMessage
 
@@ -28,33 +49,46 @@ type ConnectionPool struct {
 // NewConnectionPool creates a new connection pool
 func NewConnectionPool(poolChan chan message.PoolMessage, timeoutSecs int64, protocolChan chan<- protocolmessage.Message) ConnectionPool {
 	return ConnectionPool{
-		connections:  make(map[string]*peer.PeerConnection),
-		timeout:      time.Duration(timeoutSecs) * time.Second,
-		poolChan:     poolChan, //make(chan message.PoolMessage, 100),
-		protocolChan: protocolChan,
+		connections:    make(map[string]*peer.PeerConnection),
+		timeout:        time.Duration(timeoutSecs) * time.Second,
+		pingInterval:   DefaultPingInterval,
+		maxMissedPongs: DefaultMaxMissedPongs,
+		poolChan:       poolChan, //make(chan message.PoolMessage, 100),
+		protocolChan:   protocolChan,
 	}
 }
 
+// SetKeepalive sets how often peers are pinged and how many pongs they may miss
+func (cp *ConnectionPool) SetKeepalive(interval time.Duration, maxMissedPongs int) {
+	cp.pingInterval = interval
+	cp.maxMissedPongs = maxMissedPongs
+}
+
 // GetPoolChan returns the channel for sending messages to the pool
 func (cp *ConnectionPool) GetPoolChan() chan<- message.PoolMessage {
 	return cp.poolChan
 }
 
 // addConnection adds a new peer connection to the pool
-func (cp *ConnectionPool) addConnection(addr net.Addr, conn *peer.ProtectedConnection) {
+func (cp *ConnectionPool) addConnection(addr net.Addr, conn *peer.ProtectedConnection, handshake *handshake.Handshake) {
 	addrStr := addr.String()
 
 	cp.mutex.Lock()
 	defer cp.mutex.Unlock()
 
 	cp.connections[addrStr] = &peer.PeerConnection{
-		Addr:     addr,
-		Conn:     conn,
-		LastSeen: time.Now(),
-		Buffer:   "",
+		Addr:      addr,
+		Conn:      conn,
+		LastSeen:  time.Now(),
+		Handshake: handshake,
 	}
 
-	log.Printf("New peer connected: %s, total peers: %d", addrStr, len(cp.connections))
+	if handshake != nil {
+		log.Printf("New peer connected: %s (node %.12s, version %d, height %d), total peers: %d",
+			addrStr, handshake.NodeID, handshake.Version, handshake.Tip.Height, len(cp.connections))
+	} else {
+		log.Printf("New peer connected: %s (legacy), total peers: %d", addrStr, len(cp.connections))
+	}
 }
 
 // removeConnection removes a peer connection from the pool
@@ -95,15 +129,7 @@ func (cp *ConnectionPool) sendToPeer(addr net.Addr, message string) error {
 		return fmt.Errorf("peer not found: %s", addrStr)
 	}
 
-	peer.Conn.Mutex.Lock()
-	defer peer.Conn.Mutex.Unlock()
-
-	if _, err := fmt.Fprintf(peer.Conn.Conn, "%s\n", message); err != nil {
-		return err
-	}
-
-	peer.LastSeen = time.Now()
-	return nil
+	return peer.Conn.Send(message)
 }
 
 // broadcast sends a message to all peers
@@ -118,14 +144,10 @@ func (cp *ConnectionPool) broadcast(message string) {
 	var failedPeers []net.Addr
 
 	for _, peer := range peers {
-		peer.Conn.Mutex.Lock()
-		_, err := fmt.Fprintf(peer.Conn.Conn, "%s\n", message)
-		peer.Conn.Mutex.Unlock()
-
-		if err != nil {
+		if err := peer.Conn.Send(message); err != nil {
+			log.Printf("Dropping peer %s: %v", peer.Addr, err)
+			peer.Conn.Close()
 			failedPeers = append(failedPeers, peer.Addr)
-		} else {
-			peer.LastSeen = time.Now()
 		}
 	}
 
@@ -135,34 +157,138 @@ func (cp *ConnectionPool) broadcast(message string) {
 	}
 }
 
-// cleanupInactive removes inactive connections
+// cleanupInactive evicts peers that sent nothing within the timeout
 func (cp *ConnectionPool) cleanupInactive() {
 	cp.mutex.Lock()
-	defer cp.mutex.Unlock()
-
 	now := time.Now()
-	var inactivePeers []string
+	var inactivePeers []*peer.PeerConnection
 
 	for addrStr, peer := range cp.connections {
 		if now.Sub(peer.LastSeen) > cp.timeout {
-			inactivePeers = append(inactivePeers, addrStr)
+			log.Printf("Inactive peer timeout: %s", addrStr)
+			inactivePeers = append(inactivePeers, peer)
+			delete(cp.connections, addrStr)
 		}
 	}
+	cp.mutex.Unlock()
 
-	for _, addrStr := range inactivePeers {
-		log.Printf("Inactive peer timeout: %s", addrStr)
-		delete(cp.connections, addrStr)
+	// закрытие завершает чтение, сервер сам сообщит об отключении
+	for _, peer := range inactivePeers {
+		peer.Conn.Close()
+	}
+}
+
+// keepalive evicts peers that missed too many pongs and pings the others.
+// Only peers that announced ping in the handshake are pinged.
+func (cp *ConnectionPool) keepalive() {
+	type ping struct {
+		peer    *peer.PeerConnection
+		message string
+	}
+
+	cp.mutex.Lock()
+	now := time.Now()
+	var deadPeers []*peer.PeerConnection
+	var pings []ping
+
+	for addrStr, peer := range cp.connections {
+		if !peer.Handshake.Supports(string(protocolmessage.RequestPingMessage)) {
+			continue
+		}
+
+		if peer.PingNonce != 0 {
+			peer.MissedPongs++
+			if peer.MissedPongs >= cp.maxMissedPongs {
+				log.Printf("Peer %s missed %d pongs, evicting", addrStr, peer.MissedPongs)
+				deadPeers = append(deadPeers, peer)
+				delete(cp.connections, addrStr)
+				continue
+			}
+		}
+
+		cp.lastNonce++
+		pingJSON, err := json.Marshal(protocolmessage.NewPingMessage(cp.lastNonce))
+		if err != nil {
+			log.Printf("Failed to marshal ping: %v", err)
+			continue
+		}
+		peer.PingNonce = cp.lastNonce
+		peer.PingSentAt = now
+		pings = append(pings, ping{peer: peer, message: string(pingJSON)})
+	}
+	cp.mutex.Unlock()
+
+	for _, peer := range deadPeers {
+		peer.Conn.Close()
+	}
+	for _, ping := range pings {
+		if err := ping.peer.Conn.Send(ping.message); err != nil {
+			log.Printf("Failed to ping %s: %v", ping.peer.Addr, err)
+		}
 	}
 }
 
+// PeerStats describes a connected peer
+type PeerStats struct {
+	Addr          string
+	NodeID        string
+	Version       int
+	ListenAddress string
+	Codec         string
+	LastSeen      time.Time
+	// RTT of the last answered ping, zero until the first pong
+	RTT         time.Duration
+	MissedPongs int
+	// Messages queued for the peer and not yet written
+	Queued int
+}
+
+// Peers returns the connected peers sorted by address
+func (cp *ConnectionPool) Peers() []PeerStats {
+	cp.mutex.RLock()
+	stats := make([]PeerStats, 0, len(cp.connections))
+	conns := make([]*peer.ProtectedConnection, 0, len(cp.connections))
+	for _, peer := range cp.connections {
+		peerStats := PeerStats{
+			Addr:        peer.Addr.String(),
+			LastSeen:    peer.LastSeen,
+			RTT:         peer.RTT,
+			MissedPongs: peer.MissedPongs,
+		}
+		if peer.Handshake != nil {
+			peerStats.NodeID = peer.Handshake.NodeID
+			peerStats.Version = peer.Handshake.Version
+			peerStats.ListenAddress = peer.Handshake.ListenAddress
+		}
+		stats = append(stats, peerStats)
+		conns = append(conns, peer.Conn)
+	}
+	cp.mutex.RUnlock()
+
+	// мьютекс соединения занят на время записи, поэтому без блокировки пула
+	for i, conn := range conns {
+		stats[i].Codec = conn.Mode().String()
+		stats[i].Queued = conn.Pending()
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Addr < stats[j].Addr
+	})
+	return stats
+}
+
 // Run starts the connection pool message processing
 func (cp *ConnectionPool) Run() {
+	// Liveness is checked on a fixed ticker, independent of pool traffic
+	ticker := time.NewTicker(cp.pingInterval)
+	defer ticker.Stop()
+
 	for {
 		select {
 		case msg := <-cp.poolChan:
 			switch msg.Type {
 			case message.NewPeer:
-				cp.addConnection(msg.Addr, msg.Conn)
+				cp.addConnection(msg.Addr, msg.Conn, msg.Handshake)
 
 				// Notify the protocol about the new peer
 				peerMsg := protocolmessage.NewPeerMessage(msg.Addr.(*net.TCPAddr).IP.String())
@@ -171,6 +297,9 @@ func (cp *ConnectionPool) Run() {
 				// Request initial message info
 				cp.protocolChan <- protocolmessage.NewInfoMessage()
 
+				// Ask the protocol to catch up with the chain of the new peer
+				cp.protocolChan <- protocolmessage.NewChainRequestMessage(0, "", 0)
+
 			case message.PeerDisconnected:
 				cp.removeConnection(msg.Addr)
 
@@ -178,6 +307,11 @@ func (cp *ConnectionPool) Run() {
 				log.Printf("Broadcasting message: %s", msg.Message)
 				cp.broadcast(msg.Message)
 
+			case message.DirectMessage:
+				if err := cp.sendToPeer(msg.Addr, msg.Message); err != nil {
+					log.Printf("Failed to send message to %s: %v", msg.Addr, err)
+				}
+
 			case message.GetPeers:
 				peers := cp.getPeerAddresses()
 				msg.ResponseChan <- peers
@@ -186,58 +320,84 @@ func (cp *ConnectionPool) Run() {
 				cp.handlePeerMessage(msg.Addr, msg.Message)
 			}
 
-		case <-time.After(600 * time.Second):
-			log.Printf("Pool cleanup triggered")
+		case <-ticker.C:
+			cp.keepalive()
 			cp.cleanupInactive()
 		}
 	}
 }
 
-// handlePeerMessage processes messages from peers
+// handlePeerMessage forwards a complete message from a peer to the protocol.
+// Messages are delimited by the connection codec before they reach the pool.
+// Any inbound message proves the peer is alive; keepalive messages stay in the pool.
 func (cp *ConnectionPool) handlePeerMessage(addr net.Addr, message string) {
 	addrStr := addr.String()
 
 	cp.mutex.Lock()
 	peer, exists := cp.connections[addrStr]
+	if exists {
+		peer.LastSeen = time.Now()
+	}
+	cp.mutex.Unlock()
+
 	if !exists {
-		cp.mutex.Unlock()
 		log.Printf("Message from unknown peer: %s", addrStr)
 		return
 	}
 
-	// Append to buffer and process
-	buffer := peer.Buffer + message
-	peer.Buffer = "" // Clear the buffer
-	cp.mutex.Unlock()
+	switch {
+	case strings.HasPrefix(message, pingPrefix):
+		cp.answerPing(peer, message)
+	case strings.HasPrefix(message, pongPrefix):
+		cp.recordPong(peer, message)
+	default:
+		cp.protocolChan <- protocolmessage.NewRawMessageFrom([]byte(message), addr)
+	}
+}
 
-	messages := []string{}
+// answerPing sends the pong with the nonce of the ping
+func (cp *ConnectionPool) answerPing(peer *peer.PeerConnection, message string) {
+	ping, ok := parsePing(message)
+	if !ok {
+		log.Printf("Invalid ping from %s", peer.Addr)
+		return
+	}
 
-	// Split the buffer into messages by newline
-	for {
-		parts := strings.SplitN(buffer, "\n", 2)
-		if len(parts) == 1 {
-			// No more newlines, store the rest in the buffer
-			cp.mutex.Lock()
-			peer.Buffer = parts[0]
-			cp.mutex.Unlock()
-			break
-		}
+	pongJSON, err := json.Marshal(protocolmessage.NewPongMessage(ping.Nonce))
+	if err != nil {
+		log.Printf("Failed to marshal pong: %v", err)
+		return
+	}
+	if err := peer.Conn.Send(string(pongJSON)); err != nil {
+		log.Printf("Failed to answer ping from %s: %v", peer.Addr, err)
+	}
+}
 
-		// Add the message and continue processing
-		messages = append(messages, parts[0])
-		buffer = parts[1]
+// recordPong measures the round trip of the ping the pong answers
+func (cp *ConnectionPool) recordPong(peer *peer.PeerConnection, message string) {
+	pong, ok := parsePing(message)
+	if !ok {
+		log.Printf("Invalid pong from %s", peer.Addr)
+		return
 	}
 
-	// Process the messages
-	for _, msg := range messages {
-		// Forward to the protocol
-		cp.protocolChan <- protocolmessage.NewRawMessage([]byte(msg))
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
 
-		// Update last seen time
-		cp.mutex.Lock()
-		if peer, exists := cp.connections[addrStr]; exists {
-			peer.LastSeen = time.Now()
-		}
-		cp.mutex.Unlock()
+	// опоздавший понг на предыдущий пинг не учитывается
+	if pong.Nonce == 0 || pong.Nonce != peer.PingNonce {
+		return
+	}
+	peer.RTT = time.Since(peer.PingSentAt)
+	peer.PingNonce = 0
+	peer.MissedPongs = 0
+}
+
+func parsePing(message string) (*protocolmessage.PingMessage, bool) {
+	msg, err := protocolmessage.MessageFromJson([]byte(message))
+	if err != nil {
+		return nil, false
 	}
+	ping, ok := msg.Content.(*protocolmessage.PingMessage)
+	return ping, ok
 }
@@ -1,9 +1,18 @@
 package connectionpool
 
 import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net"
+	"os"
+	"sender/internal/server/blockchain/codec"
 	"sender/internal/server/blockchain/connectionpool/message"
 	"sender/internal/server/blockchain/connectionpool/peer"
+	"sender/internal/server/blockchain/handshake"
 	protocolmsg "sender/internal/server/blockchain/protocol/message"
 	"strings"
 	"sync"
@@ -27,7 +36,7 @@ func TestAddRemovePeer(t *testing.T) {
 	defer c.Close()
 	defer s.Close()
 	prot := &peer.ProtectedConnection{Conn: s, Mutex: &sync.Mutex{}}
-	cp.addConnection(addr, prot)
+	cp.addConnection(addr, prot, nil)
 
 	addrs := cp.getPeerAddresses()
 	if len(addrs) != 1 || addrs[0].String() != addr.String() {
@@ -49,7 +58,7 @@ func TestSendToPeer(t *testing.T) {
 	defer server.Close()
 
 	prot := &peer.ProtectedConnection{Conn: server, Mutex: &sync.Mutex{}}
-	cp.addConnection(addr, prot)
+	cp.addConnection(addr, prot, nil)
 	msg := "hello"
 
 	// concurrent read
@@ -79,14 +88,14 @@ func TestBroadcastAndRemoveFailed(t *testing.T) {
 	c1, s1 := net.Pipe()
 	defer c1.Close()
 	defer s1.Close()
-	cp.addConnection(addr1, &peer.ProtectedConnection{Conn: s1, Mutex: &sync.Mutex{}})
+	cp.addConnection(addr1, &peer.ProtectedConnection{Conn: s1, Mutex: &sync.Mutex{}}, nil)
 
 	// bad peer (client end closed)
 	addr2 := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 9003}
 	c2, s2 := net.Pipe()
 	c2.Close()
 	defer s2.Close()
-	cp.addConnection(addr2, &peer.ProtectedConnection{Conn: s2, Mutex: &sync.Mutex{}})
+	cp.addConnection(addr2, &peer.ProtectedConnection{Conn: s2, Mutex: &sync.Mutex{}}, nil)
 
 	msg := "broadcast"
 	// concurrent read for good peer
@@ -110,13 +119,78 @@ func TestBroadcastAndRemoveFailed(t *testing.T) {
 	}
 }
 
+// TestBroadcastDoesNotWaitForSlowPeer drops a peer whose queue is full instead of blocking
+func TestBroadcastDoesNotWaitForSlowPeer(t *testing.T) {
+	cp, _, _ := setupPool(10)
+
+	// peer that never reads
+	slowAddr := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 9010}
+	_, slowServer := net.Pipe()
+	slow := &peer.ProtectedConnection{Conn: slowServer, Mutex: &sync.Mutex{}}
+	slow.Start(2)
+	defer slow.Close()
+	cp.addConnection(slowAddr, slow, nil)
+
+	fastAddr := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 9011}
+	fastClient, fastServer := net.Pipe()
+	fast := &peer.ProtectedConnection{Conn: fastServer, Mutex: &sync.Mutex{}}
+	fast.Start(peer.DefaultQueueSize)
+	defer fast.Close()
+	cp.addConnection(fastAddr, fast, nil)
+
+	received := make(chan string, 10)
+	go func() {
+		reader := codec.NewReader(fastClient, 0)
+		for {
+			_, payload, _, err := reader.ReadMessage()
+			if err != nil {
+				return
+			}
+			received <- string(payload)
+		}
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 5; i++ {
+			cp.broadcast(fmt.Sprintf("msg-%d", i))
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("broadcast blocked on a slow peer")
+	}
+
+	for i := 0; i < 5; i++ {
+		select {
+		case got := <-received:
+			if got != fmt.Sprintf("msg-%d", i) {
+				t.Errorf("Expected msg-%d, got %s", i, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Fast peer did not receive msg-%d", i)
+		}
+	}
+
+	addrs := cp.getPeerAddresses()
+	if len(addrs) != 1 || addrs[0].String() != fastAddr.String() {
+		t.Errorf("Expected only %s, got %v", fastAddr, addrs)
+	}
+	if err := slow.Send("late"); !errors.Is(err, peer.ErrClosed) {
+		t.Errorf("Expected ErrClosed for a dropped peer, got %v", err)
+	}
+}
+
 // TestCleanupInactive prunes peers not seen within timeout
 func TestCleanupInactive(t *testing.T) {
 	cp, _, _ := setupPool(0)
 	addr := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 9004}
 	_, s := net.Pipe()
 	defer s.Close()
-	cp.addConnection(addr, &peer.ProtectedConnection{Conn: s, Mutex: &sync.Mutex{}})
+	cp.addConnection(addr, &peer.ProtectedConnection{Conn: s, Mutex: &sync.Mutex{}}, nil)
 
 	// simulate outdated LastSeen
 	cp.mutex.Lock()
@@ -129,20 +203,20 @@ func TestCleanupInactive(t *testing.T) {
 	}
 }
 
-// TestHandlePeerMessage splits data on newline and forwards with RawMessage
+// TestHandlePeerMessage forwards complete messages with RawMessage
 func TestHandlePeerMessage(t *testing.T) {
 	cp, _, proto := setupPool(10)
 	addr := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 9005}
 	_, s := net.Pipe()
 	defer s.Close()
-	cp.addConnection(addr, &peer.ProtectedConnection{Conn: s, Mutex: &sync.Mutex{}})
+	cp.addConnection(addr, &peer.ProtectedConnection{Conn: s, Mutex: &sync.Mutex{}}, nil)
 
-	// chunks
-	cp.handlePeerMessage(addr, "one\ntwo\npar")
-	cp.handlePeerMessage(addr, "tial\n")
+	// сообщения уже разделены кодеком соединения, перевод строки внутри не режется
+	cp.handlePeerMessage(addr, "one")
+	cp.handlePeerMessage(addr, "{\n\"pretty\": true\n}")
 
 	var got []string
-	for i := 0; i < 3; i++ {
+	for i := 0; i < 2; i++ {
 		select {
 		case pm := <-proto:
 			rm := pm.Content.(*protocolmsg.RawMessage)
@@ -152,7 +226,7 @@ func TestHandlePeerMessage(t *testing.T) {
 		}
 	}
 
-	exp := []string{"one", "two", "partial"}
+	exp := []string{"one", "{\n\"pretty\": true\n}"}
 	for i := range exp {
 		if got[i] != exp[i] {
 			t.Errorf("Expected %s, got %s", exp[i], got[i])
@@ -160,6 +234,173 @@ func TestHandlePeerMessage(t *testing.T) {
 	}
 }
 
+// TestSendToFramedPeer writes length-prefixed frames once the peer negotiated framing
+func TestSendToFramedPeer(t *testing.T) {
+	cp, _, _ := setupPool(10)
+	addr := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 9009}
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	prot := &peer.ProtectedConnection{Conn: server, Mutex: &sync.Mutex{}}
+	prot.SetMode(codec.ModeFramed)
+	cp.addConnection(addr, prot, nil)
+
+	msg := "{\"type\":\"Block\"}"
+	received := make(chan []byte, 1)
+	go func() {
+		_, payload, _, _ := codec.NewReader(client, 0).ReadMessage()
+		received <- payload
+	}()
+
+	if err := cp.sendToPeer(addr, msg); err != nil {
+		t.Fatalf("sendToPeer failed: %v", err)
+	}
+	if got := string(<-received); got != msg {
+		t.Errorf("Expected '%s', got '%s'", msg, got)
+	}
+}
+
+// pingingPeer adds a queued peer that announced ping and returns the reader of its side
+func pingingPeer(t *testing.T, cp *ConnectionPool, port int) (net.Addr, *peer.ProtectedConnection, *codec.Reader) {
+	t.Helper()
+
+	addr := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: port}
+	client, server := net.Pipe()
+	t.Cleanup(func() { client.Close() })
+
+	conn := &peer.ProtectedConnection{Conn: server, Mutex: &sync.Mutex{}}
+	conn.Start(peer.DefaultQueueSize)
+	t.Cleanup(func() { conn.Close() })
+
+	hs := &handshake.Handshake{NodeID: "node", MessageTypes: []string{string(protocolmsg.RequestPingMessage)}}
+	cp.addConnection(addr, conn, hs)
+	return addr, conn, codec.NewReader(client, 0)
+}
+
+func readPing(t *testing.T, reader *codec.Reader, expected protocolmsg.MessageType) uint64 {
+	t.Helper()
+
+	_, payload, _, err := reader.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage failed: %v", err)
+	}
+	msg, err := protocolmsg.MessageFromJson(payload)
+	if err != nil || msg.Type != expected {
+		t.Fatalf("Expected %s, got %s: %v", expected, payload, err)
+	}
+	return msg.Content.(*protocolmsg.PingMessage).Nonce
+}
+
+// TestKeepalivePingPong measures RTT from the pong and keeps keepalive away from the protocol
+func TestKeepalivePingPong(t *testing.T) {
+	cp, _, proto := setupPool(10)
+	addr, _, reader := pingingPeer(t, cp, 9020)
+
+	// старые пиры без рукопожатия не пингуются
+	legacyAddr := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 9021}
+	_, legacyServer := net.Pipe()
+	defer legacyServer.Close()
+	cp.addConnection(legacyAddr, &peer.ProtectedConnection{Conn: legacyServer, Mutex: &sync.Mutex{}}, nil)
+
+	cp.mutex.Lock()
+	cp.connections[addr.String()].LastSeen = time.Now().Add(-time.Minute)
+	cp.mutex.Unlock()
+
+	go cp.keepalive()
+	nonce := readPing(t, reader, protocolmsg.RequestPingMessage)
+
+	time.Sleep(5 * time.Millisecond)
+	pong, _ := json.Marshal(protocolmsg.NewPongMessage(nonce))
+	cp.handlePeerMessage(addr, string(pong))
+
+	stats := cp.Peers()
+	if len(stats) != 2 || stats[0].Addr != addr.String() {
+		t.Fatalf("Unexpected peers %+v", stats)
+	}
+	if stats[0].RTT < 5*time.Millisecond || stats[0].MissedPongs != 0 || stats[0].NodeID != "node" {
+		t.Errorf("Unexpected stats %+v", stats[0])
+	}
+	if time.Since(stats[0].LastSeen) > time.Second {
+		t.Errorf("Inbound pong must update LastSeen, got %s", stats[0].LastSeen)
+	}
+	if cp.connections[legacyAddr.String()].PingNonce != 0 {
+		t.Error("Legacy peer was pinged")
+	}
+
+	select {
+	case msg := <-proto:
+		t.Errorf("Keepalive reached the protocol: %v", msg.Type)
+	default:
+	}
+}
+
+// TestKeepaliveAnswersPing replies with the nonce of the ping
+func TestKeepaliveAnswersPing(t *testing.T) {
+	cp, _, _ := setupPool(10)
+	addr, _, reader := pingingPeer(t, cp, 9022)
+
+	ping, _ := json.Marshal(protocolmsg.NewPingMessage(7))
+	cp.handlePeerMessage(addr, string(ping))
+
+	if nonce := readPing(t, reader, protocolmsg.ResponsePongMessage); nonce != 7 {
+		t.Errorf("Expected pong nonce 7, got %d", nonce)
+	}
+}
+
+// TestKeepaliveEvictsPeerMissingPongs closes peers that stop answering
+func TestKeepaliveEvictsPeerMissingPongs(t *testing.T) {
+	cp, _, _ := setupPool(10)
+	cp.SetKeepalive(time.Hour, 2)
+	addr, conn, reader := pingingPeer(t, cp, 9023)
+	go func() {
+		for {
+			if _, _, _, err := reader.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	cp.keepalive()
+	cp.keepalive()
+	if stats := cp.Peers(); len(stats) != 1 || stats[0].MissedPongs != 1 {
+		t.Fatalf("Expected one missed pong, got %+v", stats)
+	}
+
+	cp.keepalive()
+	if len(cp.getPeerAddresses()) != 0 {
+		t.Fatalf("Expected %s to be evicted", addr)
+	}
+	if err := conn.Send("late"); !errors.Is(err, peer.ErrClosed) {
+		t.Errorf("Expected evicted connection to be closed, got %v", err)
+	}
+}
+
+// TestRunEvictsOnTicker checks liveness without any pool traffic
+func TestRunEvictsOnTicker(t *testing.T) {
+	cp, _, _ := setupPool(10)
+	cp.SetKeepalive(10*time.Millisecond, 1)
+	_, _, reader := pingingPeer(t, cp, 9024)
+	go func() {
+		for {
+			if _, _, _, err := reader.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	go cp.Run()
+
+	deadline := time.After(time.Second)
+	for len(cp.getPeerAddresses()) != 0 {
+		select {
+		case <-deadline:
+			t.Fatal("Silent peer was not evicted")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
+
 // TestGetPeers verifies GetPeers via poolChan and response channel
 func TestGetPeers(t *testing.T) {
 	cp, poolChan, _ := setupPool(10)
@@ -169,8 +410,8 @@ func TestGetPeers(t *testing.T) {
 	_, s2 := net.Pipe()
 	defer s1.Close()
 	defer s2.Close()
-	cp.addConnection(addr1, &peer.ProtectedConnection{Conn: s1, Mutex: &sync.Mutex{}})
-	cp.addConnection(addr2, &peer.ProtectedConnection{Conn: s2, Mutex: &sync.Mutex{}})
+	cp.addConnection(addr1, &peer.ProtectedConnection{Conn: s1, Mutex: &sync.Mutex{}}, nil)
+	cp.addConnection(addr2, &peer.ProtectedConnection{Conn: s2, Mutex: &sync.Mutex{}}, nil)
 
 	resp := make(chan []net.Addr, 1)
 	poolChan <- message.PoolMessage{Type: message.GetPeers, ResponseChan: resp}
@@ -201,3 +442,133 @@ func TestRunNewPeerAndProtocol(t *testing.T) {
 		t.Errorf("Expected ResponseMessageInfo, got %v", second.Type)
 	}
 }
+
+// legacyReadHold and legacyReadPause model the reader of the old server: it held the
+// connection mutex during a read with a 500ms deadline and slept 100ms after a timeout.
+// Both are scaled down 100 times to keep the benchmark short.
+const (
+	legacyReadHold  = 5 * time.Millisecond
+	legacyReadPause = time.Millisecond
+)
+
+// holdLikeLegacyReader locks the connection mutex the way the old read loop did
+// for an idle peer, until done is closed
+func holdLikeLegacyReader(conn *peer.ProtectedConnection, done chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		conn.Mutex.Lock()
+		time.Sleep(legacyReadHold)
+		conn.Mutex.Unlock()
+		time.Sleep(legacyReadPause)
+	}
+}
+
+// legacyBroadcast is the broadcast of the pool before the per-peer writer, kept as it
+// was: every peer is written in turn under its connection mutex
+func legacyBroadcast(cp *ConnectionPool, message string) {
+	cp.mutex.RLock()
+	peers := make([]*peer.PeerConnection, 0, len(cp.connections))
+	for _, peer := range cp.connections {
+		peers = append(peers, peer)
+	}
+	cp.mutex.RUnlock()
+
+	var failedPeers []net.Addr
+
+	for _, peer := range peers {
+		peer.Conn.Mutex.Lock()
+		_, err := fmt.Fprintf(peer.Conn.Conn, "%s\n", message)
+		peer.Conn.Mutex.Unlock()
+
+		if err != nil {
+			failedPeers = append(failedPeers, peer.Addr)
+		} else {
+			peer.LastSeen = time.Now()
+		}
+	}
+
+	// Remove failed peers
+	for _, addr := range failedPeers {
+		cp.removeConnection(addr)
+	}
+}
+
+// BenchmarkBroadcast measures the time from a broadcast until every peer has read the message.
+// "legacy" is the pre-change path: legacyBroadcast against readers that hold the
+// connection mutex like the old read loop. "queued" is the current broadcast through
+// the per-peer writers, readers take no lock.
+func BenchmarkBroadcast(b *testing.B) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	for _, peers := range []int{10, 100, 1000} {
+		for _, queued := range []bool{false, true} {
+			name := fmt.Sprintf("peers=%d/legacy", peers)
+			if queued {
+				name = fmt.Sprintf("peers=%d/queued", peers)
+			}
+			b.Run(name, func(b *testing.B) {
+				benchmarkBroadcast(b, peers, queued)
+			})
+		}
+	}
+}
+
+func benchmarkBroadcast(b *testing.B, peers int, queued bool) {
+	cp, _, _ := setupPool(10)
+
+	// каждый пир сообщает о полностью прочитанной строке
+	received := make(chan struct{}, peers)
+	readersDone := make(chan struct{})
+	conns := make([]*peer.ProtectedConnection, 0, peers)
+	for i := 0; i < peers; i++ {
+		client, server := net.Pipe()
+		go func() {
+			reader := bufio.NewReader(client)
+			for {
+				if _, err := reader.ReadString('\n'); err != nil {
+					return
+				}
+				received <- struct{}{}
+			}
+		}()
+
+		conn := &peer.ProtectedConnection{Conn: server, Mutex: &sync.Mutex{}}
+		if queued {
+			conn.Start(peer.DefaultQueueSize)
+		} else {
+			go holdLikeLegacyReader(conn, readersDone)
+		}
+		cp.addConnection(&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 10000 + i}, conn, nil)
+		conns = append(conns, conn)
+	}
+	defer func() {
+		close(readersDone)
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+
+	msg := strings.Repeat("x", 512)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if queued {
+			cp.broadcast(msg)
+		} else {
+			legacyBroadcast(cp, msg)
+		}
+		for j := 0; j < peers; j++ {
+			<-received
+		}
+	}
+	b.StopTimer()
+
+	if len(cp.getPeerAddresses()) != peers {
+		b.Fatalf("Expected %d peers, got %d", peers, len(cp.getPeerAddresses()))
+	}
+}
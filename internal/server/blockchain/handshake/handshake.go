@@ -0,0 +1,368 @@
+package handshake
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"sender/internal/data/blockchain/wallet"
+	"sender/internal/server/blockchain/codec"
+	"sender/internal/server/blockchain/protocol/message"
+)
+
+const (
+	// Type is the message type of the handshake. Peers that do not know it
+	// treat it as an unknown protocol message and ignore it.
+	Type = "Handshake"
+	// RejectType is sent instead of traffic when the peer is refused
+	RejectType = "HandshakeReject"
+	// ProofType answers the challenge of the peer handshake
+	ProofType = "HandshakeProof"
+
+	// ProtocolVersion is the version this node speaks
+	ProtocolVersion = 1
+	// MinProtocolVersion is the oldest version this node accepts
+	MinProtocolVersion = 1
+
+	// MaxClockSkew is how far the handshake time may be from the local clock
+	MaxClockSkew = 2 * time.Minute
+	// challengeSize is the number of random bytes of a handshake challenge
+	challengeSize = 32
+	// proofDomain separates proof signatures from other signed payloads
+	proofDomain = "sender-handshake-proof"
+)
+
+var (
+	ErrIncompatibleVersion = errors.New("incompatible protocol version")
+	ErrInvalidSignature    = errors.New("invalid handshake signature")
+	ErrNodeIDMismatch      = errors.New("node id does not match the public key")
+	ErrSelfConnection      = errors.New("connection to self")
+	ErrHandshakeRequired   = errors.New("handshake required")
+	ErrStaleHandshake      = errors.New("handshake time is outside the allowed clock skew")
+	ErrInvalidChallenge    = errors.New("invalid handshake challenge")
+	ErrInvalidProof        = errors.New("invalid handshake proof")
+)
+
+// prefixes of the handshake lines, checked before parsing
+var (
+	handshakePrefix = []byte(`{"type":"` + Type + `"`)
+	rejectPrefix    = []byte(`{"type":"` + RejectType + `"`)
+	proofPrefix     = []byte(`{"type":"` + ProofType + `"`)
+)
+
+// Tip is the head of the best chain of a node
+type Tip struct {
+	Height int    `json:"height"`
+	Hash   string `json:"hash"`
+}
+
+// Handshake is the first message on every connection. It tells the peer
+// who we are, what we speak and how far our chain is, signed by the node key.
+// The random challenge must be signed back by the peer in its Proof, so a
+// recorded handshake cannot be replayed on another connection.
+type Handshake struct {
+	ID            uint64           `json:"id"`
+	TimeStamp     int64            `json:"time_stamp"`
+	Version       int              `json:"version"`
+	Codecs        []string         `json:"codecs"`
+	MaxFrameSize  int              `json:"max_frame_size"`
+	MessageTypes  []string         `json:"message_types"`
+	NodeID        string           `json:"node_id"`
+	PublicKey     string           `json:"public_key"`
+	Algorithm     wallet.Algorithm `json:"algorithm"`
+	ListenAddress string           `json:"listen_address"`
+	Tip           Tip              `json:"tip"`
+	Challenge     string           `json:"challenge"`
+	Signature     string           `json:"signature"`
+}
+
+// Proof is the answer to the challenge of the peer handshake
+type Proof struct {
+	ID        uint64 `json:"id"`
+	TimeStamp int64  `json:"time_stamp"`
+	NodeID    string `json:"node_id"`
+	Signature string `json:"signature"`
+}
+
+// Reject tells the peer why the connection is refused
+type Reject struct {
+	ID        uint64 `json:"id"`
+	TimeStamp int64  `json:"time_stamp"`
+	Reason    string `json:"reason"`
+}
+
+type envelope[T any] struct {
+	Type    string `json:"type"`
+	Content T      `json:"content"`
+}
+
+// New creates the signed handshake of the node
+func New(w *wallet.Wallet, listenAddress string, tip Tip) (*Handshake, error) {
+	publicKey := w.Serialize().PublicKey
+
+	types := make([]string, 0, len(message.SupportedTypes))
+	for _, messageType := range message.SupportedTypes {
+		types = append(types, string(messageType))
+	}
+
+	challenge := make([]byte, challengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, err
+	}
+
+	h := &Handshake{
+		TimeStamp:     time.Now().UTC().Unix(),
+		Version:       ProtocolVersion,
+		Codecs:        []string{codec.NameFramed, codec.NameLine},
+		MaxFrameSize:  codec.DefaultMaxFrameSize,
+		MessageTypes:  types,
+		NodeID:        NodeID(publicKey),
+		PublicKey:     publicKey,
+		Algorithm:     w.Algorithm,
+		ListenAddress: listenAddress,
+		Tip:           tip,
+		Challenge:     base64.RawStdEncoding.EncodeToString(challenge),
+	}
+	if err := h.Sign(w); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Sign signs the handshake with the node key
+func (h *Handshake) Sign(w *wallet.Wallet) error {
+	signature, err := w.Sign(h.SigningPayload())
+	if err != nil {
+		return err
+	}
+	h.Signature = base64.RawStdEncoding.EncodeToString(signature)
+	return nil
+}
+
+// NodeID returns the hex encoded SHA-256 of the serialized public key
+func NodeID(publicKey string) string {
+	sum := sha256.Sum256([]byte(publicKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// SigningPayload returns the signed bytes, every integer is big-endian:
+//
+//	u32  version
+//	i64  time_stamp
+//	u32  max_frame_size
+//	u32  count | u32 len(codec) | codec ...
+//	u32  count | u32 len(type)  | type ...
+//	u32  len(node_id)        | node_id
+//	u32  len(public_key)     | public_key
+//	u32  len(algorithm)      | algorithm
+//	u32  len(listen_address) | listen_address
+//	i64  tip height
+//	u32  len(tip hash)       | tip hash
+//	u32  len(challenge)      | challenge
+func (h *Handshake) SigningPayload() []byte {
+	payload := binary.BigEndian.AppendUint32(nil, uint32(h.Version))
+	payload = binary.BigEndian.AppendUint64(payload, uint64(h.TimeStamp))
+	payload = binary.BigEndian.AppendUint32(payload, uint32(h.MaxFrameSize))
+	payload = appendList(payload, h.Codecs)
+	payload = appendList(payload, h.MessageTypes)
+	for _, field := range []string{h.NodeID, h.PublicKey, string(h.Algorithm), h.ListenAddress} {
+		payload = appendString(payload, field)
+	}
+	payload = binary.BigEndian.AppendUint64(payload, uint64(h.Tip.Height))
+	payload = appendString(payload, h.Tip.Hash)
+	return appendString(payload, h.Challenge)
+}
+
+// Verify checks that the node id belongs to the public key and the key signed the handshake
+func (h *Handshake) Verify() error {
+	if NodeID(h.PublicKey) != h.NodeID {
+		return ErrNodeIDMismatch
+	}
+
+	key, err := wallet.ParsePublicKey(h.PublicKey)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	signature, err := base64.RawStdEncoding.DecodeString(h.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if err := wallet.Verify(h.Algorithm, key, h.SigningPayload(), signature); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	return nil
+}
+
+// Compatible checks that this node can talk to the peer
+func (h *Handshake) Compatible() error {
+	if h.Version < MinProtocolVersion || h.Version > ProtocolVersion {
+		return fmt.Errorf("%w: peer speaks version %d, supported %d..%d",
+			ErrIncompatibleVersion, h.Version, MinProtocolVersion, ProtocolVersion)
+	}
+	return nil
+}
+
+// Accept checks the handshake of a peer before any other traffic is exchanged.
+// The peer identity is proven only by its Proof of our challenge.
+func (h *Handshake) Accept(localNodeID string) error {
+	if err := h.Compatible(); err != nil {
+		return err
+	}
+	if err := h.Verify(); err != nil {
+		return err
+	}
+
+	sent := time.Unix(h.TimeStamp, 0)
+	if skew := time.Since(sent); skew > MaxClockSkew || skew < -MaxClockSkew {
+		return fmt.Errorf("%w: sent %s", ErrStaleHandshake, sent.UTC().Format(time.RFC3339))
+	}
+	if challenge, err := base64.RawStdEncoding.DecodeString(h.Challenge); err != nil || len(challenge) != challengeSize {
+		return ErrInvalidChallenge
+	}
+
+	if h.NodeID == localNodeID {
+		return ErrSelfConnection
+	}
+	return nil
+}
+
+// NewProof signs the challenge of the peer handshake with the node key
+func NewProof(w *wallet.Wallet, peer *Handshake) (*Proof, error) {
+	nodeID := NodeID(w.Serialize().PublicKey)
+
+	signature, err := w.Sign(proofPayload(peer.Challenge, nodeID, peer.NodeID))
+	if err != nil {
+		return nil, err
+	}
+	return &Proof{
+		TimeStamp: time.Now().UTC().Unix(),
+		NodeID:    nodeID,
+		Signature: base64.RawStdEncoding.EncodeToString(signature),
+	}, nil
+}
+
+// Verify checks that the peer of the handshake signed our challenge for us
+func (p *Proof) Verify(local *Handshake, peer *Handshake) error {
+	if p.NodeID != peer.NodeID {
+		return fmt.Errorf("%w: from node %s, handshake of node %s", ErrInvalidProof, p.NodeID, peer.NodeID)
+	}
+
+	key, err := wallet.ParsePublicKey(peer.PublicKey)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	signature, err := base64.RawStdEncoding.DecodeString(p.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	if err := wallet.Verify(peer.Algorithm, key, proofPayload(local.Challenge, peer.NodeID, local.NodeID), signature); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	return nil
+}
+
+// Encode returns the proof as a JSON line payload
+func (p *Proof) Encode() ([]byte, error) {
+	return json.Marshal(envelope[*Proof]{Type: ProofType, Content: p})
+}
+
+// proofPayload binds the challenge to both sides, so a proof made for one
+// node cannot be relayed to another:
+//
+//	u32  len(domain)    | domain
+//	u32  len(challenge) | challenge
+//	u32  len(prover)    | prover node id
+//	u32  len(verifier)  | verifier node id
+func proofPayload(challenge string, prover string, verifier string) []byte {
+	payload := appendString(nil, proofDomain)
+	for _, field := range []string{challenge, prover, verifier} {
+		payload = appendString(payload, field)
+	}
+	return payload
+}
+
+// Supports reports whether the peer announced the message type
+func (h *Handshake) Supports(messageType string) bool {
+	return h != nil && slices.Contains(h.MessageTypes, messageType)
+}
+
+// Mode returns the best codec both sides support
+func (h *Handshake) Mode() codec.Mode {
+	if slices.Contains(h.Codecs, codec.NameFramed) {
+		return codec.ModeFramed
+	}
+	return codec.ModeLine
+}
+
+// Encode returns the handshake as a JSON line payload
+func (h *Handshake) Encode() ([]byte, error) {
+	return json.Marshal(envelope[*Handshake]{Type: Type, Content: h})
+}
+
+// EncodeReject returns the rejection as a JSON line payload
+func EncodeReject(reason string) ([]byte, error) {
+	return json.Marshal(envelope[Reject]{
+		Type:    RejectType,
+		Content: Reject{TimeStamp: time.Now().UTC().Unix(), Reason: reason},
+	})
+}
+
+// Parse returns the handshake if the message is one
+func Parse(payload []byte) (*Handshake, bool) {
+	if !bytes.HasPrefix(payload, handshakePrefix) {
+		return nil, false
+	}
+
+	var msg envelope[*Handshake]
+	if err := json.Unmarshal(payload, &msg); err != nil || msg.Type != Type || msg.Content == nil {
+		return nil, false
+	}
+	return msg.Content, true
+}
+
+// ParseProof returns the proof if the message is one
+func ParseProof(payload []byte) (*Proof, bool) {
+	if !bytes.HasPrefix(payload, proofPrefix) {
+		return nil, false
+	}
+
+	var msg envelope[*Proof]
+	if err := json.Unmarshal(payload, &msg); err != nil || msg.Type != ProofType || msg.Content == nil {
+		return nil, false
+	}
+	return msg.Content, true
+}
+
+// ParseReject returns the reason if the message is a rejection
+func ParseReject(payload []byte) (string, bool) {
+	if !bytes.HasPrefix(payload, rejectPrefix) {
+		return "", false
+	}
+
+	var msg envelope[Reject]
+	if err := json.Unmarshal(payload, &msg); err != nil || msg.Type != RejectType {
+		return "", false
+	}
+	return msg.Content.Reason, true
+}
+
+func appendString(payload []byte, value string) []byte {
+	payload = binary.BigEndian.AppendUint32(payload, uint32(len(value)))
+	return append(payload, value...)
+}
+
+func appendList(payload []byte, values []string) []byte {
+	payload = binary.BigEndian.AppendUint32(payload, uint32(len(values)))
+	for _, value := range values {
+		payload = appendString(payload, value)
+	}
+	return payload
+}
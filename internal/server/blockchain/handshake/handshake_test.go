@@ -0,0 +1,172 @@
+package handshake_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"sender/internal/data/blockchain/wallet"
+	"sender/internal/server/blockchain/codec"
+	"sender/internal/server/blockchain/handshake"
+	"sender/internal/server/blockchain/protocol/message"
+)
+
+func newHandshake(t *testing.T) (*handshake.Handshake, *wallet.Wallet) {
+	t.Helper()
+
+	w, err := wallet.NewWithAlgorithm(wallet.Ed25519)
+	if err != nil {
+		t.Fatalf("NewWithAlgorithm failed: %v", err)
+	}
+	hs, err := handshake.New(w, "10.0.0.1:7878", handshake.Tip{Height: 42, Hash: "00ab"})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return hs, w
+}
+
+func TestHandshakeRoundTrip(t *testing.T) {
+	hs, w := newHandshake(t)
+
+	payload, err := hs.Encode()
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	received, ok := handshake.Parse(payload)
+	if !ok {
+		t.Fatalf("Handshake not recognized: %s", payload)
+	}
+
+	if err := received.Accept("other-node"); err != nil {
+		t.Fatalf("Accept failed: %v", err)
+	}
+	if received.NodeID != handshake.NodeID(w.Serialize().PublicKey) {
+		t.Errorf("Unexpected node id %s", received.NodeID)
+	}
+	if received.Version != handshake.ProtocolVersion || received.Mode() != codec.ModeFramed {
+		t.Errorf("Unexpected version %d or codec %s", received.Version, received.Mode())
+	}
+	if received.ListenAddress != "10.0.0.1:7878" || received.Tip.Height != 42 || received.Tip.Hash != "00ab" {
+		t.Errorf("Unexpected address or tip: %+v", received)
+	}
+	if len(received.MessageTypes) != len(message.SupportedTypes) {
+		t.Errorf("Expected %d message types, got %v", len(message.SupportedTypes), received.MessageTypes)
+	}
+
+	// старые узлы видят рукопожатие как неизвестное сообщение
+	legacy, err := message.MessageFromJson(payload)
+	if err != nil || legacy.Type != handshake.Type {
+		t.Errorf("Handshake must parse as a protocol message: %v", err)
+	}
+}
+
+func TestHandshakeIsSigned(t *testing.T) {
+	hs, _ := newHandshake(t)
+	hs.Tip.Height = 1000
+	if err := hs.Verify(); !errors.Is(err, handshake.ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature for a changed tip, got %v", err)
+	}
+
+	hs, _ = newHandshake(t)
+	other, _ := newHandshake(t)
+	hs.PublicKey = other.PublicKey
+	if err := hs.Verify(); !errors.Is(err, handshake.ErrNodeIDMismatch) {
+		t.Errorf("Expected ErrNodeIDMismatch, got %v", err)
+	}
+
+	hs.NodeID = other.NodeID
+	if err := hs.Verify(); !errors.Is(err, handshake.ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature for a foreign key, got %v", err)
+	}
+}
+
+func TestHandshakeAccept(t *testing.T) {
+	hs, _ := newHandshake(t)
+	if err := hs.Accept(hs.NodeID); !errors.Is(err, handshake.ErrSelfConnection) {
+		t.Errorf("Expected ErrSelfConnection, got %v", err)
+	}
+
+	hs.Version = handshake.ProtocolVersion + 1
+	err := hs.Accept("other-node")
+	if !errors.Is(err, handshake.ErrIncompatibleVersion) {
+		t.Fatalf("Expected ErrIncompatibleVersion, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "version 2") {
+		t.Errorf("Reason should name the peer version: %v", err)
+	}
+}
+
+func TestHandshakeReplay(t *testing.T) {
+	// записанное давно рукопожатие отклоняется по времени
+	old, w := newHandshake(t)
+	old.TimeStamp = time.Now().Add(-time.Hour).Unix()
+	old.Sign(w)
+	if err := old.Accept("other-node"); !errors.Is(err, handshake.ErrStaleHandshake) {
+		t.Errorf("Expected ErrStaleHandshake, got %v", err)
+	}
+
+	unchallenged, w := newHandshake(t)
+	unchallenged.Challenge = ""
+	unchallenged.Sign(w)
+	if err := unchallenged.Accept("other-node"); !errors.Is(err, handshake.ErrInvalidChallenge) {
+		t.Errorf("Expected ErrInvalidChallenge, got %v", err)
+	}
+
+	// свежее рукопожатие доказывается только ответом на вызов этого соединения
+	peer, peerWallet := newHandshake(t)
+	local, _ := newHandshake(t)
+	proof, err := handshake.NewProof(peerWallet, local)
+	if err != nil {
+		t.Fatalf("NewProof failed: %v", err)
+	}
+	if err := proof.Verify(local, peer); err != nil {
+		t.Fatalf("Expected proof to verify, got %v", err)
+	}
+
+	payload, _ := proof.Encode()
+	received, ok := handshake.ParseProof(payload)
+	if !ok {
+		t.Fatalf("Proof not recognized: %s", payload)
+	}
+	if _, ok := handshake.Parse(payload); ok {
+		t.Error("Proof recognized as handshake")
+	}
+
+	// повтор ответа в другом соединении: вызов другой
+	next, _ := newHandshake(t)
+	if err := received.Verify(next, peer); !errors.Is(err, handshake.ErrInvalidProof) {
+		t.Errorf("Expected ErrInvalidProof for another challenge, got %v", err)
+	}
+
+	// ответ, подписанный для другого узла, не подходит, даже с тем же вызовом
+	relayed := *next
+	relayed.NodeID = "relay-node"
+	relayedProof, _ := handshake.NewProof(peerWallet, &relayed)
+	if err := relayedProof.Verify(next, peer); !errors.Is(err, handshake.ErrInvalidProof) {
+		t.Errorf("Expected ErrInvalidProof for a relayed proof, got %v", err)
+	}
+
+	other, _ := newHandshake(t)
+	if err := received.Verify(local, other); !errors.Is(err, handshake.ErrInvalidProof) {
+		t.Errorf("Expected ErrInvalidProof for a foreign node, got %v", err)
+	}
+}
+
+func TestReject(t *testing.T) {
+	payload, err := handshake.EncodeReject("incompatible protocol version")
+	if err != nil {
+		t.Fatalf("EncodeReject failed: %v", err)
+	}
+
+	reason, ok := handshake.ParseReject(payload)
+	if !ok || reason != "incompatible protocol version" {
+		t.Errorf("Unexpected reject %q", reason)
+	}
+	if _, ok := handshake.Parse(payload); ok {
+		t.Error("Reject recognized as handshake")
+	}
+	if _, ok := handshake.ParseReject([]byte(`{"type":"ResponseMessageInfo","content":{}}`)); ok {
+		t.Error("Info message recognized as reject")
+	}
+}
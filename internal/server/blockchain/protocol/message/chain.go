@@ -6,3 +6,11 @@ type ChainMessage struct {
 	BaseMessage
 	Chain []block.Block `json:"chain"`
 }
+
+// ChainRequestMessage asks a peer for blocks of its best chain
+type ChainRequestMessage struct {
+	BaseMessage
+	FromHeight int    `json:"from_height"`
+	AfterHash  string `json:"after_hash,omitempty"`
+	Limit      int    `json:"limit"`
+}
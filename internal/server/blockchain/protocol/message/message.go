@@ -3,6 +3,7 @@ package message
 import (
 	"encoding/json"
 	"log"
+	"net"
 	"sender/internal/data/blockchain/block"
 	"sender/internal/data/blockchain/transaction"
 )
@@ -52,6 +53,15 @@ func MessageFromJson(messageJson []byte) (*Message, error) {
 	case ResponseTextMessage:
 		var textMessage TextMessage
 		messageRes = &textMessage
+	case RequestChainMessage:
+		var chainRequestMessage ChainRequestMessage
+		messageRes = &chainRequestMessage
+	case ResponseChainMessage:
+		var chainMessage ChainMessage
+		messageRes = &chainMessage
+	case RequestPingMessage, ResponsePongMessage:
+		var pingMessage PingMessage
+		messageRes = &pingMessage
 	default:
 		var baseMessage BaseMessage
 		messageRes = &baseMessage
@@ -59,7 +69,7 @@ func MessageFromJson(messageJson []byte) (*Message, error) {
 
 	if err := json.Unmarshal(body.Content, &messageRes); err != nil {
 		log.Printf("Failed to parse message %s: %v", body.Type, err)
-		log.Fatalf("Message json: %s ", body.Content)
+		log.Printf("Message json: %s ", body.Content)
 		return nil, err
 	}
 
@@ -131,6 +141,39 @@ func NewPeerMessage(ipAddr string) Message {
 	}
 }
 
+func NewChainRequestMessage(fromHeight int, afterHash string, limit int) Message {
+	chainRequestMessage := ChainRequestMessage{
+		BaseMessage: *NewBaseMessage(),
+		FromHeight:  fromHeight,
+		AfterHash:   afterHash,
+		Limit:       limit,
+	}
+
+	return Message{
+		Type:    RequestChainMessage,
+		Content: &chainRequestMessage,
+	}
+}
+
+func NewChainMessage(blocks []block.Block) Message {
+	chainMessage := ChainMessage{
+		BaseMessage: *NewBaseMessage(),
+		Chain:       blocks,
+	}
+
+	return Message{
+		Type:    ResponseChainMessage,
+		Content: &chainMessage,
+	}
+}
+
+// NewRawMessageFrom creates a raw message received from the given peer
+func NewRawMessageFrom(jsonMessage []byte, addr net.Addr) Message {
+	rawMessage := NewRawMessage(jsonMessage)
+	rawMessage.Content.(*RawMessage).Addr = addr
+	return rawMessage
+}
+
 func NewRawMessage(jsonMessage []byte) Message {
 	rawMessage := RawMessage{
 		BaseMessage: *NewBaseMessage(),
@@ -10,6 +10,7 @@ import (
 	"sender/internal/data/blockchain/transaction"
 	"sender/internal/data/blockchain/wallet"
 	"sender/internal/data/deal"
+	"sender/internal/data/decimal"
 	"sender/internal/data/order"
 	"sync/atomic" // Используем для безопасного инкремента ID в NewBaseMessage
 	"testing"
@@ -25,11 +26,11 @@ func TestMessageFromJson(t *testing.T) {
 	w := wallet.New()
 
 	// Создаем транзакцию и связанные объекты
-	buyOrder := &order.Order{ID: 1, UserHashPublicKey: w.Sereliaze().PublicKey, CryptocurrencyCode: "BTC", TypeName: "buy", UnitPrice: 50000.0, Quantity: 0.1}
-	sellOrder := &order.Order{ID: 2, UserHashPublicKey: w.Sereliaze().PublicKey, CryptocurrencyCode: "BTC", TypeName: "sell", UnitPrice: 50000.0, Quantity: 0.1}
+	buyOrder := &order.Order{ID: 1, UserHashPublicKey: w.Sereliaze().PublicKey, CryptocurrencyCode: "BTC", TypeName: "buy", UnitPrice: decimal.MustParse("50000.0"), Quantity: decimal.MustParse("0.1")}
+	sellOrder := &order.Order{ID: 2, UserHashPublicKey: w.Sereliaze().PublicKey, CryptocurrencyCode: "BTC", TypeName: "sell", UnitPrice: decimal.MustParse("50000.0"), Quantity: decimal.MustParse("0.1")}
 	dealObj := &deal.Deal{ID: 1, BuyOrder: buyOrder, SellOrder: sellOrder, StatusName: "completed", CreatedAt: "2025-01-01T12:00:00Z", LastStatusChange: "2025-01-01T12:30:00Z"}
 	tx, _ := transaction.New(w, dealObj)
-	blockObj := &block.Block{ID: 1, TimeCreated: time.Now().UTC().Unix(), Transactions: []transaction.Transaction{tx}, PreviousHash: "abc123", Nonce: 42}
+	blockObj := &block.Block{ID: 1, TimeCreated: block.NewTimestamp(time.Now()), Transactions: []transaction.Transaction{tx}, PreviousHash: "abc123", Nonce: 42}
 
 	tests := []struct {
 		name        string
@@ -147,16 +148,16 @@ func TestNewTransactionMessage(t *testing.T) {
 		UserHashPublicKey:  w.Sereliaze().PublicKey,
 		CryptocurrencyCode: "BTC",
 		TypeName:           "buy",
-		UnitPrice:          50000.0,
-		Quantity:           0.1,
+		UnitPrice:          decimal.MustParse("50000.0"),
+		Quantity:           decimal.MustParse("0.1"),
 	}
 	sellOrder := &order.Order{
 		ID:                 2,
 		UserHashPublicKey:  w.Sereliaze().PublicKey,
 		CryptocurrencyCode: "BTC",
 		TypeName:           "sell",
-		UnitPrice:          50000.0,
-		Quantity:           0.1,
+		UnitPrice:          decimal.MustParse("50000.0"),
+		Quantity:           decimal.MustParse("0.1"),
 	}
 
 	// Создаем сделку
@@ -184,7 +185,7 @@ func TestNewTransactionMessage(t *testing.T) {
 	}
 
 	// Сравнение содержимого
-	if !reflect.DeepEqual(content.Transaction, mockTx) {
+	if !reflect.DeepEqual(*content.Transaction, mockTx) {
 		t.Errorf("Transaction content mismatch:\nExpected: %#v\nActual:   %#v", mockTx, content.Transaction)
 	}
 
@@ -300,3 +301,35 @@ func TestNewRawMessage(t *testing.T) {
 		t.Errorf("Expected time >= %d, got %d", startTime, content.GetTime())
 	}
 }
+
+func TestChainMessagesFromJson(t *testing.T) {
+	request := NewChainRequestMessage(5, "abc", 20)
+	requestJson, _ := json.Marshal(request)
+
+	msg, err := MessageFromJson(requestJson)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	content, ok := msg.Content.(*ChainRequestMessage)
+	if !ok {
+		t.Fatalf("Expected content type *ChainRequestMessage, got %T", msg.Content)
+	}
+	if content.FromHeight != 5 || content.AfterHash != "abc" || content.Limit != 20 {
+		t.Errorf("Unexpected chain request: %+v", content)
+	}
+
+	response := NewChainMessage([]block.Block{{ID: 5, PreviousHash: "abc"}, {ID: 6}})
+	responseJson, _ := json.Marshal(response)
+
+	msg, err = MessageFromJson(responseJson)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	chainContent, ok := msg.Content.(*ChainMessage)
+	if !ok {
+		t.Fatalf("Expected content type *ChainMessage, got %T", msg.Content)
+	}
+	if len(chainContent.Chain) != 2 || chainContent.Chain[0].PreviousHash != "abc" {
+		t.Errorf("Unexpected chain: %+v", chainContent.Chain)
+	}
+}
@@ -5,7 +5,9 @@ type MessageType string
 const (
 	RawMessageType MessageType = "RawMessage"
 
-	RequestMessageInfo MessageType = "RequestMessageInfo"
+	RequestMessageInfo  MessageType = "RequestMessageInfo"
+	RequestChainMessage MessageType = "RequestChainMessage"
+	RequestPingMessage  MessageType = "RequestPing"
 
 	ResponseMessageInfo        MessageType = "ResponseMessageInfo"
 	ResponseTransactionMessage MessageType = "ResponseTransactionMessage"
@@ -13,4 +15,19 @@ const (
 	ResponsePeerMessage        MessageType = "ResponsePeerMessage"
 	ResponseTextMessage        MessageType = "ResponseTextMessage"
 	ResponseChainMessage       MessageType = "ResponseChainMessage"
+	ResponsePongMessage        MessageType = "ResponsePong"
 )
+
+// SupportedTypes lists the message types this node understands, announced in the handshake
+var SupportedTypes = []MessageType{
+	RequestMessageInfo,
+	RequestChainMessage,
+	RequestPingMessage,
+	ResponseMessageInfo,
+	ResponseTransactionMessage,
+	ResponseBlockMessage,
+	ResponsePeerMessage,
+	ResponseTextMessage,
+	ResponseChainMessage,
+	ResponsePongMessage,
+}
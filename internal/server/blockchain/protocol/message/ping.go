@@ -0,0 +1,24 @@
+package message
+
+import "time"
+
+// PingMessage checks that a peer is alive. The peer answers with a pong carrying
+// the same nonce. Both are handled by the connection pool and never relayed.
+type PingMessage struct {
+	BaseMessage
+	Nonce uint64 `json:"nonce"`
+}
+
+func NewPingMessage(nonce uint64) Message {
+	return Message{
+		Type:    RequestPingMessage,
+		Content: &PingMessage{BaseMessage: BaseMessage{TimeStamp: time.Now().UTC().Unix()}, Nonce: nonce},
+	}
+}
+
+func NewPongMessage(nonce uint64) Message {
+	return Message{
+		Type:    ResponsePongMessage,
+		Content: &PingMessage{BaseMessage: BaseMessage{TimeStamp: time.Now().UTC().Unix()}, Nonce: nonce},
+	}
+}
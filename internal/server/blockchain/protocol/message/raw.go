@@ -1,8 +1,13 @@
 package message
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"net"
+)
 
 type RawMessage struct {
 	BaseMessage
 	MessageJson json.RawMessage
+	// Peer the message was received from, nil for local messages
+	Addr net.Addr `json:"-"`
 }
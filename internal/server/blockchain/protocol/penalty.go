@@ -0,0 +1,40 @@
+package protocol
+
+import (
+	"net"
+	"sync"
+)
+
+// penalties counts invalid messages received from every peer
+type penalties struct {
+	mutex  sync.Mutex
+	counts map[string]int
+}
+
+func newPenalties() *penalties {
+	return &penalties{counts: make(map[string]int)}
+}
+
+// add counts an invalid message against the peer and returns its total
+func (p *penalties) add(addr net.Addr) int {
+	if addr == nil {
+		return 0
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.counts[addr.String()]++
+	return p.counts[addr.String()]
+}
+
+func (p *penalties) snapshot() map[string]int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	counts := make(map[string]int, len(p.counts))
+	for addr, count := range p.counts {
+		counts[addr] = count
+	}
+	return counts
+}
@@ -1,14 +1,29 @@
 package protocol
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"net"
 	"sender/internal/app"
+	"sender/internal/data/blockchain/block"
+	"sender/internal/data/blockchain/chain"
+	"sender/internal/data/blockchain/replay"
+	"sender/internal/jsonutil"
 	poolMessage "sender/internal/server/blockchain/connectionpool/message"
 	"sender/internal/server/blockchain/protocol/message"
 	"time"
 )
 
+// replayPruneInterval is how often transactions outside the replay window are forgotten
+const replayPruneInterval = time.Second
+
+var (
+	errDuplicateBlock = errors.New("block is already known")
+	// errInvalidBlock marks blocks rejected because of their content, they count against the peer
+	errInvalidBlock = errors.New("invalid block")
+)
+
 // P2PProtocol manages the P2P communication protocol
 type P2PProtocol struct {
 	// Channels for protocol communication
@@ -19,6 +34,18 @@ type P2PProtocol struct {
 
 	lastMessageID uint64
 	appState      *app.AppState
+
+	// Number of leading zero hex digits required in block hashes
+	difficulty int
+
+	// Time of the last chain sync request, used for throttling
+	lastSyncRequest time.Time
+
+	// Transactions already relayed, replays are dropped
+	replay *replay.Guard
+
+	// Invalid transactions and blocks received from every peer
+	penalties *penalties
 }
 
 // NewP2PProtocol creates a new P2P protocol instance
@@ -28,9 +55,22 @@ func NewProtocol(messageChan chan message.Message, appState *app.AppState, poolC
 		poolChan:      poolChan,
 		lastMessageID: 0,
 		appState:      appState,
+		difficulty:    block.DefaultDifficulty,
+		replay:        replay.New(replay.DefaultWindow),
+		penalties:     newPenalties(),
 	}
 }
 
+// SetDifficulty sets the proof-of-work difficulty required for incoming blocks
+func (p *P2PProtocol) SetDifficulty(difficulty int) {
+	p.difficulty = difficulty
+}
+
+// Penalties returns the number of invalid messages received from every peer
+func (p *P2PProtocol) Penalties() map[string]int {
+	return p.penalties.snapshot()
+}
+
 // GetMessageChan returns the channel for sending messages to the protocol
 func (p *P2PProtocol) GetMessageChan() chan<- message.Message {
 	return p.messageChan
@@ -38,33 +78,48 @@ func (p *P2PProtocol) GetMessageChan() chan<- message.Message {
 
 // Run starts the P2P protocol message processing
 func (p *P2PProtocol) Run() {
+	// the replay window is pruned on a ticker, a steady stream of messages must not postpone it
+	pruneTicker := time.NewTicker(replayPruneInterval)
+	defer pruneTicker.Stop()
+
 	for {
 		select {
 		case msg := <-p.messageChan:
 			switch msg.Type {
 			case message.RawMessageType:
-				rawMsgJson := msg.Content.(*message.RawMessage).MessageJson
-				msg_from_json, err := message.MessageFromJson(rawMsgJson)
+				rawMsg := msg.Content.(*message.RawMessage)
+				msg_from_json, err := message.MessageFromJson(rawMsg.MessageJson)
 				if err != nil {
-					log.Printf("Error with message")
-					return
+					log.Printf("Error with message from %v: %v", rawMsg.Addr, err)
+					p.penalize(rawMsg.Addr, err)
+					continue
 				}
 
-				p.processMessage(*msg_from_json)
+				p.processMessage(*msg_from_json, rawMsg.Addr)
+
+			case message.RequestChainMessage:
+				// New peer connected, catch up with its chain
+				p.requestChain(nil, false)
+
+			case message.ResponseTransactionMessage:
+				// Our own transaction, its echo from peers must not be relayed again
+				p.replay.Record(msg.Content.(*message.TransactionMessage).Transaction, time.Now())
+				p.sendMessage(msg)
 
 			default:
 				// Message from this server
 				p.sendMessage(msg)
 			}
 
-		case <-time.After(1 * time.Second):
-			// Timeout, just continue
+		case now := <-pruneTicker.C:
+			// forget transactions outside the replay window
+			p.replay.Prune(now)
 		}
 	}
 }
 
 // processMessage handles incoming messages from peers
-func (p *P2PProtocol) processMessage(msg message.Message) {
+func (p *P2PProtocol) processMessage(msg message.Message, from net.Addr) {
 	// Check if we've already seen this message
 	if msg.Type == message.RequestMessageInfo {
 		log.Printf("Type:RequestMessageInfo received")
@@ -82,6 +137,17 @@ func (p *P2PProtocol) processMessage(msg message.Message) {
 		return
 	}
 
+	// Chain sync messages are addressed to a single peer and never relayed
+	if msg.Type == message.RequestChainMessage {
+		p.processChainRequest(msg.Content.(*message.ChainRequestMessage), from)
+		return
+	}
+
+	if msg.Type == message.ResponseChainMessage {
+		p.processChain(msg.Content.(*message.ChainMessage), from)
+		return
+	}
+
 	// Check if this is a duplicate message
 	if msg.Content.GetID() <= p.lastMessageID {
 		log.Printf("Message ID less than current: %d<%d", msg.Content.GetID(), p.lastMessageID)
@@ -96,12 +162,33 @@ func (p *P2PProtocol) processMessage(msg message.Message) {
 	switch msg.Type {
 	case message.ResponseBlockMessage:
 		blockMessage := msg.Content.(*message.BlockMessage)
-		p.processBlock(blockMessage)
+		if err := p.processBlock(blockMessage, from); err != nil {
+			log.Printf("Block rejected: %v", err)
+			if errors.Is(err, errInvalidBlock) {
+				p.penalize(from, err)
+			}
+			return
+		}
+
+	case message.ResponseTransactionMessage:
+		transactionMessage := msg.Content.(*message.TransactionMessage)
+		if err := p.processTransaction(transactionMessage); err != nil {
+			log.Printf("Transaction rejected: %v", err)
+			// повторы и устаревшие nonce честно пересылаются соседями, это не их вина
+			if !errors.Is(err, replay.ErrDuplicate) && !errors.Is(err, replay.ErrStaleNonce) {
+				p.penalize(from, err)
+			}
+			return
+		}
 
 	case message.ResponsePeerMessage:
 		peerMsg := msg.Content.(*message.PeerMessage)
 		p.processPeer(peerMsg)
 
+	case message.RequestPingMessage, message.ResponsePongMessage:
+		// keepalive is answered by the connection pool and never relayed
+		return
+
 	case message.ResponseTextMessage:
 		textMsg := msg.Content.(*message.TextMessage).Message
 		log.Printf("Received text message: %s", textMsg)
@@ -113,7 +200,7 @@ func (p *P2PProtocol) processMessage(msg message.Message) {
 
 	if !isUnknown {
 		// Broadcast the message to all peers
-		msgJSON, err := json.Marshal(msg)
+		msgJSON, err := jsonutil.Marshal(msg)
 		if err != nil {
 			log.Printf("Failed to marshal message: %v", err)
 			return
@@ -126,12 +213,35 @@ func (p *P2PProtocol) processMessage(msg message.Message) {
 	}
 }
 
+// sendDirect sends a message to a single peer, or to all peers when addr is nil
+func (p *P2PProtocol) sendDirect(msg message.Message, addr net.Addr) {
+	if addr == nil {
+		p.sendMessage(msg)
+		return
+	}
+
+	p.lastMessageID++
+	msg.Content.SetID(p.lastMessageID)
+
+	msgJSON, err := jsonutil.Marshal(msg)
+	if err != nil {
+		log.Printf("Failed to marshal message: %v", err)
+		return
+	}
+
+	p.poolChan <- poolMessage.PoolMessage{
+		Type:    poolMessage.DirectMessage,
+		Addr:    addr,
+		Message: string(msgJSON),
+	}
+}
+
 // sendMessage sends a message to all peers
 func (p *P2PProtocol) sendMessage(msg message.Message) {
 	p.lastMessageID++
 	msg.Content.SetID(p.lastMessageID)
 
-	msgJSON, err := json.Marshal(msg)
+	msgJSON, err := jsonutil.Marshal(msg)
 	if err != nil {
 		log.Printf("Failed to marshal message: %v", err)
 		return
@@ -143,9 +253,72 @@ func (p *P2PProtocol) sendMessage(msg message.Message) {
 	}
 }
 
-// processBlock processes a block message
-func (p *P2PProtocol) processBlock(msg *message.BlockMessage) {
-	p.appState.ReadBlock(msg)
+// processBlock validates a block message and hands it over to the application.
+// Blocks that fail validation are returned as an error and must not be relayed.
+func (p *P2PProtocol) processBlock(msg *message.BlockMessage, from net.Addr) error {
+	return p.acceptBlock(msg.Block, from)
+}
+
+// acceptBlock validates, stores and links a block received from a peer
+func (p *P2PProtocol) acceptBlock(newBlock *block.Block, from net.Addr) error {
+	if err := newBlock.Validate(p.difficulty); err != nil {
+		return fmt.Errorf("%w: %w", errInvalidBlock, err)
+	}
+	for i := range newBlock.Transactions {
+		if err := newBlock.Transactions[i].VerifySender(); err != nil {
+			return fmt.Errorf("%w: block %d transaction %d: %w", errInvalidBlock, newBlock.ID, i, err)
+		}
+	}
+
+	if err := p.appState.StoreBlock(newBlock); err != nil {
+		return fmt.Errorf("failed to store block %d: %w", newBlock.ID, err)
+	}
+
+	result, err := p.appState.AddBlock(newBlock)
+	if err != nil {
+		return fmt.Errorf("failed to link block %d: %w", newBlock.ID, err)
+	}
+
+	switch result.Status {
+	case chain.Duplicate:
+		return errDuplicateBlock
+	case chain.Orphaned:
+		log.Printf("Block %d is orphaned, waiting for parent %s", newBlock.ID, newBlock.PreviousHash)
+		// We are missing blocks, ask the sender for them
+		p.requestChain(from, false)
+	case chain.Reorganized:
+		log.Printf("Chain reorganized: %d blocks reverted, %d applied", len(result.Reverted), len(result.Applied))
+	}
+
+	p.appState.PublishChainUpdate(result)
+	return nil
+}
+
+// processTransaction verifies the signature of a transaction from a peer and
+// checks that it is not a replay. Rejected transactions are returned as an
+// error and must not be relayed.
+func (p *P2PProtocol) processTransaction(msg *message.TransactionMessage) error {
+	if msg.Transaction == nil {
+		return errors.New("empty transaction")
+	}
+	if err := msg.Transaction.VerifySender(); err != nil {
+		return err
+	}
+
+	id, err := p.replay.Accept(msg.Transaction, time.Now())
+	if err != nil {
+		return fmt.Errorf("transaction %s: %w", id, err)
+	}
+
+	log.Printf("Received transaction %s", id)
+	return nil
+}
+
+// penalize counts an invalid message against the peer
+func (p *P2PProtocol) penalize(from net.Addr, reason error) {
+	if count := p.penalties.add(from); count > 0 {
+		log.Printf("Peer %s sent invalid data (%d so far): %v", from, count, reason)
+	}
 }
 
 // processPeer processes a peer message
@@ -162,7 +335,7 @@ func (p *P2PProtocol) sendFirstMessage() {
 	responseMsg := message.NewInfoMessage()
 	responseMsg.Content.SetID(p.lastMessageID)
 
-	msgJSON, err := json.Marshal(responseMsg)
+	msgJSON, err := jsonutil.Marshal(responseMsg)
 	if err != nil {
 		log.Printf("Failed to marshal response message: %v", err)
 		return
@@ -2,10 +2,21 @@ package protocol_test
 
 import (
 	"encoding/json"
+	"net"
+	"path/filepath"
 	"sender/internal/app"
+	"sender/internal/data/blockchain/block"
+	"sender/internal/data/blockchain/chain"
+	"sender/internal/data/blockchain/transaction"
+	"sender/internal/data/blockchain/wallet"
+	"sender/internal/data/deal"
+	"sender/internal/data/dealevent"
+	"sender/internal/data/decimal"
+	"sender/internal/data/order"
 	poolMessage "sender/internal/server/blockchain/connectionpool/message"
 	"sender/internal/server/blockchain/protocol"
 	"sender/internal/server/blockchain/protocol/message"
+	"sender/internal/storage/outbox"
 	"testing"
 	"time"
 )
@@ -20,6 +31,26 @@ func newRawMessage(msg message.Message) message.Message {
 	}
 }
 
+// newSignedTransaction creates a transaction signed by a fresh ed25519 wallet
+func newSignedTransaction(t *testing.T) *transaction.Transaction {
+	t.Helper()
+
+	w, err := wallet.NewWithAlgorithm(wallet.Ed25519)
+	if err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+	d := &deal.Deal{
+		ID:        1,
+		BuyOrder:  &order.Order{ID: 1, UserHashPublicKey: "buyer-public-key"},
+		SellOrder: &order.Order{ID: 2, UserHashPublicKey: "seller-public-key"},
+	}
+	tx, _ := transaction.New(w, d)
+	if err := tx.Sign(); err != nil {
+		t.Fatalf("Failed to sign transaction: %v", err)
+	}
+	return &tx
+}
+
 func TestRun_ResponseInfoUpdatesID(t *testing.T) {
 	msgChan := make(chan message.Message, 1)
 	poolChan := make(chan poolMessage.PoolMessage, 1)
@@ -184,3 +215,292 @@ func TestRun_InvalidJSONShouldNotPanic(t *testing.T) {
 	default:
 	}
 }
+
+func TestRun_InvalidBlockIsNotBroadcasted(t *testing.T) {
+	msgChan := make(chan message.Message, 1)
+	poolChan := make(chan poolMessage.PoolMessage, 1)
+	state := &app.AppState{KafkaChan: make(chan dealevent.Event, 1)}
+	proto := protocol.NewProtocol(msgChan, state, poolChan)
+	proto.SetDifficulty(64)
+
+	base := message.NewBaseMessage()
+	base.SetID(1)
+	blockMsg := message.Message{
+		Type:    message.ResponseBlockMessage,
+		Content: &message.BlockMessage{BaseMessage: *base, Block: &block.Block{ID: 1, TimeCreated: block.NewTimestamp(time.Now())}},
+	}
+	msgChan <- newRawMessage(blockMsg)
+
+	go proto.Run()
+	time.Sleep(100 * time.Millisecond)
+
+	select {
+	case <-poolChan:
+		t.Error("Block without valid proof of work should not be broadcasted")
+	case <-state.KafkaChan:
+		t.Error("Block without valid proof of work should not reach kafka")
+	default:
+	}
+}
+
+func TestRun_ValidBlockIsForwarded(t *testing.T) {
+	msgChan := make(chan message.Message, 1)
+	poolChan := make(chan poolMessage.PoolMessage, 1)
+	state := &app.AppState{KafkaChan: make(chan dealevent.Event, 1)}
+	proto := protocol.NewProtocol(msgChan, state, poolChan)
+	proto.SetDifficulty(0)
+
+	base := message.NewBaseMessage()
+	base.SetID(1)
+	tx := newSignedTransaction(t)
+	newBlock := &block.Block{
+		ID:           1,
+		TimeCreated:  block.NewTimestamp(time.Now()),
+		Transactions: []transaction.Transaction{*tx},
+	}
+	blockMsg := message.Message{
+		Type:    message.ResponseBlockMessage,
+		Content: &message.BlockMessage{BaseMessage: *base, Block: newBlock},
+	}
+	msgChan <- newRawMessage(blockMsg)
+
+	go proto.Run()
+
+	select {
+	case event := <-state.KafkaChan:
+		if event.Kind != dealevent.Confirmed || event.Transaction.Signature != tx.Signature {
+			t.Errorf("Unexpected event: %+v", event)
+		}
+	case <-time.After(200 * time.Millisecond):
+		t.Fatal("Expected valid block to reach kafka")
+	}
+
+	select {
+	case out := <-poolChan:
+		if out.Type != poolMessage.BroadcastMessage {
+			t.Errorf("Expected BroadcastMessage, got %v", out.Type)
+		}
+	case <-time.After(200 * time.Millisecond):
+		t.Error("Expected valid block to be broadcasted")
+	}
+}
+
+func TestRun_ConfirmedEventsAreInOutboxBeforeBroadcast(t *testing.T) {
+	eventOutbox, err := outbox.Open(filepath.Join(t.TempDir(), "outbox.jsonl"))
+	if err != nil {
+		t.Fatalf("Failed to open outbox: %v", err)
+	}
+	defer eventOutbox.Close()
+
+	msgChan := make(chan message.Message, 1)
+	poolChan := make(chan poolMessage.PoolMessage, 1)
+	state := &app.AppState{Outbox: eventOutbox}
+	proto := protocol.NewProtocol(msgChan, state, poolChan)
+	proto.SetDifficulty(0)
+
+	base := message.NewBaseMessage()
+	base.SetID(1)
+	tx := newSignedTransaction(t)
+	newBlock := &block.Block{
+		ID:           1,
+		TimeCreated:  block.NewTimestamp(time.Now()),
+		Transactions: []transaction.Transaction{*tx},
+	}
+	msgChan <- newRawMessage(message.Message{
+		Type:    message.ResponseBlockMessage,
+		Content: &message.BlockMessage{BaseMessage: *base, Block: newBlock},
+	})
+
+	go proto.Run()
+
+	select {
+	case <-poolChan:
+	case <-time.After(200 * time.Millisecond):
+		t.Fatal("Expected valid block to be broadcasted")
+	}
+
+	// блок принят только после того, как событие записано на диск
+	entry, exists := eventOutbox.Peek()
+	if !exists || entry.Kind != string(dealevent.Confirmed) {
+		t.Fatalf("Expected confirmed event in outbox, got %+v, %v", entry, exists)
+	}
+}
+
+func newRawMessageFrom(msg message.Message, addr net.Addr) message.Message {
+	raw := newRawMessage(msg)
+	raw.Content.(*message.RawMessage).Addr = addr
+	return raw
+}
+
+func TestRun_ChainRequestIsServedToPeer(t *testing.T) {
+	msgChan := make(chan message.Message, 1)
+	poolChan := make(chan poolMessage.PoolMessage, 1)
+	blockChain := chain.New()
+	state := &app.AppState{Chain: blockChain}
+	proto := protocol.NewProtocol(msgChan, state, poolChan)
+
+	genesis := &block.Block{ID: 1, TimeCreated: block.NewTimestamp(time.Now())}
+	genesisHash, _ := genesis.Hash()
+	second := &block.Block{ID: 2, TimeCreated: block.NewTimestamp(time.Now()), PreviousHash: genesisHash}
+	blockChain.Add(genesis)
+	blockChain.Add(second)
+
+	addr := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 9100}
+	msgChan <- newRawMessageFrom(message.NewChainRequestMessage(2, "", 10), addr)
+
+	go proto.Run()
+
+	select {
+	case out := <-poolChan:
+		if out.Type != poolMessage.DirectMessage || out.Addr.String() != addr.String() {
+			t.Fatalf("Expected DirectMessage to %s, got %v to %v", addr, out.Type, out.Addr)
+		}
+		response, err := message.MessageFromJson([]byte(out.Message))
+		if err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		chainMsg := response.Content.(*message.ChainMessage)
+		if len(chainMsg.Chain) != 1 || chainMsg.Chain[0].ID != 2 {
+			t.Errorf("Expected only block 2, got %+v", chainMsg.Chain)
+		}
+	case <-time.After(200 * time.Millisecond):
+		t.Fatal("Expected chain response")
+	}
+}
+
+func TestRun_ChainResponseIsLinked(t *testing.T) {
+	msgChan := make(chan message.Message, 1)
+	poolChan := make(chan poolMessage.PoolMessage, 1)
+	blockChain := chain.New()
+	state := &app.AppState{Chain: blockChain, KafkaChan: make(chan dealevent.Event, 10)}
+	proto := protocol.NewProtocol(msgChan, state, poolChan)
+	proto.SetDifficulty(0)
+
+	genesis := block.Block{ID: 1, TimeCreated: block.NewTimestamp(time.Now())}
+	genesisHash, _ := genesis.Hash()
+	second := block.Block{ID: 2, TimeCreated: block.NewTimestamp(time.Now()), PreviousHash: genesisHash}
+	secondHash, _ := second.Hash()
+
+	addr := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 9101}
+	msgChan <- newRawMessageFrom(message.NewChainMessage([]block.Block{genesis, second}), addr)
+
+	go proto.Run()
+	time.Sleep(100 * time.Millisecond)
+
+	if !blockChain.Has(secondHash) {
+		t.Fatal("Expected synced blocks to be linked into the chain")
+	}
+
+	// Неполная партия не требует продолжения, а ответы не пересылаются
+	select {
+	case out := <-poolChan:
+		t.Errorf("Unexpected pool message: %v", out.Type)
+	default:
+	}
+}
+
+func TestRun_ReplayedTransactionIsNotRelayed(t *testing.T) {
+	msgChan := make(chan message.Message, 2)
+	poolChan := make(chan poolMessage.PoolMessage, 2)
+	state := &app.AppState{}
+	proto := protocol.NewProtocol(msgChan, state, poolChan)
+
+	tx := newSignedTransaction(t)
+	// та же транзакция приходит повторно в новом сообщении
+	for id := uint64(1); id <= 2; id++ {
+		base := message.NewBaseMessage()
+		base.SetID(id)
+		msgChan <- newRawMessage(message.Message{
+			Type:    message.ResponseTransactionMessage,
+			Content: &message.TransactionMessage{BaseMessage: *base, Transaction: tx},
+		})
+	}
+
+	go proto.Run()
+	time.Sleep(100 * time.Millisecond)
+
+	if len(poolChan) != 1 {
+		t.Fatalf("Expected transaction to be relayed once, got %d", len(poolChan))
+	}
+}
+
+func TestRun_StaleNonceIsNotPenalized(t *testing.T) {
+	msgChan := make(chan message.Message, 2)
+	poolChan := make(chan poolMessage.PoolMessage, 2)
+	state := &app.AppState{}
+	proto := protocol.NewProtocol(msgChan, state, poolChan)
+
+	tx := newSignedTransaction(t)
+	// другая транзакция с тем же nonce отправителя
+	reused := *tx
+	reused.CreatedAt += 1000
+	if err := reused.Sign(); err != nil {
+		t.Fatalf("Failed to sign transaction: %v", err)
+	}
+
+	addr := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 9103}
+	for id, relayed := range []*transaction.Transaction{tx, &reused} {
+		base := message.NewBaseMessage()
+		base.SetID(uint64(id + 1))
+		msgChan <- newRawMessageFrom(message.Message{
+			Type:    message.ResponseTransactionMessage,
+			Content: &message.TransactionMessage{BaseMessage: *base, Transaction: relayed},
+		}, addr)
+	}
+
+	go proto.Run()
+	time.Sleep(100 * time.Millisecond)
+
+	if len(poolChan) != 1 {
+		t.Errorf("Expected only the first transaction to be relayed, got %d", len(poolChan))
+	}
+	if penalties := proto.Penalties(); penalties[addr.String()] != 0 {
+		t.Errorf("Expected no penalties for relaying a stale nonce, got %v", penalties)
+	}
+}
+
+func TestRun_InvalidTransactionIsPenalized(t *testing.T) {
+	msgChan := make(chan message.Message, 2)
+	poolChan := make(chan poolMessage.PoolMessage, 2)
+	state := &app.AppState{KafkaChan: make(chan dealevent.Event, 1)}
+	proto := protocol.NewProtocol(msgChan, state, poolChan)
+	proto.SetDifficulty(0)
+
+	forged := newSignedTransaction(t)
+	forged.Transfer = decimal.New(1000000, 0)
+
+	addr := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 9102}
+	base := message.NewBaseMessage()
+	base.SetID(1)
+	msgChan <- newRawMessageFrom(message.Message{
+		Type:    message.ResponseTransactionMessage,
+		Content: &message.TransactionMessage{BaseMessage: *base, Transaction: forged},
+	}, addr)
+
+	// блок с поддельной транзакцией тоже отклоняется
+	base = message.NewBaseMessage()
+	base.SetID(2)
+	msgChan <- newRawMessageFrom(message.Message{
+		Type: message.ResponseBlockMessage,
+		Content: &message.BlockMessage{BaseMessage: *base, Block: &block.Block{
+			ID:           1,
+			TimeCreated:  block.NewTimestamp(time.Now()),
+			Transactions: []transaction.Transaction{*forged},
+		}},
+	}, addr)
+
+	go proto.Run()
+	time.Sleep(100 * time.Millisecond)
+
+	select {
+	case out := <-poolChan:
+		t.Errorf("Forged data must not be relayed, got %v", out.Type)
+	case <-state.KafkaChan:
+		t.Error("Block with a forged transaction must not reach kafka")
+	default:
+	}
+
+	if penalties := proto.Penalties(); penalties[addr.String()] != 2 {
+		t.Errorf("Expected 2 penalties for %s, got %v", addr, penalties)
+	}
+}
@@ -0,0 +1,90 @@
+package protocol
+
+import (
+	"errors"
+	"log"
+	"net"
+	"sender/internal/data/blockchain/block"
+	"sender/internal/server/blockchain/protocol/message"
+	"time"
+)
+
+// MaxChainBatch limits the number of blocks sent in one chain response
+const MaxChainBatch = 50
+
+// syncThrottle is the minimal interval between unsolicited chain requests
+const syncThrottle = 2 * time.Second
+
+// requestChain asks a peer (or all peers when addr is nil) for the blocks
+// following our best tip. Continuation requests pass force to skip throttling.
+func (p *P2PProtocol) requestChain(addr net.Addr, force bool) {
+	if !force && time.Since(p.lastSyncRequest) < syncThrottle {
+		return
+	}
+	p.lastSyncRequest = time.Now()
+
+	fromHeight := 0
+	afterHash := ""
+	if p.appState.Chain != nil {
+		if tip, ok := p.appState.Chain.Tip(); ok {
+			fromHeight = tip.Height + 1
+			afterHash = tip.Hash
+		}
+	}
+
+	log.Printf("Requesting chain from height %d", fromHeight)
+	p.sendDirect(message.NewChainRequestMessage(fromHeight, afterHash, MaxChainBatch), addr)
+}
+
+// processChainRequest serves a batch of our best chain to the requesting peer
+func (p *P2PProtocol) processChainRequest(req *message.ChainRequestMessage, from net.Addr) {
+	if p.appState.Chain == nil {
+		return
+	}
+
+	limit := req.Limit
+	if limit <= 0 || limit > MaxChainBatch {
+		limit = MaxChainBatch
+	}
+
+	blocks := p.appState.Chain.Range(req.FromHeight, req.AfterHash, limit)
+	if len(blocks) == 0 {
+		return
+	}
+
+	chainBlocks := make([]block.Block, 0, len(blocks))
+	for _, b := range blocks {
+		chainBlocks = append(chainBlocks, *b)
+	}
+
+	log.Printf("Serving %d blocks from height %d", len(chainBlocks), chainBlocks[0].ID)
+	p.sendDirect(message.NewChainMessage(chainBlocks), from)
+}
+
+// processChain validates and links a batch of blocks received from a peer
+// and asks for the next batch while the peer keeps sending full batches
+func (p *P2PProtocol) processChain(msg *message.ChainMessage, from net.Addr) {
+	added := 0
+	for i := range msg.Chain {
+		newBlock := &msg.Chain[i]
+
+		err := p.acceptBlock(newBlock, from)
+		if errors.Is(err, errDuplicateBlock) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Chain sync stopped at block %d: %v", newBlock.ID, err)
+			if errors.Is(err, errInvalidBlock) {
+				p.penalize(from, err)
+			}
+			return
+		}
+		added++
+	}
+
+	log.Printf("Chain sync: %d of %d blocks added", added, len(msg.Chain))
+
+	if added > 0 && len(msg.Chain) >= MaxChainBatch {
+		p.requestChain(from, true)
+	}
+}
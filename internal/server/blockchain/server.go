@@ -1,36 +1,103 @@
 package blockchain
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
+	"sender/internal/data/blockchain/wallet"
+	"sender/internal/server/blockchain/codec"
 	"sender/internal/server/blockchain/connectionpool/message"
 	"sender/internal/server/blockchain/connectionpool/peer"
+	"sender/internal/server/blockchain/handshake"
+	"sender/internal/server/blockchain/transport"
 	"sync"
 	"time"
 )
 
+// handshakeTimeout is how long a peer has to send its handshake before it is taken for a legacy peer
+var handshakeTimeout = 5 * time.Second
+
 // Server represents the P2P server that listens for incoming connections
 type Server struct {
 	poolChan chan message.PoolMessage
+
+	// identity signs the handshake, listenAddress and chainTip are announced in it
+	identity      *wallet.Wallet
+	listenAddress string
+	chainTip      func() handshake.Tip
+
+	// allowLegacy accepts peers that do not send a handshake
+	allowLegacy bool
+
+	// transport is plaintext TCP unless TLS is configured
+	transport *transport.Transport
 }
 
 // NewServer creates a new P2P server instance
 func NewServer(poolChan chan message.PoolMessage) Server {
+	// до SetIdentity узел представляется временным ключом
+	identity, err := wallet.NewWithAlgorithm(wallet.Ed25519)
+	if err != nil {
+		log.Fatalf("Failed to generate node key: %v", err)
+	}
+
 	return Server{
-		poolChan: poolChan,
+		poolChan:    poolChan,
+		identity:    identity,
+		allowLegacy: true,
+		transport:   transport.Plain(),
 	}
 }
 
+// SetIdentity sets the wallet whose public key identifies the node
+func (s *Server) SetIdentity(identity *wallet.Wallet) {
+	s.identity = identity
+}
+
+// SetListenAddress sets the address announced to peers
+func (s *Server) SetListenAddress(address string) {
+	s.listenAddress = address
+}
+
+// SetChainTip sets the source of the chain tip announced to peers
+func (s *Server) SetChainTip(chainTip func() handshake.Tip) {
+	s.chainTip = chainTip
+}
+
+// SetAllowLegacy sets whether peers without a handshake are accepted
+func (s *Server) SetAllowLegacy(allow bool) {
+	s.allowLegacy = allow
+}
+
+// SetTransport sets how connections are opened and which nodes are trusted
+func (s *Server) SetTransport(transport *transport.Transport) {
+	s.transport = transport
+}
+
+// getTransport returns the configured transport, plaintext for a server without one
+func (s *Server) getTransport() *transport.Transport {
+	if s == nil || s.transport == nil {
+		return transport.Plain()
+	}
+	return s.transport
+}
+
+// NodeID returns the id announced in the handshake
+func (s *Server) NodeID() string {
+	return handshake.NodeID(s.identity.Serialize().PublicKey)
+}
+
 // Run starts the server and begins listening for connections
 func (s *Server) Run(address string) error {
-	listener, err := net.Listen("tcp", address)
+	listener, err := s.getTransport().Listen(address)
 	if err != nil {
 		return err
 	}
 	defer listener.Close()
 
-	log.Printf("P2P server started on %s", address)
+	log.Printf("P2P server started on %s (%s)", address, s.getTransport().Mode())
 
 	for {
 		conn, err := listener.Accept()
@@ -54,7 +121,7 @@ func (s *Server) Run(address string) error {
 
 // Connect attempts to connect to a peer at the given address
 func (s *Server) Connect(address string) error {
-	conn, err := net.Dial("tcp", address)
+	conn, err := s.getTransport().Dial(address)
 	if err != nil {
 		log.Printf("Error connecting to %s: %v", address, err)
 		return err
@@ -78,60 +145,143 @@ func (s *Server) GetPoolSender() chan<- message.PoolMessage {
 	return s.poolChan
 }
 
+// readResult is a message read from a peer
+type readResult struct {
+	frameType byte
+	payload   []byte
+	mode      codec.Mode
+	err       error
+}
+
+// readMessages reads from the peer until an error or until done is closed
+func readMessages(reader *codec.Reader, reads chan<- readResult, done <-chan struct{}) {
+	for {
+		var result readResult
+		result.frameType, result.payload, result.mode, result.err = reader.ReadMessage()
+
+		select {
+		case reads <- result:
+		case <-done:
+			return
+		}
+		if result.err != nil {
+			return
+		}
+	}
+}
+
 // handle manages a single connection
 func (s *Server) handle(conn net.Conn) error {
 	addr := conn.RemoteAddr()
 	log.Printf("Started thread for peer %s", addr.String())
 
+	// Incoming TLS connections finish their handshake before anything is sent
+	if err := transport.Handshake(conn, handshakeTimeout); err != nil {
+		log.Printf("TLS handshake with peer %s failed: %v", addr.String(), err)
+		conn.Close()
+		return err
+	}
+
 	// Create a mutex-protected connection
 	connMutex := &sync.Mutex{}
 	wrappedConn := peer.NewProtectedConnection(conn, connMutex)
 
+	// The handshake precedes any other traffic, legacy peers ignore the message
+	localHandshake, err := s.sendHandshake(&wrappedConn)
+	if err != nil {
+		log.Printf("Error sending handshake to peer %s: %v", addr.String(), err)
+		conn.Close()
+		return err
+	}
+
+	reads := make(chan readResult)
+	done := make(chan struct{})
+	defer close(done)
+	go readMessages(codec.NewReader(conn, codec.DefaultMaxFrameSize), reads, done)
+
+	peerHandshake, pending, err := s.awaitHandshake(reads)
+	if err == nil {
+		err = s.authorize(conn, peerHandshake)
+	}
+	if err == nil && peerHandshake != nil {
+		err = s.exchangeProofs(&wrappedConn, reads, localHandshake, peerHandshake)
+	}
+	if err != nil {
+		log.Printf("Refusing peer %s: %v", addr.String(), err)
+		if !errors.Is(err, errRejectedByPeer) && !errors.Is(err, io.EOF) {
+			if reject, encodeErr := handshake.EncodeReject(err.Error()); encodeErr == nil {
+				wrappedConn.SendLine(reject)
+			}
+		}
+		conn.Close()
+		return err
+	}
+
+	if peerHandshake != nil {
+		// Switch our writes to the best codec the peer reads
+		wrappedConn.SetMode(peerHandshake.Mode())
+		log.Printf("Peer %s is node %.12s, version %d, codec %s",
+			addr.String(), peerHandshake.NodeID, peerHandshake.Version, peerHandshake.Mode())
+	} else {
+		log.Printf("Peer %s did not send a handshake, using legacy mode", addr.String())
+	}
+
+	// Writes go through the queue, so reading below never blocks them
+	wrappedConn.Start(peer.DefaultQueueSize)
+
 	// Notify the pool about the new peer
 	s.poolChan <- message.PoolMessage{
-		Type: message.NewPeer,
-		Addr: addr,
-		Conn: &wrappedConn,
+		Type:      message.NewPeer,
+		Addr:      addr,
+		Conn:      &wrappedConn,
+		Handshake: peerHandshake,
 	}
 
-	// Set read timeout
-	conn.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
-
-	buffer := make([]byte, 1024)
 	for {
-		// Read data from the peer
-		connMutex.Lock()
-		n, err := conn.Read(buffer)
-		connMutex.Unlock()
+		// Read a complete message from the peer
+		var result readResult
+		if pending != nil {
+			result, pending = *pending, nil
+		} else {
+			result = <-reads
+		}
 
-		if err != nil {
-			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-				// Timeout occurred, reset the deadline and continue
-				conn.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
-				time.Sleep(100 * time.Millisecond)
-				continue
-			} else if err.Error() == "EOF" {
+		if result.err != nil {
+			if errors.Is(result.err, io.EOF) {
 				// Connection closed
 				log.Printf("Peer %s disconnected", addr.String())
-				break
 			} else {
-				// Other error
-				log.Printf("Error reading from peer %s: %v", addr.String(), err)
-				break
+				log.Printf("Error reading from peer %s: %v", addr.String(), result.err)
 			}
+			break
 		}
 
-		if n > 0 {
-			message_json := string(buffer[:n])
-			// Send the message to the pool
-			s.poolChan <- message.PoolMessage{
-				Type:    message.PeerMessage,
-				Addr:    addr,
-				Message: message_json,
+		if result.frameType != codec.FrameMessage {
+			log.Printf("Unknown frame type %d from peer %s", result.frameType, addr.String())
+			continue
+		}
+
+		if result.mode == codec.ModeLine {
+			if _, ok := handshake.Parse(result.payload); ok {
+				log.Printf("Ignoring repeated handshake from peer %s", addr.String())
+				continue
+			}
+			if reason, ok := handshake.ParseReject(result.payload); ok {
+				log.Printf("Peer %s closed the connection: %s", addr.String(), reason)
+				break
 			}
 		}
+
+		// Send the message to the pool
+		s.poolChan <- message.PoolMessage{
+			Type:    message.PeerMessage,
+			Addr:    addr,
+			Message: string(result.payload),
+		}
 	}
 
+	wrappedConn.Close()
+
 	// Notify the pool about the disconnected peer
 	s.poolChan <- message.PoolMessage{
 		Type: message.PeerDisconnected,
@@ -140,3 +290,126 @@ func (s *Server) handle(conn net.Conn) error {
 
 	return nil
 }
+
+// errRejectedByPeer - the peer refused the connection, nothing to answer
+var errRejectedByPeer = errors.New("rejected by peer")
+
+// sendHandshake writes the signed handshake of the node as a line
+func (s *Server) sendHandshake(conn *peer.ProtectedConnection) (*handshake.Handshake, error) {
+	var tip handshake.Tip
+	if s.chainTip != nil {
+		tip = s.chainTip()
+	}
+
+	hs, err := handshake.New(s.identity, s.listenAddress, tip)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := hs.Encode()
+	if err != nil {
+		return nil, err
+	}
+	return hs, conn.SendLine(payload)
+}
+
+// exchangeProofs answers the challenge of the peer and waits for the answer to
+// ours. Until then the peer only claims its node id, a replayed handshake fails here.
+func (s *Server) exchangeProofs(conn *peer.ProtectedConnection, reads <-chan readResult, local *handshake.Handshake, peerHandshake *handshake.Handshake) error {
+	proof, err := handshake.NewProof(s.identity, peerHandshake)
+	if err != nil {
+		return err
+	}
+	payload, err := proof.Encode()
+	if err != nil {
+		return err
+	}
+	if err := conn.SendLine(payload); err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(handshakeTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return fmt.Errorf("%w: no answer to the challenge", handshake.ErrInvalidProof)
+
+	case result := <-reads:
+		if result.err != nil {
+			return result.err
+		}
+		if result.mode == codec.ModeLine {
+			if reason, ok := handshake.ParseReject(result.payload); ok {
+				return fmt.Errorf("%w: %s", errRejectedByPeer, reason)
+			}
+			if peerProof, ok := handshake.ParseProof(result.payload); ok {
+				return peerProof.Verify(local, peerHandshake)
+			}
+		}
+		return fmt.Errorf("%w: expected an answer to the challenge", handshake.ErrInvalidProof)
+	}
+}
+
+// authorize checks that the peer is one of the trusted nodes. Over TLS the
+// handshake must come from the key of the certificate.
+func (s *Server) authorize(conn net.Conn, peerHandshake *handshake.Handshake) error {
+	if peerHandshake == nil {
+		// ключ старого узла известен только из сертификата TLS
+		if s.getTransport().Restricted() && !transport.IsTLS(conn) {
+			return fmt.Errorf("%w: legacy peer cannot be identified", transport.ErrUntrustedPeer)
+		}
+		return nil
+	}
+
+	if transport.IsTLS(conn) {
+		nodeID, err := transport.PeerNodeID(conn)
+		if err != nil {
+			return err
+		}
+		if nodeID != peerHandshake.NodeID {
+			return transport.ErrIdentityMismatch
+		}
+	}
+
+	if !s.getTransport().Trusts(peerHandshake.NodeID) {
+		return fmt.Errorf("%w: node %s", transport.ErrUntrustedPeer, peerHandshake.NodeID)
+	}
+	return nil
+}
+
+// awaitHandshake waits for the handshake of the peer. A legacy peer either sends
+// another message first, returned as pending, or nothing within handshakeTimeout.
+func (s *Server) awaitHandshake(reads <-chan readResult) (*handshake.Handshake, *readResult, error) {
+	timer := time.NewTimer(handshakeTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		if !s.allowLegacy {
+			return nil, nil, handshake.ErrHandshakeRequired
+		}
+		return nil, nil, nil
+
+	case result := <-reads:
+		if result.err != nil {
+			return nil, nil, result.err
+		}
+
+		if result.mode == codec.ModeLine {
+			if reason, ok := handshake.ParseReject(result.payload); ok {
+				return nil, nil, fmt.Errorf("%w: %s", errRejectedByPeer, reason)
+			}
+			if peerHandshake, ok := handshake.Parse(result.payload); ok {
+				if err := peerHandshake.Accept(s.NodeID()); err != nil {
+					return nil, nil, err
+				}
+				return peerHandshake, nil, nil
+			}
+		}
+
+		if !s.allowLegacy {
+			return nil, nil, handshake.ErrHandshakeRequired
+		}
+		return nil, &result, nil
+	}
+}
@@ -7,8 +7,12 @@ import (
 	"testing"
 	"time"
 
+	"sender/internal/data/blockchain/wallet"
 	"sender/internal/server/blockchain"
+	"sender/internal/server/blockchain/codec"
 	"sender/internal/server/blockchain/connectionpool/message"
+	"sender/internal/server/blockchain/handshake"
+	"sender/internal/server/blockchain/transport"
 )
 
 func startServer(t *testing.T, poolChan chan message.PoolMessage, addr string) {
@@ -62,22 +66,25 @@ func TestHandlePeerMessageAndDisconnect(t *testing.T) {
 		t.Fatalf("Dial failed: %v", err)
 	}
 
+	// Старый узел без рукопожатия сразу отправляет данные
+	_, err = conn.Write([]byte("test message\n"))
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
 	// Ждем сообщения NewPeer
 	select {
 	case msg := <-poolChan:
 		if msg.Type != message.NewPeer {
 			t.Fatalf("Expected NewPeer, got %v", msg.Type)
 		}
+		if msg.Handshake != nil {
+			t.Errorf("Expected legacy peer without handshake, got %+v", msg.Handshake)
+		}
 	case <-time.After(time.Second):
 		t.Fatal("No NewPeer received")
 	}
 
-	// Отправляем данные
-	_, err = conn.Write([]byte("test message"))
-	if err != nil {
-		t.Fatalf("Write failed: %v", err)
-	}
-
 	// Проверяем PeerMessage
 	select {
 	case msg := <-poolChan:
@@ -105,6 +112,294 @@ func TestHandlePeerMessageAndDisconnect(t *testing.T) {
 	}
 }
 
+// dialWithHandshake connects to the server and reads its handshake
+func dialWithHandshake(t *testing.T, addr string) (net.Conn, *codec.Reader, *handshake.Handshake) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+
+	// Сервер первым делом отправляет подписанное рукопожатие строкой JSON
+	reader := codec.NewReader(conn, 0)
+	_, payload, mode, err := reader.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage failed: %v", err)
+	}
+	serverHandshake, ok := handshake.Parse(payload)
+	if !ok || mode != codec.ModeLine {
+		t.Fatalf("Expected handshake line, got %q", payload)
+	}
+	if err := serverHandshake.Verify(); err != nil {
+		t.Fatalf("Server handshake does not verify: %v", err)
+	}
+	return conn, reader, serverHandshake
+}
+
+// clientHandshake returns the handshake of a new node followed by its answer
+// to the challenge of the server
+func clientHandshake(t *testing.T, version int, server *handshake.Handshake) []byte {
+	t.Helper()
+
+	w, _ := wallet.NewWithAlgorithm(wallet.Ed25519)
+	hs, err := handshake.New(w, "127.0.0.1:7000", handshake.Tip{Height: 3, Hash: "abc"})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	hs.Version = version
+	hs.Sign(w)
+
+	return append(encodeLine(t, hs), encodeLine(t, newProof(t, w, server))...)
+}
+
+type lineEncoder interface {
+	Encode() ([]byte, error)
+}
+
+func encodeLine(t *testing.T, msg lineEncoder) []byte {
+	t.Helper()
+
+	payload, err := msg.Encode()
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	line, _ := codec.Encode(codec.ModeLine, codec.FrameMessage, payload)
+	return line
+}
+
+func newProof(t *testing.T, w *wallet.Wallet, server *handshake.Handshake) *handshake.Proof {
+	t.Helper()
+
+	proof, err := handshake.NewProof(w, server)
+	if err != nil {
+		t.Fatalf("NewProof failed: %v", err)
+	}
+	return proof
+}
+
+func TestHandshakeAndFramedCodec(t *testing.T) {
+	poolChan := make(chan message.PoolMessage, 10)
+	addr := "127.0.0.1:9013"
+
+	startServer(t, poolChan, addr)
+
+	conn, reader, serverHandshake := dialWithHandshake(t, addr)
+	defer conn.Close()
+
+	large := `{"type":"Text","content":{"text":"` + strings.Repeat("x", 1<<20) + `"}}`
+	conn.Write(codec.AppendFrame(clientHandshake(t, handshake.ProtocolVersion, serverHandshake), codec.FrameMessage, []byte(large)))
+
+	// сервер отвечает на вызов клиента
+	if _, payload, _, err := reader.ReadMessage(); err != nil {
+		t.Fatalf("Expected server proof, got %v", err)
+	} else if _, ok := handshake.ParseProof(payload); !ok {
+		t.Fatalf("Expected server proof, got %q", payload)
+	}
+
+	// пул узнает о пире только после рукопожатия
+	var newPeer message.PoolMessage
+	select {
+	case newPeer = <-poolChan:
+	case <-time.After(time.Second):
+		t.Fatal("No NewPeer received")
+	}
+	if newPeer.Type != message.NewPeer || newPeer.Handshake == nil {
+		t.Fatalf("Expected NewPeer with handshake, got %+v", newPeer)
+	}
+	if newPeer.Handshake.ListenAddress != "127.0.0.1:7000" || newPeer.Handshake.Tip.Height != 3 {
+		t.Errorf("Unexpected peer handshake: %+v", newPeer.Handshake)
+	}
+
+	select {
+	case msg := <-poolChan:
+		if msg.Type != message.PeerMessage || msg.Message != large {
+			t.Fatalf("Expected the framed message, got %v with %d bytes", msg.Type, len(msg.Message))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("No PeerMessage received")
+	}
+
+	// клиент объявил кадры, ответы идут кадрами
+	if newPeer.Conn.Mode() != codec.ModeFramed {
+		t.Fatalf("Expected framed mode, got %s", newPeer.Conn.Mode())
+	}
+	newPeer.Conn.Send("reply")
+	_, payload, mode, err := reader.ReadMessage()
+	if err != nil || mode != codec.ModeFramed || string(payload) != "reply" {
+		t.Errorf("Expected framed reply, got %q in %s: %v", payload, mode, err)
+	}
+}
+
+func TestIncompatibleVersionRefused(t *testing.T) {
+	poolChan := make(chan message.PoolMessage, 10)
+	addr := "127.0.0.1:9014"
+
+	startServer(t, poolChan, addr)
+
+	conn, reader, serverHandshake := dialWithHandshake(t, addr)
+	defer conn.Close()
+
+	conn.Write(clientHandshake(t, handshake.ProtocolVersion+1, serverHandshake))
+
+	_, payload, _, err := reader.ReadMessage()
+	if err != nil {
+		t.Fatalf("Expected reject, got %v", err)
+	}
+	reason, ok := handshake.ParseReject(payload)
+	if !ok || !strings.Contains(reason, "incompatible protocol version") {
+		t.Fatalf("Expected incompatible version reason, got %q", payload)
+	}
+
+	if _, _, _, err := reader.ReadMessage(); err == nil {
+		t.Error("Expected the connection to be closed")
+	}
+	select {
+	case msg := <-poolChan:
+		t.Errorf("Refused peer reached the pool: %v", msg.Type)
+	default:
+	}
+}
+
+func TestReplayedHandshakeRefused(t *testing.T) {
+	poolChan := make(chan message.PoolMessage, 10)
+	addr := "127.0.0.1:9017"
+
+	startServer(t, poolChan, addr)
+
+	// узел-жертва однажды подключился, его рукопожатие и ответ записаны
+	victim, _ := wallet.NewWithAlgorithm(wallet.Ed25519)
+	victimHandshake, _ := handshake.New(victim, "127.0.0.1:7001", handshake.Tip{})
+	earlier, _, earlierServer := dialWithHandshake(t, addr)
+	captured := append(encodeLine(t, victimHandshake), encodeLine(t, newProof(t, victim, earlierServer))...)
+	earlier.Close()
+	drainPool(poolChan)
+
+	conn, reader, _ := dialWithHandshake(t, addr)
+	defer conn.Close()
+	conn.Write(captured)
+
+	// сервер отвечает на вызов жертвы, затем отказывает
+	for {
+		_, payload, _, err := reader.ReadMessage()
+		if err != nil {
+			t.Fatalf("Expected reject, got %v", err)
+		}
+		if _, ok := handshake.ParseProof(payload); ok {
+			continue
+		}
+		if reason, ok := handshake.ParseReject(payload); !ok || !strings.Contains(reason, handshake.ErrInvalidProof.Error()) {
+			t.Fatalf("Expected invalid proof reason, got %q", payload)
+		}
+		break
+	}
+
+	select {
+	case msg := <-poolChan:
+		if msg.Type == message.NewPeer {
+			t.Errorf("Replayed handshake reached the pool as node %s", msg.Handshake.NodeID)
+		}
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// drainPool drops the messages already sent to the pool
+func drainPool(poolChan chan message.PoolMessage) {
+	for {
+		select {
+		case <-poolChan:
+		default:
+			return
+		}
+	}
+}
+
+func tlsServer(t *testing.T, poolChan chan message.PoolMessage, identity *wallet.Wallet) blockchain.Server {
+	t.Helper()
+
+	tr, err := transport.New(transport.Config{Mode: transport.TLS, Identity: identity})
+	if err != nil {
+		t.Fatalf("transport.New failed: %v", err)
+	}
+	s := blockchain.NewServer(poolChan)
+	s.SetIdentity(identity)
+	s.SetTransport(tr)
+	return s
+}
+
+func TestTLSConnect(t *testing.T) {
+	poolChan := make(chan message.PoolMessage, 10)
+	addr := "127.0.0.1:9015"
+
+	serverWallet, _ := wallet.NewWithAlgorithm(wallet.Ed25519)
+	clientWallet, _ := wallet.NewWithAlgorithm(wallet.ECDSAP256)
+
+	server := tlsServer(t, poolChan, serverWallet)
+	go server.Run(addr)
+	time.Sleep(100 * time.Millisecond)
+
+	client := tlsServer(t, poolChan, clientWallet)
+	if err := client.Connect(addr); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	// обе стороны узнают друг друга по ключу узла
+	seen := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-poolChan:
+			if msg.Type != message.NewPeer || msg.Handshake == nil {
+				t.Fatalf("Expected NewPeer with handshake, got %+v", msg)
+			}
+			seen[msg.Handshake.NodeID] = true
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timeout waiting for NewPeer")
+		}
+	}
+	if !seen[server.NodeID()] || !seen[client.NodeID()] {
+		t.Errorf("Expected both node ids, got %v", seen)
+	}
+}
+
+func TestTLSHandshakeMustMatchCertificate(t *testing.T) {
+	poolChan := make(chan message.PoolMessage, 10)
+	addr := "127.0.0.1:9016"
+
+	serverWallet, _ := wallet.NewWithAlgorithm(wallet.Ed25519)
+	server := tlsServer(t, poolChan, serverWallet)
+	go server.Run(addr)
+	time.Sleep(100 * time.Millisecond)
+
+	clientWallet, _ := wallet.NewWithAlgorithm(wallet.Ed25519)
+	clientTransport, _ := transport.New(transport.Config{Mode: transport.TLS, Identity: clientWallet})
+	conn, err := clientTransport.Dial(addr)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	reader := codec.NewReader(conn, 0)
+	_, payload, _, err := reader.ReadMessage()
+	if err != nil {
+		t.Fatalf("Expected server handshake, got %v", err)
+	}
+	serverHandshake, ok := handshake.Parse(payload)
+	if !ok {
+		t.Fatalf("Expected server handshake, got %q", payload)
+	}
+
+	// рукопожатие подписано другим ключом, чем сертификат TLS
+	conn.Write(clientHandshake(t, handshake.ProtocolVersion, serverHandshake))
+
+	_, payload, _, err = reader.ReadMessage()
+	if err != nil {
+		t.Fatalf("Expected reject, got %v", err)
+	}
+	if reason, ok := handshake.ParseReject(payload); !ok || !strings.Contains(reason, transport.ErrIdentityMismatch.Error()) {
+		t.Errorf("Expected identity mismatch, got %q", payload)
+	}
+}
+
 func TestGetPoolSender(t *testing.T) {
 	poolChan := make(chan message.PoolMessage, 1)
 	s := blockchain.NewServer(poolChan)
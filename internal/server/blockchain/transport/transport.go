@@ -0,0 +1,306 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"sender/internal/data/blockchain/wallet"
+	"sender/internal/server/blockchain/handshake"
+)
+
+// Mode is the way bytes travel between nodes
+type Mode string
+
+const (
+	// Plaintext - raw TCP, compatible with nodes without TLS
+	Plaintext Mode = "plaintext"
+	// TLS - TLS 1.3, both sides present a certificate of their node key
+	TLS Mode = "tls"
+)
+
+var (
+	ErrUnknownMode       = errors.New("unknown transport mode")
+	ErrUntrustedPeer     = errors.New("peer key is not trusted")
+	ErrNoCertificate     = errors.New("peer sent no certificate")
+	ErrMissingWallet     = errors.New("tls transport requires the node wallet")
+	ErrIdentityMismatch  = errors.New("handshake node id does not match the tls certificate")
+	ErrAllowlistNeedsTLS = errors.New("trusted node keys require the tls transport")
+	errNotTLS            = errors.New("not a tls connection")
+)
+
+// ParseMode converts a mode name, empty means Plaintext
+func ParseMode(name string) (Mode, error) {
+	switch mode := Mode(strings.ToLower(name)); mode {
+	case "":
+		return Plaintext, nil
+	case Plaintext, TLS:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownMode, name)
+	}
+}
+
+// Allowlist holds the node ids of trusted public keys. An empty list trusts every node.
+type Allowlist struct {
+	nodeIDs map[string]struct{}
+}
+
+// NewAllowlist builds the allowlist from public keys in any format the wallet parses
+func NewAllowlist(publicKeys []string) (*Allowlist, error) {
+	list := &Allowlist{nodeIDs: make(map[string]struct{}, len(publicKeys))}
+	for _, publicKey := range publicKeys {
+		if strings.TrimSpace(publicKey) == "" {
+			continue
+		}
+
+		nodeID, err := nodeIDOf(publicKey)
+		if err != nil {
+			return nil, fmt.Errorf("trusted key %.16s...: %w", publicKey, err)
+		}
+		list.nodeIDs[nodeID] = struct{}{}
+	}
+	return list, nil
+}
+
+// LoadAllowlist reads public keys from a file, one raw key per line, '#' starts a comment
+func LoadAllowlist(path string) (*Allowlist, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var keys []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		keys = append(keys, strings.TrimSpace(line))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewAllowlist(keys)
+}
+
+// Trusts reports whether the node may connect
+func (a *Allowlist) Trusts(nodeID string) bool {
+	if a.Len() == 0 {
+		return true
+	}
+	_, ok := a.nodeIDs[nodeID]
+	return ok
+}
+
+// Len returns the number of trusted keys, safe on a nil list
+func (a *Allowlist) Len() int {
+	if a == nil {
+		return 0
+	}
+	return len(a.nodeIDs)
+}
+
+// Config describes the transport of the node
+type Config struct {
+	Mode Mode
+	// Identity is the node wallet, its key is the TLS certificate key
+	Identity *wallet.Wallet
+	// Trusted limits which nodes may connect. It requires TLS: plaintext
+	// traffic can be taken over after the handshake, so a key proves nothing.
+	Trusted *Allowlist
+}
+
+// Transport opens connections to peers
+type Transport struct {
+	mode      Mode
+	trusted   *Allowlist
+	tlsConfig *tls.Config
+}
+
+// New creates the transport for the configuration
+func New(config Config) (*Transport, error) {
+	t := &Transport{mode: config.Mode, trusted: config.Trusted}
+
+	switch config.Mode {
+	case "", Plaintext:
+		if config.Trusted.Len() > 0 {
+			return nil, ErrAllowlistNeedsTLS
+		}
+		t.mode = Plaintext
+	case TLS:
+		if config.Identity == nil {
+			return nil, ErrMissingWallet
+		}
+		certificate, err := Certificate(config.Identity)
+		if err != nil {
+			return nil, err
+		}
+
+		// Цепочки сертификатов нет: узел подтверждает владение ключом в рукопожатии TLS,
+		// а доверие к ключу определяет allowlist
+		t.tlsConfig = &tls.Config{
+			MinVersion:            tls.VersionTLS13,
+			Certificates:          []tls.Certificate{certificate},
+			ClientAuth:            tls.RequireAnyClientCert,
+			InsecureSkipVerify:    true,
+			VerifyPeerCertificate: t.verifyPeer,
+		}
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownMode, config.Mode)
+	}
+
+	return t, nil
+}
+
+// Plain returns the plaintext transport that trusts every node
+func Plain() *Transport {
+	return &Transport{mode: Plaintext}
+}
+
+// Mode returns the mode of the transport
+func (t *Transport) Mode() Mode {
+	return t.mode
+}
+
+// Trusts reports whether the node may connect
+func (t *Transport) Trusts(nodeID string) bool {
+	return t.trusted.Trusts(nodeID)
+}
+
+// Restricted reports whether only listed nodes may connect
+func (t *Transport) Restricted() bool {
+	return t.trusted.Len() > 0
+}
+
+// Listen opens the listener for incoming peers
+func (t *Transport) Listen(address string) (net.Listener, error) {
+	if t.tlsConfig != nil {
+		return tls.Listen("tcp", address, t.tlsConfig)
+	}
+	return net.Listen("tcp", address)
+}
+
+// Dial connects to a peer, for TLS the handshake is complete when it returns
+func (t *Transport) Dial(address string) (net.Conn, error) {
+	if t.tlsConfig != nil {
+		dialer := &tls.Dialer{
+			NetDialer: &net.Dialer{Timeout: 10 * time.Second},
+			Config:    t.tlsConfig,
+		}
+		return dialer.Dial("tcp", address)
+	}
+	return net.Dial("tcp", address)
+}
+
+// Handshake completes the TLS handshake of an accepted connection, a no-op for plaintext
+func Handshake(conn net.Conn, timeout time.Duration) error {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return tlsConn.HandshakeContext(ctx)
+}
+
+// PeerNodeID returns the node id of the certificate the peer presented
+func PeerNodeID(conn net.Conn) (string, error) {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return "", errNotTLS
+	}
+
+	certificates := tlsConn.ConnectionState().PeerCertificates
+	if len(certificates) == 0 {
+		return "", ErrNoCertificate
+	}
+	return certificateNodeID(certificates[0])
+}
+
+// IsTLS reports whether the connection is encrypted
+func IsTLS(conn net.Conn) bool {
+	_, ok := conn.(*tls.Conn)
+	return ok
+}
+
+// Certificate creates a self-signed certificate for the node key, the common name is the node id
+func Certificate(w *wallet.Wallet) (tls.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	keyUsage := x509.KeyUsageDigitalSignature
+	if _, isRSA := w.PrivateKey.(*rsa.PrivateKey); isRSA {
+		keyUsage |= x509.KeyUsageKeyEncipherment
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: handshake.NodeID(w.Serialize().PublicKey)},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.AddDate(10, 0, 0),
+		KeyUsage:     keyUsage,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, w.PublicKey, w.PrivateKey)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: w.PrivateKey}, nil
+}
+
+// verifyPeer checks the key of the peer certificate against the allowlist
+func (t *Transport) verifyPeer(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) == 0 {
+		return ErrNoCertificate
+	}
+
+	certificate, err := x509.ParseCertificate(rawCerts[0])
+	if err != nil {
+		return err
+	}
+	nodeID, err := certificateNodeID(certificate)
+	if err != nil {
+		return err
+	}
+
+	if !t.Trusts(nodeID) {
+		return fmt.Errorf("%w: node %s", ErrUntrustedPeer, nodeID)
+	}
+	return nil
+}
+
+func certificateNodeID(certificate *x509.Certificate) (string, error) {
+	publicKey, err := wallet.EncodePublicKey(certificate.PublicKey, wallet.FormatRaw)
+	if err != nil {
+		return "", err
+	}
+	return handshake.NodeID(publicKey), nil
+}
+
+func nodeIDOf(publicKey string) (string, error) {
+	key, err := wallet.ParsePublicKey(strings.TrimSpace(publicKey))
+	if err != nil {
+		return "", err
+	}
+	raw, err := wallet.EncodePublicKey(key, wallet.FormatRaw)
+	if err != nil {
+		return "", err
+	}
+	return handshake.NodeID(raw), nil
+}
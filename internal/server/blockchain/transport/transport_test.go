@@ -0,0 +1,215 @@
+package transport_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"sender/internal/data/blockchain/wallet"
+	"sender/internal/server/blockchain/handshake"
+	"sender/internal/server/blockchain/transport"
+)
+
+func newNode(t *testing.T, algorithm wallet.Algorithm) (*wallet.Wallet, string) {
+	t.Helper()
+
+	w, err := wallet.NewWithAlgorithm(algorithm)
+	if err != nil {
+		t.Fatalf("NewWithAlgorithm failed: %v", err)
+	}
+	return w, handshake.NodeID(w.Serialize().PublicKey)
+}
+
+func newTLS(t *testing.T, identity *wallet.Wallet, trusted ...*wallet.Wallet) *transport.Transport {
+	t.Helper()
+
+	keys := make([]string, 0, len(trusted))
+	for _, w := range trusted {
+		keys = append(keys, w.Serialize().PublicKey)
+	}
+	allowlist, err := transport.NewAllowlist(keys)
+	if err != nil {
+		t.Fatalf("NewAllowlist failed: %v", err)
+	}
+
+	tr, err := transport.New(transport.Config{Mode: transport.TLS, Identity: identity, Trusted: allowlist})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return tr
+}
+
+type accepted struct {
+	nodeID string
+	err    error
+}
+
+// serve accepts one connection, completes the TLS handshake and echoes one line back
+func serve(t *testing.T, tr *transport.Transport) (string, <-chan accepted) {
+	t.Helper()
+
+	listener, err := tr.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	result := make(chan accepted, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			result <- accepted{err: err}
+			return
+		}
+		defer conn.Close()
+
+		if err := transport.Handshake(conn, time.Second); err != nil {
+			result <- accepted{err: err}
+			return
+		}
+		nodeID, err := transport.PeerNodeID(conn)
+		result <- accepted{nodeID: nodeID, err: err}
+
+		buf := make([]byte, 5)
+		if _, err := io.ReadFull(conn, buf); err == nil {
+			conn.Write(buf)
+		}
+	}()
+	return listener.Addr().String(), result
+}
+
+func TestMutualTLS(t *testing.T) {
+	serverWallet, serverID := newNode(t, wallet.Ed25519)
+	clientWallet, clientID := newNode(t, wallet.ECDSAP256)
+
+	addr, result := serve(t, newTLS(t, serverWallet, clientWallet))
+
+	conn, err := newTLS(t, clientWallet, serverWallet).Dial(addr)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	// каждая сторона видит ключ другой стороны
+	if nodeID, err := transport.PeerNodeID(conn); err != nil || nodeID != serverID {
+		t.Errorf("Client expected server %s, got %s: %v", serverID, nodeID, err)
+	}
+	accept := <-result
+	if accept.err != nil || accept.nodeID != clientID {
+		t.Errorf("Server expected client %s, got %s: %v", clientID, accept.nodeID, accept.err)
+	}
+
+	conn.Write([]byte("hello"))
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "hello" {
+		t.Errorf("Echo failed: %q, %v", buf, err)
+	}
+}
+
+func TestUntrustedPeerRefused(t *testing.T) {
+	serverWallet, _ := newNode(t, wallet.Ed25519)
+	clientWallet, _ := newNode(t, wallet.Ed25519)
+	otherWallet, _ := newNode(t, wallet.Ed25519)
+
+	// сервер доверяет только третьему узлу
+	addr, result := serve(t, newTLS(t, serverWallet, otherWallet))
+	conn, err := newTLS(t, clientWallet).Dial(addr)
+	if err == nil {
+		defer conn.Close()
+		conn.Write([]byte("hello"))
+	}
+	if accept := <-result; !errors.Is(accept.err, transport.ErrUntrustedPeer) {
+		t.Errorf("Expected server to refuse the client, got %v", accept.err)
+	}
+
+	// клиент не доверяет серверу
+	addr, _ = serve(t, newTLS(t, serverWallet))
+	if _, err := newTLS(t, clientWallet, otherWallet).Dial(addr); !errors.Is(err, transport.ErrUntrustedPeer) {
+		t.Errorf("Expected client to refuse the server, got %v", err)
+	}
+}
+
+func TestPlaintextPeerRefusedByTLS(t *testing.T) {
+	serverWallet, _ := newNode(t, wallet.Ed25519)
+	addr, result := serve(t, newTLS(t, serverWallet))
+
+	conn, err := transport.Plain().Dial(addr)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("{\"type\":\"ResponseMessageInfo\"}\n"))
+
+	if accept := <-result; accept.err == nil {
+		t.Error("Expected the TLS handshake to fail for a plaintext peer")
+	}
+	if transport.IsTLS(conn) {
+		t.Error("Plaintext connection reported as TLS")
+	}
+}
+
+func TestAllowlist(t *testing.T) {
+	w, nodeID := newNode(t, wallet.RSA)
+	pem, err := wallet.EncodePublicKey(w.PublicKey, wallet.FormatPKCS8)
+	if err != nil {
+		t.Fatalf("EncodePublicKey failed: %v", err)
+	}
+
+	// ключ в PEM дает тот же id, что и сырой
+	list, err := transport.NewAllowlist([]string{pem})
+	if err != nil {
+		t.Fatalf("NewAllowlist failed: %v", err)
+	}
+	if !list.Trusts(nodeID) || list.Trusts("other") {
+		t.Errorf("Unexpected allowlist %d entries", list.Len())
+	}
+
+	path := filepath.Join(t.TempDir(), "trusted_keys")
+	os.WriteFile(path, []byte("# node A\n"+w.Serialize().PublicKey+" # main\n\n"), 0o600)
+	loaded, err := transport.LoadAllowlist(path)
+	if err != nil || loaded.Len() != 1 || !loaded.Trusts(nodeID) {
+		t.Errorf("LoadAllowlist failed: %v", err)
+	}
+
+	if _, err := transport.NewAllowlist([]string{"not-a-key"}); err == nil {
+		t.Error("Expected invalid key to fail")
+	}
+	var empty *transport.Allowlist
+	if !empty.Trusts(nodeID) {
+		t.Error("Empty allowlist must trust every node")
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for name, expected := range map[string]transport.Mode{"": transport.Plaintext, "TLS": transport.TLS, "plaintext": transport.Plaintext} {
+		if mode, err := transport.ParseMode(name); err != nil || mode != expected {
+			t.Errorf("ParseMode(%q) = %s, %v", name, mode, err)
+		}
+	}
+	if _, err := transport.ParseMode("noise"); !errors.Is(err, transport.ErrUnknownMode) {
+		t.Errorf("Expected ErrUnknownMode, got %v", err)
+	}
+	if _, err := transport.New(transport.Config{Mode: transport.TLS}); !errors.Is(err, transport.ErrMissingWallet) {
+		t.Errorf("Expected ErrMissingWallet, got %v", err)
+	}
+}
+
+func TestAllowlistRequiresTLS(t *testing.T) {
+	trusted, _ := newNode(t, wallet.Ed25519)
+	allowlist, err := transport.NewAllowlist([]string{trusted.Serialize().PublicKey})
+	if err != nil {
+		t.Fatalf("NewAllowlist failed: %v", err)
+	}
+
+	if _, err := transport.New(transport.Config{Mode: transport.Plaintext, Trusted: allowlist}); !errors.Is(err, transport.ErrAllowlistNeedsTLS) {
+		t.Errorf("Expected ErrAllowlistNeedsTLS, got %v", err)
+	}
+
+	empty, _ := transport.NewAllowlist(nil)
+	if _, err := transport.New(transport.Config{Mode: transport.Plaintext, Trusted: empty}); err != nil {
+		t.Errorf("Expected plaintext without trusted keys to be allowed, got %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+	"sender/internal/data/blockchain/chain"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ChainState struct {
+	Tip     *chain.TipInfo  `json:"tip"`
+	Forks   []chain.TipInfo `json:"forks"`
+	Orphans int             `json:"orphans"`
+}
+
+// ChainTipHandler returns the head of the best chain
+func ChainTipHandler(blockChain *chain.Chain) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tip, ok := blockChain.Tip()
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "chain is empty"})
+			return
+		}
+
+		c.JSON(http.StatusOK, tip)
+	}
+}
+
+// ChainForksHandler returns the best tip together with all competing branches
+func ChainForksHandler(blockChain *chain.Chain) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		state := ChainState{
+			Forks:   blockChain.Forks(),
+			Orphans: blockChain.OrphanCount(),
+		}
+		if tip, ok := blockChain.Tip(); ok {
+			state.Tip = &tip
+		}
+
+		c.JSON(http.StatusOK, state)
+	}
+}
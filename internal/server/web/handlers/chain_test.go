@@ -0,0 +1,55 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sender/internal/data/blockchain/block"
+	"sender/internal/data/blockchain/chain"
+	"sender/internal/server/web/handlers"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChainTipHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	blockChain := chain.New()
+	r := gin.Default()
+	r.GET("/chain/tip", handlers.ChainTipHandler(blockChain))
+	r.GET("/chain/forks", handlers.ChainForksHandler(blockChain))
+
+	// Пустая цепочка
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/chain/tip", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	genesis := &block.Block{ID: 1, TimeCreated: block.NewTimestamp(time.Unix(1745089962, 0))}
+	_, err := blockChain.Add(genesis)
+	assert.NoError(t, err)
+	genesisHash, _ := genesis.Hash()
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/chain/tip", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var tip chain.TipInfo
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &tip))
+	assert.Equal(t, genesisHash, tip.Hash)
+	assert.Equal(t, 1, tip.Height)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/chain/forks", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var state handlers.ChainState
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &state))
+	assert.Len(t, state.Forks, 1)
+	assert.True(t, state.Forks[0].Best)
+}
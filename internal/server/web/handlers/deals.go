@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+	"sender/internal/tracker"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultStuckAge is used when /deals/stuck is called without older_than
+const defaultStuckAge = 5 * time.Minute
+
+// DealHandler returns the lifecycle of the deal with the given id
+func DealHandler(dealTracker *tracker.Tracker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		dealID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "deal id must be a number"})
+			return
+		}
+
+		record, ok := dealTracker.Get(dealID)
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "deal not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, record)
+	}
+}
+
+// DealBySignatureHandler returns the lifecycle of the deal signed with ?signature=
+func DealBySignatureHandler(dealTracker *tracker.Tracker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		signature := c.Query("signature")
+		if signature == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "signature is required"})
+			return
+		}
+
+		record, ok := dealTracker.GetBySignature(signature)
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "deal not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, record)
+	}
+}
+
+// StuckDealsHandler lists unfinished deals that did not move for ?older_than= (e.g. 10m)
+func StuckDealsHandler(dealTracker *tracker.Tracker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		olderThan := defaultStuckAge
+		if value := c.Query("older_than"); value != "" {
+			var err error
+			olderThan, err = time.ParseDuration(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "older_than must be a duration, e.g. 10m"})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, dealTracker.Stuck(olderThan))
+	}
+}
@@ -0,0 +1,58 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sender/internal/server/web/handlers"
+	"sender/internal/tracker"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDealHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	dealTracker := tracker.New(tracker.DefaultRetention)
+	dealTracker.Record(123, tracker.Ingested, "")
+	dealTracker.AttachTransaction(123, "sig-123", "tx-hash")
+
+	r := gin.Default()
+	r.GET("/deals", handlers.DealBySignatureHandler(dealTracker))
+	r.GET("/deals/stuck", handlers.StuckDealsHandler(dealTracker))
+	r.GET("/deals/:id", handlers.DealHandler(dealTracker))
+
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/deals/123")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var record tracker.Record
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &record))
+	assert.Equal(t, tracker.Signed, record.Stage)
+	assert.Equal(t, "sig-123", record.Signature)
+
+	assert.Equal(t, http.StatusNotFound, get("/deals/999").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/deals/abc").Code)
+
+	w = get("/deals?signature=sig-123")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &record))
+	assert.Equal(t, 123, record.DealID)
+
+	assert.Equal(t, http.StatusBadRequest, get("/deals").Code)
+
+	w = get("/deals/stuck?older_than=0s")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var stuck []tracker.Record
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &stuck))
+	assert.Len(t, stuck, 1)
+
+	assert.Equal(t, http.StatusBadRequest, get("/deals/stuck?older_than=soon").Code)
+}
@@ -10,18 +10,40 @@ import (
 type Keys struct {
 	PublicKey  string `json:"publicKey"`
 	PrivateKey string `json:"privateKey"`
+	Format     string `json:"format"`
+	Algorithm  string `json:"algorithm"`
 }
 
+// KeysGenerateHandler generates a new key pair for ?algorithm= (rsa, ed25519 or ecdsa-p256)
+// encoded as ?format= (raw, pkcs1 or pkcs8)
 func KeysGenerateHandler(c *gin.Context) {
-	newWallet := wallet.New()
-	walletSerialize := newWallet.Sereliaze()
+	format, err := wallet.ParseFormat(c.Query("format"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	algorithm, err := wallet.ParseAlgorithm(c.Query("algorithm"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	publicKey := walletSerialize.PublicKey
-	privateKey := walletSerialize.PrivateKey
+	newWallet, err := wallet.NewWithAlgorithm(algorithm)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	walletSerialize, err := newWallet.Export(format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	jsonKeys := Keys{
-		PublicKey:  publicKey,
-		PrivateKey: privateKey,
+		PublicKey:  walletSerialize.PublicKey,
+		PrivateKey: walletSerialize.PrivateKey,
+		Format:     string(format),
+		Algorithm:  string(newWallet.Algorithm),
 	}
 
 	c.JSON(http.StatusOK, jsonKeys)
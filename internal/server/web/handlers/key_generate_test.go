@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"sender/internal/data/blockchain/wallet"
 	"sender/internal/server/web/handlers"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
@@ -39,3 +41,57 @@ func TestKeysGenerateHandler(t *testing.T) {
 	assert.NotEmpty(t, keys.PublicKey, "PublicKey должен быть непустым")
 	assert.NotEmpty(t, keys.PrivateKey, "PrivateKey должен быть непустым")
 }
+
+func TestKeysGenerateHandlerFormats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.Default()
+	r.GET("/keys", handlers.KeysGenerateHandler)
+
+	for _, format := range []string{"pkcs1", "pkcs8"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/keys?format="+format, nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var keys handlers.Keys
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &keys))
+		assert.Equal(t, format, keys.Format)
+		assert.True(t, strings.HasPrefix(keys.PrivateKey, "-----BEGIN "), "PrivateKey должен быть в PEM")
+
+		// ключи должны импортироваться обратно
+		_, err := wallet.Import(keys.PublicKey, keys.PrivateKey)
+		assert.NoError(t, err)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/keys?format=jwk", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestKeysGenerateHandlerAlgorithm(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.Default()
+	r.GET("/keys", handlers.KeysGenerateHandler)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/keys?algorithm=ed25519&format=pkcs8", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var keys handlers.Keys
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &keys))
+	assert.Equal(t, "ed25519", keys.Algorithm)
+
+	imported, err := wallet.Import(keys.PublicKey, keys.PrivateKey)
+	assert.NoError(t, err)
+	assert.Equal(t, wallet.Ed25519, imported.Algorithm)
+
+	// PKCS#1 не подходит для ed25519
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/keys?algorithm=ed25519&format=pkcs1", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
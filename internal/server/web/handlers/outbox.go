@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+	"sender/internal/storage/outbox"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pendingEntry describes the oldest entry waiting to be published
+type pendingEntry struct {
+	ID         uint64    `json:"id"`
+	Kind       string    `json:"kind"`
+	CreatedAt  time.Time `json:"created_at"`
+	AgeSeconds float64   `json:"age_seconds"`
+	Attempts   int       `json:"attempts"`
+	LastError  string    `json:"last_error,omitempty"`
+}
+
+type outboxStats struct {
+	Depth  int           `json:"depth"`
+	Oldest *pendingEntry `json:"oldest,omitempty"`
+}
+
+// OutboxHandler returns the number of events waiting to be published to kafka and the oldest one
+func OutboxHandler(eventOutbox *outbox.Outbox) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		stats := eventOutbox.Stats()
+
+		result := outboxStats{Depth: stats.Depth}
+		if oldest := stats.Oldest; oldest != nil {
+			result.Oldest = &pendingEntry{
+				ID:         oldest.ID,
+				Kind:       oldest.Kind,
+				CreatedAt:  oldest.CreatedAt,
+				AgeSeconds: time.Since(oldest.CreatedAt).Seconds(),
+				Attempts:   oldest.Attempts,
+				LastError:  oldest.LastError,
+			}
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
@@ -0,0 +1,52 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sender/internal/server/web/handlers"
+	"sender/internal/storage/outbox"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutboxHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	eventOutbox, err := outbox.Open(filepath.Join(t.TempDir(), "outbox.jsonl"))
+	assert.NoError(t, err)
+	defer eventOutbox.Close()
+
+	r := gin.Default()
+	r.GET("/admin/outbox", handlers.OutboxHandler(eventOutbox))
+
+	get := func() map[string]interface{} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/admin/outbox", nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var result map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		return result
+	}
+
+	result := get()
+	assert.Equal(t, float64(0), result["depth"])
+	assert.Nil(t, result["oldest"])
+
+	first, _ := eventOutbox.Append(outbox.Entry{Kind: "DealConfirmed", Value: `{"id":1}`})
+	eventOutbox.Append(outbox.Entry{Kind: "DealConfirmed", Value: `{"id":2}`})
+	eventOutbox.Fail(first.ID, errors.New("broker unavailable"))
+
+	result = get()
+	assert.Equal(t, float64(2), result["depth"])
+	oldest := result["oldest"].(map[string]interface{})
+	assert.Equal(t, float64(first.ID), oldest["id"])
+	assert.Equal(t, float64(1), oldest["attempts"])
+	assert.Equal(t, "broker unavailable", oldest["last_error"])
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+	"sender/internal/server/blockchain/connectionpool"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// peerState describes a connected peer
+type peerState struct {
+	Addr          string    `json:"addr"`
+	NodeID        string    `json:"node_id,omitempty"`
+	Version       int       `json:"version"`
+	ListenAddress string    `json:"listen_address,omitempty"`
+	Codec         string    `json:"codec"`
+	LastSeen      time.Time `json:"last_seen"`
+	IdleSeconds   float64   `json:"idle_seconds"`
+	RTTMillis     *float64  `json:"rtt_ms"`
+	MissedPongs   int       `json:"missed_pongs"`
+	Queued        int       `json:"queued"`
+	Penalties     int       `json:"penalties"`
+}
+
+// PeersHandler returns the connected peers with their round trip time and penalties.
+// penalties may be nil.
+func PeersHandler(pool *connectionpool.ConnectionPool, penalties func() map[string]int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var counts map[string]int
+		if penalties != nil {
+			counts = penalties()
+		}
+
+		stats := pool.Peers()
+		result := make([]peerState, 0, len(stats))
+		for _, peer := range stats {
+			state := peerState{
+				Addr:          peer.Addr,
+				NodeID:        peer.NodeID,
+				Version:       peer.Version,
+				ListenAddress: peer.ListenAddress,
+				Codec:         peer.Codec,
+				LastSeen:      peer.LastSeen,
+				IdleSeconds:   time.Since(peer.LastSeen).Seconds(),
+				MissedPongs:   peer.MissedPongs,
+				Queued:        peer.Queued,
+				Penalties:     counts[peer.Addr],
+			}
+			if peer.RTT > 0 {
+				rtt := float64(peer.RTT) / float64(time.Millisecond)
+				state.RTTMillis = &rtt
+			}
+			result = append(result, state)
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
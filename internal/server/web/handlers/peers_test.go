@@ -0,0 +1,59 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sender/internal/server/blockchain/connectionpool"
+	"sender/internal/server/blockchain/connectionpool/message"
+	"sender/internal/server/blockchain/connectionpool/peer"
+	"sender/internal/server/blockchain/handshake"
+	protocolmessage "sender/internal/server/blockchain/protocol/message"
+	"sender/internal/server/web/handlers"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeersHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	poolChan := make(chan message.PoolMessage, 10)
+	pool := connectionpool.NewConnectionPool(poolChan, 10, make(chan protocolmessage.Message, 10))
+	go pool.Run()
+
+	addr := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 9030}
+	_, server := net.Pipe()
+	defer server.Close()
+	poolChan <- message.PoolMessage{
+		Type:      message.NewPeer,
+		Addr:      addr,
+		Conn:      &peer.ProtectedConnection{Conn: server, Mutex: &sync.Mutex{}},
+		Handshake: &handshake.Handshake{NodeID: "node-a", Version: 1, ListenAddress: "10.0.0.1:7878"},
+	}
+	assert.Eventually(t, func() bool { return len(pool.Peers()) == 1 }, time.Second, 5*time.Millisecond)
+
+	penalties := func() map[string]int { return map[string]int{addr.String(): 2} }
+	r := gin.Default()
+	r.GET("/peers", handlers.PeersHandler(&pool, penalties))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/peers", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var result []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Len(t, result, 1)
+	assert.Equal(t, addr.String(), result[0]["addr"])
+	assert.Equal(t, "node-a", result[0]["node_id"])
+	assert.Equal(t, "10.0.0.1:7878", result[0]["listen_address"])
+	assert.Equal(t, "ndjson", result[0]["codec"])
+	assert.Equal(t, float64(2), result[0]["penalties"])
+	// до первого понга RTT неизвестен
+	assert.Nil(t, result[0]["rtt_ms"])
+}
@@ -2,6 +2,7 @@ package web
 
 import (
 	"log"
+	"sender/internal/app"
 	"sender/internal/server/web/handlers"
 
 	"github.com/gin-gonic/gin"
@@ -13,8 +14,8 @@ type WebServer struct {
 }
 
 // Create a new web server
-func New(port string) WebServer {
-	router := setupRoutes()
+func New(port string, appState *app.AppState) WebServer {
+	router := setupRoutes(appState)
 	return WebServer{
 		Port:   port,
 		router: router,
@@ -34,12 +35,31 @@ func (ws *WebServer) Router() *gin.Engine {
 }
 
 // Function to set up routes
-func setupRoutes() *gin.Engine {
+func setupRoutes(appState *app.AppState) *gin.Engine {
 	router := gin.Default()
 
 	// Register routes
 	router.GET("/health", handlers.HealthHandler)
 	router.GET("/keys/generate", handlers.KeysGenerateHandler)
 
+	if appState != nil && appState.Chain != nil {
+		router.GET("/chain/tip", handlers.ChainTipHandler(appState.Chain))
+		router.GET("/chain/forks", handlers.ChainForksHandler(appState.Chain))
+	}
+
+	if appState != nil && appState.Tracker != nil {
+		router.GET("/deals", handlers.DealBySignatureHandler(appState.Tracker))
+		router.GET("/deals/stuck", handlers.StuckDealsHandler(appState.Tracker))
+		router.GET("/deals/:id", handlers.DealHandler(appState.Tracker))
+	}
+
+	if appState != nil && appState.Pool != nil {
+		router.GET("/peers", handlers.PeersHandler(appState.Pool, appState.Penalties))
+	}
+
+	if appState != nil && appState.Outbox != nil {
+		router.GET("/admin/outbox", handlers.OutboxHandler(appState.Outbox))
+	}
+
 	return router
 }
@@ -0,0 +1,242 @@
+package blockstore
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sender/internal/data/blockchain/block"
+	"sender/internal/jsonutil"
+	"sort"
+	"sync"
+)
+
+var ErrNilBlock = errors.New("cannot store nil block")
+
+// record is a single line of the append-only store file
+type record struct {
+	Hash  string       `json:"hash"`
+	Block *block.Block `json:"block"`
+}
+
+// Store is an append-only on-disk block store with in-memory indexes
+type Store struct {
+	mutex sync.RWMutex
+	file  *os.File
+	path  string
+
+	// blocks in the order they were appended
+	blocks []*record
+
+	byHash      map[string]*record
+	byHeight    map[int][]*record
+	bySignature map[string][]*record
+}
+
+// Open opens (or creates) a block store at the given path and rebuilds its indexes
+func Open(path string) (*Store, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{
+		file:        file,
+		path:        path,
+		byHash:      make(map[string]*record),
+		byHeight:    make(map[int][]*record),
+		bySignature: make(map[string][]*record),
+	}
+
+	if err := s.load(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	log.Printf("Block store opened: %s, blocks: %d", path, len(s.blocks))
+	return s, nil
+}
+
+// load reads all records from disk. A partially written trailing record
+// (e.g. after a crash) is dropped and the file is truncated to the last complete line.
+// Corrupted complete lines are skipped but kept on disk, so the blocks after them survive.
+func (s *Store) load() error {
+	reader := bufio.NewReader(s.file)
+	var offset int64
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				log.Printf("Block store %s: dropping incomplete record at offset %d", s.path, offset)
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		var rec record
+		if err := json.Unmarshal(line, &rec); err != nil || rec.Block == nil {
+			log.Printf("Block store %s: skipping corrupted record at offset %d, file needs inspection", s.path, offset)
+		} else {
+			s.index(&rec)
+		}
+		offset += int64(len(line))
+	}
+
+	if err := s.file.Truncate(offset); err != nil {
+		return err
+	}
+	_, err := s.file.Seek(offset, io.SeekStart)
+	return err
+}
+
+// index adds the record to the in-memory indexes
+func (s *Store) index(rec *record) {
+	if _, exists := s.byHash[rec.Hash]; exists {
+		return
+	}
+
+	s.blocks = append(s.blocks, rec)
+	s.byHash[rec.Hash] = rec
+	s.byHeight[rec.Block.ID] = append(s.byHeight[rec.Block.ID], rec)
+
+	for _, tr := range rec.Block.Transactions {
+		if tr.Signature == "" {
+			continue
+		}
+		s.bySignature[tr.Signature] = append(s.bySignature[tr.Signature], rec)
+	}
+}
+
+// Append writes the block to disk and returns its hash.
+// Appending a block that is already stored is a no-op.
+func (s *Store) Append(b *block.Block) (string, error) {
+	if b == nil {
+		return "", ErrNilBlock
+	}
+
+	hash, err := b.Hash()
+	if err != nil {
+		return "", err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.byHash[hash]; exists {
+		return hash, nil
+	}
+
+	rec := &record{Hash: hash, Block: b}
+	// blocks are written without html escaping, raw transactions keep their hash
+	line, err := jsonutil.Marshal(rec)
+	if err != nil {
+		return "", err
+	}
+	line = append(line, '\n')
+
+	if _, err := s.file.Write(line); err != nil {
+		return "", fmt.Errorf("failed to write block %d: %w", b.ID, err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return "", fmt.Errorf("failed to sync block store: %w", err)
+	}
+
+	s.index(rec)
+	return hash, nil
+}
+
+// Has reports whether a block with the given hash is stored
+func (s *Store) Has(hash string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	_, exists := s.byHash[hash]
+	return exists
+}
+
+// GetByHash returns the block with the given hash
+func (s *Store) GetByHash(hash string) (*block.Block, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	rec, exists := s.byHash[hash]
+	if !exists {
+		return nil, false
+	}
+	return rec.Block, true
+}
+
+// GetByHeight returns every stored block with the given ID (height).
+// More than one block may exist at a height when the network forks.
+func (s *Store) GetByHeight(height int) []*block.Block {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return blocksOf(s.byHeight[height])
+}
+
+// FindTransaction returns the blocks that contain a transaction with the given signature
+func (s *Store) FindTransaction(signature string) []*block.Block {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return blocksOf(s.bySignature[signature])
+}
+
+// Heights returns all stored heights in ascending order
+func (s *Store) Heights() []int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	heights := make([]int, 0, len(s.byHeight))
+	for height := range s.byHeight {
+		heights = append(heights, height)
+	}
+	sort.Ints(heights)
+	return heights
+}
+
+// Blocks returns every stored block in the order it was appended
+func (s *Store) Blocks() []*block.Block {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return blocksOf(s.blocks)
+}
+
+// Len returns the number of stored blocks
+func (s *Store) Len() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return len(s.blocks)
+}
+
+// Close closes the underlying file
+func (s *Store) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.file.Close()
+}
+
+func blocksOf(records []*record) []*block.Block {
+	blocks := make([]*block.Block, 0, len(records))
+	for _, rec := range records {
+		blocks = append(blocks, rec.Block)
+	}
+	return blocks
+}
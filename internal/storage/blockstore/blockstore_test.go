@@ -0,0 +1,190 @@
+package blockstore_test
+
+import (
+	"os"
+	"path/filepath"
+	"sender/internal/data/blockchain/block"
+	"sender/internal/data/blockchain/transaction"
+	"sender/internal/storage/blockstore"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestBlock(id int, previousHash string, signatures ...string) *block.Block {
+	transactions := make([]transaction.Transaction, 0, len(signatures))
+	for _, signature := range signatures {
+		transactions = append(transactions, transaction.Transaction{
+			Sender:      "test_sender",
+			DealMessage: "test_message",
+			Signature:   signature,
+		})
+	}
+
+	return &block.Block{
+		ID:           id,
+		TimeCreated:  block.NewTimestamp(time.Unix(1745089962, 0)),
+		Transactions: transactions,
+		PreviousHash: previousHash,
+		Nonce:        uint64(id),
+	}
+}
+
+func TestAppendAndLookup(t *testing.T) {
+	store, err := blockstore.Open(filepath.Join(t.TempDir(), "blocks.jsonl"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	first := newTestBlock(1, "", "sig-1")
+	hash, err := store.Append(first)
+	if err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+
+	// повторная запись не должна дублировать блок
+	if _, err := store.Append(first); err != nil {
+		t.Fatalf("Append of duplicate failed: %v", err)
+	}
+	if store.Len() != 1 {
+		t.Fatalf("Expected 1 block, got %d", store.Len())
+	}
+
+	if got, ok := store.GetByHash(hash); !ok || got.ID != 1 {
+		t.Errorf("GetByHash returned %v, %v", got, ok)
+	}
+	if blocks := store.GetByHeight(1); len(blocks) != 1 {
+		t.Errorf("Expected 1 block at height 1, got %d", len(blocks))
+	}
+	if blocks := store.FindTransaction("sig-1"); len(blocks) != 1 || blocks[0].ID != 1 {
+		t.Errorf("FindTransaction returned %v", blocks)
+	}
+	if blocks := store.FindTransaction("unknown"); len(blocks) != 0 {
+		t.Errorf("Expected no blocks for unknown signature, got %d", len(blocks))
+	}
+}
+
+func TestAppendNilBlock(t *testing.T) {
+	store, err := blockstore.Open(filepath.Join(t.TempDir(), "blocks.jsonl"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	if _, err := store.Append(nil); err == nil {
+		t.Fatal("Expected error when appending nil block")
+	}
+}
+
+func TestReopenRestoresIndexes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocks.jsonl")
+
+	store, err := blockstore.Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	firstHash, _ := store.Append(newTestBlock(1, "", "sig-1"))
+	store.Append(newTestBlock(2, firstHash, "sig-2"))
+	// форк на той же высоте
+	store.Append(newTestBlock(2, "other", "sig-3"))
+	store.Close()
+
+	reopened, err := blockstore.Open(path)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer reopened.Close()
+
+	if reopened.Len() != 3 {
+		t.Fatalf("Expected 3 blocks after reopen, got %d", reopened.Len())
+	}
+	if !reopened.Has(firstHash) {
+		t.Errorf("Expected block %s after reopen", firstHash)
+	}
+	if blocks := reopened.GetByHeight(2); len(blocks) != 2 {
+		t.Errorf("Expected 2 blocks at height 2, got %d", len(blocks))
+	}
+	if blocks := reopened.FindTransaction("sig-3"); len(blocks) != 1 {
+		t.Errorf("Expected sig-3 to be indexed, got %d blocks", len(blocks))
+	}
+	heights := reopened.Heights()
+	if len(heights) != 2 || heights[0] != 1 || heights[1] != 2 {
+		t.Errorf("Unexpected heights: %v", heights)
+	}
+}
+
+func TestOpenDropsIncompleteRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocks.jsonl")
+
+	store, err := blockstore.Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	store.Append(newTestBlock(1, ""))
+	store.Close()
+
+	// имитируем падение процесса посреди записи
+	file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	file.WriteString(`{"hash":"abc","block":{"id":2`)
+	file.Close()
+
+	reopened, err := blockstore.Open(path)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer reopened.Close()
+
+	if reopened.Len() != 1 {
+		t.Fatalf("Expected 1 block, got %d", reopened.Len())
+	}
+
+	// после восстановления запись продолжается с корректной позиции
+	if _, err := reopened.Append(newTestBlock(2, "")); err != nil {
+		t.Fatalf("Append after recovery failed: %v", err)
+	}
+	reopened.Close()
+
+	again, err := blockstore.Open(path)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer again.Close()
+	if again.Len() != 2 {
+		t.Errorf("Expected 2 blocks, got %d", again.Len())
+	}
+}
+
+func TestOpenSkipsCorruptedRecordInTheMiddle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocks.jsonl")
+
+	store, err := blockstore.Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	store.Append(newTestBlock(1, ""))
+	store.Close()
+
+	// испорченная строка посреди файла не должна стирать блоки после нее
+	file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	file.WriteString("{not json}\n")
+	file.Close()
+
+	store, _ = blockstore.Open(path)
+	store.Append(newTestBlock(2, ""))
+	store.Close()
+
+	reopened, err := blockstore.Open(path)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer reopened.Close()
+
+	if reopened.Len() != 2 {
+		t.Fatalf("Expected 2 blocks around the corrupted line, got %d", reopened.Len())
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "{not json}") {
+		t.Error("Corrupted line must stay on disk for inspection")
+	}
+}
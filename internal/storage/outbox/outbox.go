@@ -0,0 +1,328 @@
+package outbox
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// compactThreshold is the number of acknowledged entries after which the file is rewritten
+const compactThreshold = 1000
+
+var ErrClosed = errors.New("outbox is closed")
+
+// Entry is a message waiting to be published to kafka
+type Entry struct {
+	ID        uint64            `json:"id"`
+	Kind      string            `json:"kind"`
+	Key       string            `json:"key,omitempty"`
+	Value     string            `json:"value"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+
+	// Publish attempts since the node started, not persisted
+	Attempts  int    `json:"-"`
+	LastError string `json:"-"`
+}
+
+// record is a single line of the append-only outbox file
+type record struct {
+	Op    string `json:"op"`
+	Entry *Entry `json:"entry,omitempty"`
+	ID    uint64 `json:"id,omitempty"`
+}
+
+const (
+	opAdd = "add"
+	opAck = "ack"
+)
+
+// Stats describes the pending part of the outbox
+type Stats struct {
+	Depth  int    `json:"depth"`
+	Oldest *Entry `json:"oldest,omitempty"`
+}
+
+// Outbox is a durable FIFO queue of kafka messages. Messages are written to
+// disk before they are published and removed only after the broker acknowledged them.
+type Outbox struct {
+	mutex sync.Mutex
+	file  *os.File
+	path  string
+
+	pending []*Entry
+	nextID  uint64
+	// acknowledged entries still present in the file
+	acked int
+
+	// signaled when an entry is appended
+	notify chan struct{}
+}
+
+// Open opens (or creates) an outbox at the given path and loads the pending entries
+func Open(path string) (*Outbox, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &Outbox{
+		file:   file,
+		path:   path,
+		nextID: 1,
+		notify: make(chan struct{}, 1),
+	}
+	if err := o.load(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	log.Printf("Outbox opened: %s, pending: %d", path, len(o.pending))
+	return o, nil
+}
+
+// load replays the file. A partially written trailing record is dropped.
+// Corrupted complete lines are skipped, so the adds and acks after them survive.
+func (o *Outbox) load() error {
+	reader := bufio.NewReader(o.file)
+	var offset int64
+	byID := make(map[uint64]*Entry)
+	var order []*Entry
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				log.Printf("Outbox %s: dropping incomplete record at offset %d", o.path, offset)
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		var rec record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			log.Printf("Outbox %s: skipping corrupted record at offset %d, file needs inspection", o.path, offset)
+			offset += int64(len(line))
+			continue
+		}
+
+		switch {
+		case rec.Op == opAdd && rec.Entry != nil:
+			byID[rec.Entry.ID] = rec.Entry
+			order = append(order, rec.Entry)
+			o.nextID = max(o.nextID, rec.Entry.ID+1)
+		case rec.Op == opAck:
+			delete(byID, rec.ID)
+			o.acked++
+		}
+		offset += int64(len(line))
+	}
+
+	for _, entry := range order {
+		if _, exists := byID[entry.ID]; exists {
+			o.pending = append(o.pending, entry)
+		}
+	}
+
+	if err := o.file.Truncate(offset); err != nil {
+		return err
+	}
+	_, err := o.file.Seek(offset, io.SeekStart)
+	return err
+}
+
+// Append stores the message on disk and queues it for publishing.
+// The ID and the creation time of the entry are assigned by the outbox.
+func (o *Outbox) Append(message Entry) (Entry, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if o.file == nil {
+		return Entry{}, ErrClosed
+	}
+
+	entry := &Entry{
+		ID:        o.nextID,
+		Kind:      message.Kind,
+		Key:       message.Key,
+		Value:     message.Value,
+		Headers:   message.Headers,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := o.write(record{Op: opAdd, Entry: entry}); err != nil {
+		return Entry{}, err
+	}
+
+	o.nextID++
+	o.pending = append(o.pending, entry)
+
+	select {
+	case o.notify <- struct{}{}:
+	default:
+	}
+	return *entry, nil
+}
+
+// Peek returns the oldest pending entry
+func (o *Outbox) Peek() (Entry, bool) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if len(o.pending) == 0 {
+		return Entry{}, false
+	}
+	return *o.pending[0], true
+}
+
+// Ack removes a published entry. The entry is dropped from the queue even if
+// the acknowledgement could not be written, it is then published again after a restart.
+func (o *Outbox) Ack(id uint64) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	index := o.indexOf(id)
+	if index < 0 {
+		return nil
+	}
+	o.pending = append(o.pending[:index], o.pending[index+1:]...)
+
+	if o.file == nil {
+		return ErrClosed
+	}
+	if err := o.write(record{Op: opAck, ID: id}); err != nil {
+		return err
+	}
+
+	o.acked++
+	if o.acked >= compactThreshold {
+		if err := o.compact(); err != nil {
+			log.Printf("Outbox %s: compaction failed: %v", o.path, err)
+		}
+	}
+	return nil
+}
+
+// Fail records a failed publish attempt and returns the number of attempts
+func (o *Outbox) Fail(id uint64, reason error) int {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	index := o.indexOf(id)
+	if index < 0 {
+		return 0
+	}
+	entry := o.pending[index]
+	entry.Attempts++
+	entry.LastError = reason.Error()
+	return entry.Attempts
+}
+
+// Stats returns the number of pending entries and the oldest one
+func (o *Outbox) Stats() Stats {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	stats := Stats{Depth: len(o.pending)}
+	if len(o.pending) > 0 {
+		oldest := *o.pending[0]
+		stats.Oldest = &oldest
+	}
+	return stats
+}
+
+// Len returns the number of pending entries
+func (o *Outbox) Len() int {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	return len(o.pending)
+}
+
+// Close closes the outbox file
+func (o *Outbox) Close() error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if o.file == nil {
+		return nil
+	}
+	err := o.file.Close()
+	o.file = nil
+	return err
+}
+
+func (o *Outbox) indexOf(id uint64) int {
+	for i, entry := range o.pending {
+		if entry.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (o *Outbox) write(rec record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if _, err := o.file.Write(line); err != nil {
+		return fmt.Errorf("failed to write outbox record: %w", err)
+	}
+	if err := o.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync outbox: %w", err)
+	}
+	return nil
+}
+
+// compact rewrites the file with the pending entries only
+func (o *Outbox) compact() error {
+	tmpPath := o.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(tmp)
+	for _, entry := range o.pending {
+		line, err := json.Marshal(record{Op: opAdd, Entry: entry})
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		writer.Write(append(line, '\n'))
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := os.Rename(tmpPath, o.path); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	o.file.Close()
+	o.file = tmp
+	o.acked = 0
+	return nil
+}
@@ -0,0 +1,185 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sender/internal/storage/outbox"
+	"testing"
+	"time"
+)
+
+func TestAppendAckAndReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+
+	box, err := outbox.Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	first, _ := box.Append(outbox.Entry{Kind: "DealConfirmed", Value: `{"id":1}`})
+	second, _ := box.Append(outbox.Entry{
+		Kind:    "DealFailed",
+		Key:     "2",
+		Value:   `{"id":2}`,
+		Headers: map[string]string{"event-type": "DealFailed"},
+	})
+
+	if entry, ok := box.Peek(); !ok || entry.ID != first.ID {
+		t.Fatalf("Expected oldest entry first, got %+v", entry)
+	}
+	if err := box.Ack(first.ID); err != nil {
+		t.Fatalf("Ack failed: %v", err)
+	}
+	box.Close()
+
+	// неподтвержденные записи переживают перезапуск
+	reopened, err := outbox.Open(path)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer reopened.Close()
+
+	stats := reopened.Stats()
+	if stats.Depth != 1 || stats.Oldest == nil || stats.Oldest.ID != second.ID || stats.Oldest.Value != `{"id":2}` {
+		t.Fatalf("Expected only the second entry to remain, got %+v", stats)
+	}
+	if stats.Oldest.Key != "2" || stats.Oldest.Headers["event-type"] != "DealFailed" {
+		t.Errorf("Expected key and headers to be persisted, got %+v", stats.Oldest)
+	}
+
+	third, _ := reopened.Append(outbox.Entry{Kind: "DealConfirmed", Value: `{"id":3}`})
+	if third.ID <= second.ID {
+		t.Errorf("Expected IDs to keep growing after reopen, got %d after %d", third.ID, second.ID)
+	}
+}
+
+func TestOpenDropsIncompleteRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+
+	box, _ := outbox.Open(path)
+	box.Append(outbox.Entry{Kind: "DealConfirmed", Value: `{"id":1}`})
+	box.Close()
+
+	file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	file.WriteString(`{"op":"add","entry":{"id":2,`)
+	file.Close()
+
+	reopened, err := outbox.Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer reopened.Close()
+	if reopened.Len() != 1 {
+		t.Errorf("Expected 1 pending entry, got %d", reopened.Len())
+	}
+}
+
+func TestOpenSkipsCorruptedRecordInTheMiddle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+
+	box, _ := outbox.Open(path)
+	first, _ := box.Append(outbox.Entry{Kind: "DealConfirmed", Value: `{"id":1}`})
+	box.Close()
+
+	// испорченная строка не должна стирать добавления и подтверждения после нее
+	file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	file.WriteString("{not json}\n")
+	file.Close()
+
+	box, _ = outbox.Open(path)
+	box.Append(outbox.Entry{Kind: "DealConfirmed", Value: `{"id":2}`})
+	if err := box.Ack(first.ID); err != nil {
+		t.Fatalf("Ack failed: %v", err)
+	}
+	box.Close()
+
+	reopened, err := outbox.Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer reopened.Close()
+
+	entry, exists := reopened.Peek()
+	if reopened.Len() != 1 || !exists || entry.Value != `{"id":2}` {
+		t.Errorf("Expected only the second entry to be pending, got %d entries, %+v", reopened.Len(), entry)
+	}
+}
+
+func TestRunRetriesUntilAcknowledged(t *testing.T) {
+	box, _ := outbox.Open(filepath.Join(t.TempDir(), "outbox.jsonl"))
+	defer box.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	published := make(chan string, 10)
+	attempts := 0
+	go box.Run(ctx, func(entry outbox.Entry) error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("broker unavailable")
+		}
+		published <- entry.Value
+		return nil
+	}, outbox.Backoff{Initial: time.Millisecond, Max: 5 * time.Millisecond})
+
+	box.Append(outbox.Entry{Kind: "DealConfirmed", Value: `{"id":1}`})
+	box.Append(outbox.Entry{Kind: "DealConfirmed", Value: `{"id":2}`})
+
+	for _, expected := range []string{`{"id":1}`, `{"id":2}`} {
+		select {
+		case value := <-published:
+			if value != expected {
+				t.Fatalf("Expected %s, got %s", expected, value)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Entry was not published")
+		}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for box.Len() != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if box.Len() != 0 {
+		t.Errorf("Expected published entries to be removed, got %d", box.Len())
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	backoff := outbox.Backoff{Initial: time.Second, Max: 5 * time.Second}
+
+	expected := []time.Duration{time.Second, time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for attempts, delay := range expected {
+		if got := backoff.Delay(attempts); got != delay {
+			t.Errorf("Delay(%d) = %s, want %s", attempts, got, delay)
+		}
+	}
+}
+
+func TestAckCompactsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	box, _ := outbox.Open(path)
+	defer box.Close()
+
+	for i := 0; i < 1000; i++ {
+		entry, _ := box.Append(outbox.Entry{Kind: "DealConfirmed", Value: `{}`})
+		box.Ack(entry.ID)
+	}
+	pending, _ := box.Append(outbox.Entry{Kind: "DealConfirmed", Value: `{"id":"pending"}`})
+
+	info, _ := os.Stat(path)
+	if info.Size() > 200 {
+		t.Errorf("Expected acknowledged entries to be compacted, file size %d", info.Size())
+	}
+
+	reopened, err := outbox.Open(path)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer reopened.Close()
+	if entry, ok := reopened.Peek(); !ok || entry.ID != pending.ID {
+		t.Errorf("Expected pending entry to survive compaction, got %+v", entry)
+	}
+}
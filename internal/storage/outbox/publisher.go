@@ -0,0 +1,59 @@
+package outbox
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Backoff is the exponential delay between failed publish attempts
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+var DefaultBackoff = Backoff{Initial: 500 * time.Millisecond, Max: time.Minute}
+
+// Delay returns the pause after the given number of failed attempts
+func (b Backoff) Delay(attempts int) time.Duration {
+	delay := b.Initial
+	for i := 1; i < attempts && delay < b.Max; i++ {
+		delay *= 2
+	}
+	return min(delay, b.Max)
+}
+
+// Run publishes pending entries in order until the context is done. An entry
+// is acknowledged only after publish returns nil, a failed entry is retried
+// with exponential backoff and blocks the entries behind it to keep the order.
+func (o *Outbox) Run(ctx context.Context, publish func(Entry) error, backoff Backoff) {
+	for {
+		entry, exists := o.Peek()
+		if !exists {
+			select {
+			case <-ctx.Done():
+				return
+			case <-o.notify:
+			}
+			continue
+		}
+
+		if err := publish(entry); err != nil {
+			attempts := o.Fail(entry.ID, err)
+			delay := backoff.Delay(attempts)
+			log.Printf("Failed to publish outbox entry %d (%s), attempt %d, retrying in %s: %v", entry.ID, entry.Kind, attempts, delay, err)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			continue
+		}
+
+		if err := o.Ack(entry.ID); err != nil {
+			// запись будет опубликована повторно только после перезапуска
+			log.Printf("Failed to acknowledge outbox entry %d: %v", entry.ID, err)
+		}
+	}
+}
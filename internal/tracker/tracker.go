@@ -0,0 +1,275 @@
+package tracker
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultRetention is how long finished deals are kept in the tracker
+const DefaultRetention = 24 * time.Hour
+
+// Stage is a step of the deal lifecycle
+type Stage string
+
+const (
+	Ingested  Stage = "ingested"
+	Rejected  Stage = "rejected"
+	Signed    Stage = "signed"
+	Broadcast Stage = "broadcast"
+	Pending   Stage = "mempool"
+	InBlock   Stage = "in_block"
+	Confirmed Stage = "confirmed"
+	Reverted  Stage = "reverted"
+	Failed    Stage = "failed"
+)
+
+// IsFinal reports whether the deal cannot move further
+func (s Stage) IsFinal() bool {
+	return s == Confirmed || s == Failed || s == Rejected
+}
+
+// StageEvent is a single recorded transition
+type StageEvent struct {
+	Stage  Stage     `json:"stage"`
+	At     time.Time `json:"at"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// Record is the lifecycle of a single deal
+type Record struct {
+	DealID        int          `json:"deal_id"`
+	Stage         Stage        `json:"stage"`
+	Signature     string       `json:"signature,omitempty"`
+	TransactionID string       `json:"transaction_id,omitempty"`
+	CorrelationID string       `json:"correlation_id,omitempty"`
+	BlockID       int          `json:"block_id,omitempty"`
+	BlockHash     string       `json:"block_hash,omitempty"`
+	Confirmations int          `json:"confirmations"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+	History       []StageEvent `json:"history"`
+}
+
+// Tracker follows deals from kafka ingest to confirmation.
+// All methods are safe to call on a nil tracker.
+type Tracker struct {
+	mutex       sync.RWMutex
+	byDeal      map[int]*Record
+	bySignature map[string]int
+	retention   time.Duration
+}
+
+// New creates an empty tracker
+func New(retention time.Duration) *Tracker {
+	return &Tracker{
+		byDeal:      make(map[int]*Record),
+		bySignature: make(map[string]int),
+		retention:   retention,
+	}
+}
+
+// Record moves the deal to the given stage
+func (t *Tracker) Record(dealID int, stage Stage, detail string) {
+	if t == nil {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.record(t.getOrCreate(dealID), stage, detail)
+}
+
+// Correlate remembers the ID of the inbound message the deal came from
+func (t *Tracker) Correlate(dealID int, correlationID string) {
+	if t == nil {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.getOrCreate(dealID).CorrelationID = correlationID
+}
+
+// AttachTransaction links the signed transaction to the deal
+func (t *Tracker) AttachTransaction(dealID int, signature string, transactionID string) {
+	if t == nil {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	rec := t.getOrCreate(dealID)
+	if rec.Signature != "" {
+		delete(t.bySignature, rec.Signature)
+	}
+	rec.Signature = signature
+	rec.TransactionID = transactionID
+	t.bySignature[signature] = dealID
+	t.record(rec, Signed, "")
+}
+
+// RecordBySignature moves the deal owning the transaction to the given stage.
+// Transactions of deals that are not tracked are ignored.
+func (t *Tracker) RecordBySignature(signature string, stage Stage, detail string) {
+	if t == nil {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if rec, exists := t.lookup(signature); exists {
+		t.record(rec, stage, detail)
+	}
+}
+
+// RecordBlock moves the deal owning the transaction to a block stage
+// (InBlock, Confirmed or Reverted) and remembers the block it is in
+func (t *Tracker) RecordBlock(signature string, stage Stage, blockID int, blockHash string, confirmations int) {
+	if t == nil {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	rec, exists := t.lookup(signature)
+	if !exists {
+		return
+	}
+
+	if stage == Reverted {
+		rec.BlockID = 0
+		rec.BlockHash = ""
+		rec.Confirmations = 0
+	} else {
+		rec.BlockID = blockID
+		rec.BlockHash = blockHash
+		rec.Confirmations = confirmations
+	}
+	t.record(rec, stage, blockHash)
+}
+
+// Get returns the lifecycle of the deal
+func (t *Tracker) Get(dealID int) (Record, bool) {
+	if t == nil {
+		return Record{}, false
+	}
+
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	rec, exists := t.byDeal[dealID]
+	if !exists {
+		return Record{}, false
+	}
+	return copyRecord(rec), true
+}
+
+// GetBySignature returns the lifecycle of the deal signed with the given signature
+func (t *Tracker) GetBySignature(signature string) (Record, bool) {
+	if t == nil {
+		return Record{}, false
+	}
+
+	t.mutex.RLock()
+	dealID, exists := t.bySignature[signature]
+	t.mutex.RUnlock()
+
+	if !exists {
+		return Record{}, false
+	}
+	return t.Get(dealID)
+}
+
+// InFlight reports whether a signed transaction of the deal was already handed
+// to the mempool or peers. A redelivered deal in flight must not be signed again.
+func (t *Tracker) InFlight(dealID int) bool {
+	rec, exists := t.Get(dealID)
+	if !exists || rec.Signature == "" {
+		return false
+	}
+
+	switch rec.Stage {
+	case Broadcast, Pending, InBlock, Confirmed, Reverted:
+		return true
+	}
+	return false
+}
+
+// Stuck returns unfinished deals that have not moved for longer than olderThan, oldest first
+func (t *Tracker) Stuck(olderThan time.Duration) []Record {
+	if t == nil {
+		return nil
+	}
+
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	now := time.Now()
+	stuck := []Record{}
+	for _, rec := range t.byDeal {
+		if rec.Stage.IsFinal() || now.Sub(rec.UpdatedAt) < olderThan {
+			continue
+		}
+		stuck = append(stuck, copyRecord(rec))
+	}
+
+	sort.Slice(stuck, func(i, j int) bool {
+		return stuck[i].UpdatedAt.Before(stuck[j].UpdatedAt)
+	})
+	return stuck
+}
+
+func (t *Tracker) getOrCreate(dealID int) *Record {
+	rec, exists := t.byDeal[dealID]
+	if !exists {
+		t.prune()
+		now := time.Now()
+		rec = &Record{
+			DealID:    dealID,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		t.byDeal[dealID] = rec
+	}
+	return rec
+}
+
+func (t *Tracker) lookup(signature string) (*Record, bool) {
+	dealID, exists := t.bySignature[signature]
+	if !exists {
+		return nil, false
+	}
+	rec, exists := t.byDeal[dealID]
+	return rec, exists
+}
+
+func (t *Tracker) record(rec *Record, stage Stage, detail string) {
+	now := time.Now()
+	rec.Stage = stage
+	rec.UpdatedAt = now
+	rec.History = append(rec.History, StageEvent{Stage: stage, At: now, Detail: detail})
+}
+
+// prune forgets finished deals older than the retention
+func (t *Tracker) prune() {
+	now := time.Now()
+	for dealID, rec := range t.byDeal {
+		if !rec.Stage.IsFinal() || now.Sub(rec.UpdatedAt) < t.retention {
+			continue
+		}
+		delete(t.bySignature, rec.Signature)
+		delete(t.byDeal, dealID)
+	}
+}
+
+func copyRecord(rec *Record) Record {
+	result := *rec
+	result.History = append([]StageEvent(nil), rec.History...)
+	return result
+}
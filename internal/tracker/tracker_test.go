@@ -0,0 +1,146 @@
+package tracker_test
+
+import (
+	"sender/internal/tracker"
+	"testing"
+	"time"
+)
+
+func TestLifecycle(t *testing.T) {
+	dealTracker := tracker.New(tracker.DefaultRetention)
+
+	dealTracker.Record(123, tracker.Ingested, "")
+	dealTracker.AttachTransaction(123, "sig-123", "tx-hash")
+	dealTracker.RecordBySignature("sig-123", tracker.Pending, "tx-hash")
+	dealTracker.RecordBySignature("sig-123", tracker.Broadcast, "")
+	dealTracker.RecordBlock("sig-123", tracker.InBlock, 5, "block-hash", 1)
+	dealTracker.RecordBlock("sig-123", tracker.Confirmed, 5, "block-hash", 3)
+
+	// транзакции чужих сделок игнорируются
+	dealTracker.RecordBlock("unknown", tracker.InBlock, 5, "block-hash", 1)
+
+	record, ok := dealTracker.Get(123)
+	if !ok {
+		t.Fatal("Expected deal 123 to be tracked")
+	}
+	if record.Stage != tracker.Confirmed {
+		t.Errorf("Expected stage %s, got %s", tracker.Confirmed, record.Stage)
+	}
+	if record.BlockID != 5 || record.BlockHash != "block-hash" || record.Confirmations != 3 {
+		t.Errorf("Unexpected block info: %+v", record)
+	}
+
+	expected := []tracker.Stage{
+		tracker.Ingested, tracker.Signed, tracker.Pending, tracker.Broadcast, tracker.InBlock, tracker.Confirmed,
+	}
+	if len(record.History) != len(expected) {
+		t.Fatalf("Expected %d history entries, got %d", len(expected), len(record.History))
+	}
+	for i, stage := range expected {
+		if record.History[i].Stage != stage {
+			t.Errorf("History[%d]: expected %s, got %s", i, stage, record.History[i].Stage)
+		}
+		if record.History[i].At.IsZero() {
+			t.Errorf("History[%d] has no timestamp", i)
+		}
+	}
+
+	bySignature, ok := dealTracker.GetBySignature("sig-123")
+	if !ok || bySignature.DealID != 123 {
+		t.Errorf("GetBySignature returned %+v, %v", bySignature, ok)
+	}
+	if _, ok := dealTracker.Get(999); ok {
+		t.Error("Expected unknown deal to be missing")
+	}
+}
+
+func TestRevertedClearsBlock(t *testing.T) {
+	dealTracker := tracker.New(tracker.DefaultRetention)
+	dealTracker.AttachTransaction(1, "sig-1", "tx-hash")
+	dealTracker.RecordBlock("sig-1", tracker.InBlock, 2, "block-hash", 1)
+	dealTracker.RecordBlock("sig-1", tracker.Reverted, 0, "", 0)
+
+	record, _ := dealTracker.Get(1)
+	if record.Stage != tracker.Reverted || record.BlockHash != "" || record.BlockID != 0 {
+		t.Errorf("Unexpected record after revert: %+v", record)
+	}
+}
+
+func TestStuck(t *testing.T) {
+	dealTracker := tracker.New(tracker.DefaultRetention)
+	dealTracker.Record(1, tracker.Ingested, "")
+	dealTracker.AttachTransaction(2, "sig-2", "tx-hash")
+	dealTracker.RecordBlock("sig-2", tracker.Confirmed, 1, "block-hash", 3)
+
+	if stuck := dealTracker.Stuck(time.Hour); len(stuck) != 0 {
+		t.Errorf("Expected no stuck deals, got %d", len(stuck))
+	}
+
+	// завершенные сделки не считаются зависшими
+	stuck := dealTracker.Stuck(0)
+	if len(stuck) != 1 || stuck[0].DealID != 1 {
+		t.Errorf("Expected deal 1 to be stuck, got %+v", stuck)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	dealTracker := tracker.New(0)
+	dealTracker.AttachTransaction(1, "sig-1", "tx-hash")
+	dealTracker.RecordBySignature("sig-1", tracker.Failed, "expired")
+	dealTracker.Record(2, tracker.Ingested, "")
+
+	if _, ok := dealTracker.Get(1); ok {
+		t.Error("Expected finished deal to be pruned")
+	}
+	if _, ok := dealTracker.GetBySignature("sig-1"); ok {
+		t.Error("Expected signature index to be pruned")
+	}
+	if _, ok := dealTracker.Get(2); !ok {
+		t.Error("Expected new deal to be tracked")
+	}
+}
+
+func TestNilTracker(t *testing.T) {
+	var dealTracker *tracker.Tracker
+	dealTracker.Record(1, tracker.Ingested, "")
+	dealTracker.RecordBySignature("sig", tracker.Broadcast, "")
+	if _, ok := dealTracker.Get(1); ok {
+		t.Error("Expected nil tracker to track nothing")
+	}
+}
+
+func TestInFlight(t *testing.T) {
+	dealTracker := tracker.New(tracker.DefaultRetention)
+
+	dealTracker.Record(1, tracker.Ingested, "")
+	if dealTracker.InFlight(1) {
+		t.Error("Ingested deal is not in flight")
+	}
+
+	dealTracker.AttachTransaction(1, "sig-1", "tx-1")
+	if dealTracker.InFlight(1) {
+		t.Error("Signed but not broadcast deal is not in flight")
+	}
+
+	dealTracker.RecordBySignature("sig-1", tracker.Broadcast, "")
+	if !dealTracker.InFlight(1) {
+		t.Error("Expected broadcast deal to be in flight")
+	}
+
+	dealTracker.RecordBySignature("sig-1", tracker.Failed, "expired")
+	if dealTracker.InFlight(1) || dealTracker.InFlight(2) {
+		t.Error("Failed and unknown deals are not in flight")
+	}
+}
+
+func TestCorrelate(t *testing.T) {
+	dealTracker := tracker.New(tracker.DefaultRetention)
+
+	dealTracker.Correlate(1, "GoGetDeal-0-15")
+	dealTracker.AttachTransaction(1, "sig-1", "tx-1")
+
+	record, ok := dealTracker.GetBySignature("sig-1")
+	if !ok || record.CorrelationID != "GoGetDeal-0-15" {
+		t.Errorf("Expected correlation ID to be kept, got %+v", record)
+	}
+}
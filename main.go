@@ -6,89 +6,355 @@ import (
 	"log"
 	"os"
 	"sender/internal/app"
+	"sender/internal/data/blockchain/block"
+	"sender/internal/data/blockchain/chain"
+	"sender/internal/data/blockchain/mempool"
 	"sender/internal/data/blockchain/transaction"
 	"sender/internal/data/blockchain/wallet"
 	"sender/internal/data/deal"
+	"sender/internal/data/dealevent"
+	"sender/internal/data/validation"
 	"sender/internal/process"
 	"sender/internal/server/blockchain"
 	"sender/internal/server/blockchain/connectionpool"
 	messagePool "sender/internal/server/blockchain/connectionpool/message"
+	"sender/internal/server/blockchain/handshake"
 	"sender/internal/server/blockchain/protocol"
 	messageProtocol "sender/internal/server/blockchain/protocol/message"
+	"sender/internal/server/blockchain/transport"
 	"sender/internal/server/web"
+	"sender/internal/storage/blockstore"
+	"sender/internal/storage/outbox"
+	"sender/internal/tracker"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
 )
 
-func readFromKafkaMessage(kafkaConsumer *process.KafkaProcess, appState *app.AppState, wallet *wallet.Wallet) {
+// lookupDuration reads a duration (e.g. "30s") from the environment
+func lookupDuration(name string, defaultValue time.Duration) time.Duration {
+	value, exist := os.LookupEnv(name)
+	if !exist {
+		return defaultValue
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", name, err)
+	}
+	return duration
+}
+
+// loadWallet returns the node wallet so the Sender key survives restarts.
+// NODE_WALLET holds the wallet itself, otherwise it is kept in NODE_WALLET_PATH
+// and created on first run with NODE_WALLET_ALGORITHM (rsa, ed25519 or ecdsa-p256).
+// NODE_WALLET_PASSWORD encrypts the private key.
+func loadWallet() *wallet.Wallet {
+	password := os.Getenv("NODE_WALLET_PASSWORD")
+
+	if walletData, exist := os.LookupEnv("NODE_WALLET"); exist {
+		nodeWallet, err := wallet.Unmarshal([]byte(walletData), password)
+		if err != nil {
+			log.Fatalf("Invalid NODE_WALLET: %v", err)
+		}
+		return nodeWallet
+	}
+
+	walletPath, exist := os.LookupEnv("NODE_WALLET_PATH")
+	if !exist {
+		walletPath = "data/wallet.json"
+	}
+	algorithm, err := wallet.ParseAlgorithm(os.Getenv("NODE_WALLET_ALGORITHM"))
+	if err != nil {
+		log.Fatalf("Invalid NODE_WALLET_ALGORITHM: %v", err)
+	}
+	nodeWallet, err := wallet.LoadOrCreate(walletPath, password, algorithm)
+	if err != nil {
+		log.Fatalf("Failed to load wallet: %v", err)
+	}
+	return nodeWallet
+}
+
+// correlationID returns the correlation id sent with the inbound message,
+// or its topic, partition and offset if the sender did not set one
+func correlationID(msg kafka.Message) string {
+	if id := process.HeaderValue(msg, dealevent.HeaderCorrelationID); id != "" {
+		return id
+	}
+	return fmt.Sprintf("%s-%d-%d", msg.Topic, msg.Partition, msg.Offset)
+}
+
+// loadTransport configures the P2P transport. P2P_TRANSPORT=tls encrypts traffic with
+// certificates made from the node key; P2P_TRUSTED_KEYS (comma separated) or
+// P2P_TRUSTED_KEYS_PATH (one key per line) limit which nodes may connect and
+// require P2P_TRANSPORT=tls.
+func loadTransport(nodeWallet *wallet.Wallet) *transport.Transport {
+	mode, err := transport.ParseMode(os.Getenv("P2P_TRANSPORT"))
+	if err != nil {
+		log.Fatalf("Invalid P2P_TRANSPORT: %v", err)
+	}
+
+	var trusted *transport.Allowlist
+	if keysPath, exist := os.LookupEnv("P2P_TRUSTED_KEYS_PATH"); exist {
+		trusted, err = transport.LoadAllowlist(keysPath)
+	} else {
+		trusted, err = transport.NewAllowlist(strings.Split(os.Getenv("P2P_TRUSTED_KEYS"), ","))
+	}
+	if err != nil {
+		log.Fatalf("Invalid trusted node keys: %v", err)
+	}
+
+	p2pTransport, err := transport.New(transport.Config{Mode: mode, Identity: nodeWallet, Trusted: trusted})
+	if err != nil {
+		log.Fatalf("Failed to configure P2P transport: %v", err)
+	}
+	if trusted.Len() == 0 {
+		log.Printf("P2P transport %s, every node is trusted", mode)
+	} else {
+		log.Printf("P2P transport %s, %d trusted nodes", mode, trusted.Len())
+	}
+	return p2pTransport
+}
+
+func readFromKafkaMessage(kafkaConsumer *process.KafkaProcess, deadLetter *process.KafkaProcess, appState *app.AppState, wallet *wallet.Wallet) {
+	// offset коммитится после записи транзакции в mempool, без диска сделка потеряется при падении
+	if appState.Mempool == nil || !appState.Mempool.Persistent() {
+		log.Fatal("Kafka consumer requires a persisted mempool, set MEMPOOL_PATH")
+	}
+
 	kafkaConsumer.ConnectReader()
 	defer kafkaConsumer.CloseReader()
 
-	// функция для отправки транзакции
-	handleMessage := func(msg string) {
-		log.Printf("Processing message from kafka: %s", msg)
+	// сделка не подписывается: сообщение уходит в dead-letter топик, Spring получает DealRejected
+	rejectDeal := func(msg kafka.Message, dealID int, reason error) error {
+		correlation := correlationID(msg)
+		log.Printf("Deal %d rejected: %v", dealID, reason)
+		if err := deadLetter.WriteKafkaMessage(context.Background(), process.DeadLetter(msg, reason, time.Now())); err != nil {
+			return fmt.Errorf("failed to write deal %d to dead-letter topic %s: %w", dealID, deadLetter.GetTopicName(), err)
+		}
+		appState.RejectDeal(dealID, correlation, msg.Value, reason)
+		return nil
+	}
 
-		newDeal, err := deal.FromJson([]byte(msg))
+	// функция для отправки транзакции, offset коммитится только если она вернула nil
+	handleMessage := func(msg kafka.Message) error {
+		log.Printf("Processing message from kafka: %s", msg.Value)
+
+		newDeal, err := deal.FromJson(msg.Value)
 		if err != nil {
-			fmt.Println(err)
-			panic("Deal read error")
+			return rejectDeal(msg, 0, fmt.Errorf("deal read error: %w", err))
+		}
+
+		// повторно доставленная сделка уже отправлена, подписывать ее еще раз нельзя
+		if appState.Tracker.InFlight(newDeal.ID) {
+			log.Printf("Deal %d is already in flight, skipping redelivery", newDeal.ID)
+			return nil
 		}
+		appState.Tracker.Record(newDeal.ID, tracker.Ingested, "")
+		appState.Tracker.Correlate(newDeal.ID, correlationID(msg))
 
-		newTransaction, _ := transaction.New(wallet, newDeal)
-		newTransaction.Sign()
+		if err := validation.ValidateDeal(newDeal); err != nil {
+			return rejectDeal(msg, newDeal.ID, err)
+		}
 
-		appState.SendTransaction(&newTransaction)
+		newTransaction, err := transaction.New(wallet, newDeal)
+		if err != nil {
+			return rejectDeal(msg, newDeal.ID, err)
+		}
+		if err := newTransaction.Sign(); err != nil {
+			return err
+		}
+		transactionID, _ := newTransaction.ID()
+		appState.Tracker.AttachTransaction(newDeal.ID, newTransaction.Signature, transactionID)
+
+		return appState.SendTransaction(&newTransaction)
 	}
 
-	err := kafkaConsumer.ReadMessages(context.Background(), handleMessage)
+	err := kafkaConsumer.ConsumeMessages(context.Background(), handleMessage)
 	if err != nil {
 		log.Fatal("Error of reading", err)
 	}
 }
 
-func sendToKafkaMessage(kafkaProducer *process.KafkaProcess, kafkaChan chan messageProtocol.MessageInterface) {
+// sendToKafkaMessage publishes deal events from the outbox,
+// an event leaves the outbox only after kafka acknowledged it
+func sendToKafkaMessage(kafkaProducer *process.KafkaProcess, eventOutbox *outbox.Outbox) {
 	kafkaProducer.ConnectWriter()
 	defer kafkaProducer.Close()
 
-	for messageGet := range kafkaChan {
-		switch msg := messageGet.(type) {
-		case *messageProtocol.BlockMessage:
-			for _, tr := range msg.Block.Transactions {
-				log.Printf("Transaction send to kafka topic: %s", kafkaProducer.GetTopicName())
-				message := tr.DealMessage
-				kafkaProducer.WriteMessage(context.Background(), string(message))
-			}
-		default:
-			fmt.Println("Неизвестный тип сообщения")
-		}
-	}
+	eventOutbox.Run(context.Background(), func(entry outbox.Entry) error {
+		log.Printf("%s send to kafka topic: %s", entry.Kind, kafkaProducer.GetTopicName())
+		return kafkaProducer.WriteKafkaMessage(context.Background(), process.NewMessage(entry.Key, entry.Value, entry.Headers))
+	}, outbox.DefaultBackoff)
 }
 
 func initialize() (*blockchain.Server, *connectionpool.ConnectionPool, *protocol.P2PProtocol, *app.AppState) {
+	// open local block store
+	blockStorePath, exist := os.LookupEnv("BLOCK_STORE_PATH")
+	if !exist {
+		blockStorePath = "data/blocks.jsonl"
+	}
+	blockStore, err := blockstore.Open(blockStorePath)
+	if err != nil {
+		log.Fatalf("Failed to open block store: %v", err)
+	}
+
 	//initialize chans
 	protocolChan := make(chan messageProtocol.Message, 100)
 	poolChan := make(chan messagePool.PoolMessage, 100)
 
 	// create server and appstate
 	server := blockchain.NewServer(poolChan)
-	pool := connectionpool.NewConnectionPool(poolChan, 60, protocolChan)
+	// peers are evicted after 10 minutes without inbound traffic or after missing
+	// P2P_MAX_MISSED_PONGS pings sent every P2P_PING_INTERVAL
+	pool := connectionpool.NewConnectionPool(poolChan, 600, protocolChan)
+	maxMissedPongs := connectionpool.DefaultMaxMissedPongs
+	if missedEnv, exist := os.LookupEnv("P2P_MAX_MISSED_PONGS"); exist {
+		maxMissedPongs, err = strconv.Atoi(missedEnv)
+		if err != nil {
+			log.Fatalf("Invalid P2P_MAX_MISSED_PONGS: %v", err)
+		}
+	}
+	pool.SetKeepalive(lookupDuration("P2P_PING_INTERVAL", connectionpool.DefaultPingInterval), maxMissedPongs)
+
+	confirmationDepth := chain.DefaultConfirmationDepth
+	if depthEnv, exist := os.LookupEnv("CONFIRMATION_DEPTH"); exist {
+		confirmationDepth, err = strconv.Atoi(depthEnv)
+		if err != nil {
+			log.Fatalf("Invalid CONFIRMATION_DEPTH: %v", err)
+		}
+	}
+
+	rebroadcastInterval := lookupDuration("MEMPOOL_REBROADCAST_INTERVAL", mempool.DefaultRebroadcastInterval)
+	maxAge := lookupDuration("MEMPOOL_MAX_AGE", mempool.DefaultMaxAge)
+	// the kafka offset of a deal is committed once its transaction is in the mempool,
+	// so the pool is always kept on disk
+	mempoolPath, exist := os.LookupEnv("MEMPOOL_PATH")
+	if !exist {
+		mempoolPath = "data/mempool.json"
+	}
+	pendingPool, err := mempool.Open(mempoolPath, rebroadcastInterval, maxAge)
+	if err != nil {
+		log.Fatalf("Failed to open mempool: %v", err)
+	}
 
+	outboxPath, exist := os.LookupEnv("OUTBOX_PATH")
+	if !exist {
+		outboxPath = "data/outbox.jsonl"
+	}
+	eventOutbox, err := outbox.Open(outboxPath)
+	if err != nil {
+		log.Fatalf("Failed to open outbox: %v", err)
+	}
+
+	// the last block whose deal events reached the outbox, blocks above it are announced again after a restart
+	confirmerPath, exist := os.LookupEnv("CONFIRMER_PATH")
+	if !exist {
+		confirmerPath = "data/confirmed.json"
+	}
+
+	blockChain := chain.New()
+	// hashes of trusted blocks a node may start from without the blocks below them,
+	// e.g. CHAIN_CHECKPOINTS=000159c1...,000991fe...
+	blockChain.SetCheckpoints(splitList(os.Getenv("CHAIN_CHECKPOINTS")))
+	confirmer, err := chain.OpenConfirmer(blockChain, confirmationDepth, confirmerPath)
+	if err != nil {
+		log.Fatalf("Failed to open confirmer state: %v", err)
+	}
 	appState := app.AppState{
 		Server:       &server,
-		KafkaChan:    make(chan messageProtocol.MessageInterface, 100),
 		ProtocolChan: protocolChan,
+		BlockStore:   blockStore,
+		Chain:        blockChain,
+		Confirmer:    confirmer,
+		Mempool:      pendingPool,
+		Tracker:      tracker.New(lookupDuration("DEAL_TRACKER_RETENTION", tracker.DefaultRetention)),
+		Outbox:       eventOutbox,
+		Pool:         &pool,
 	}
+	appState.RestoreChain()
+	appState.RestoreMempool()
 
 	p2pprotocol := protocol.NewProtocol(protocolChan, &appState, poolChan)
+	appState.Penalties = p2pprotocol.Penalties
+
+	difficulty := block.DefaultDifficulty
+	if difficultyEnv, exist := os.LookupEnv("BLOCK_DIFFICULTY"); exist {
+		difficulty, err = strconv.Atoi(difficultyEnv)
+		if err != nil {
+			log.Fatalf("Invalid BLOCK_DIFFICULTY: %v", err)
+		}
+	}
+	p2pprotocol.SetDifficulty(difficulty)
+
+	// compatibility with peers that still sign "sender:message:transfer" or payload versions 1-2
+	if allowLegacy, exist := os.LookupEnv("ALLOW_LEGACY_SIGNATURES"); exist {
+		allow, err := strconv.ParseBool(allowLegacy)
+		if err != nil {
+			log.Fatalf("Invalid ALLOW_LEGACY_SIGNATURES: %v", err)
+		}
+		transaction.SetLegacySignatures(allow)
+	}
+
+	// consumers of SpringGetDeal that expect the raw deal message instead of the envelope
+	if legacyPayload, exist := os.LookupEnv("KAFKA_LEGACY_PAYLOAD"); exist {
+		legacy, err := strconv.ParseBool(legacyPayload)
+		if err != nil {
+			log.Fatalf("Invalid KAFKA_LEGACY_PAYLOAD: %v", err)
+		}
+		dealevent.SetLegacyPayloads(legacy)
+	}
+
+	// accepted typeName values of deal orders, e.g. DEAL_BUY_TYPES=Покупка,buy,
+	// an unset side keeps the defaults of the validation package
+	validation.SetOrderTypes(splitList(os.Getenv("DEAL_BUY_TYPES")), splitList(os.Getenv("DEAL_SELL_TYPES")))
 
 	return &server, &pool, &p2pprotocol, &appState
 }
 
+// splitList splits a comma separated env value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func main() {
 	var wg sync.WaitGroup
 	// initialize blockchain
-	newWallet := wallet.New()
+	newWallet := loadWallet()
 	server, pool, p2pprotocol, appState := initialize()
 
+	// the node key identifies this node in the handshake with peers
+	listenAddress, exist := os.LookupEnv("P2P_LISTEN_ADDRESS")
+	if !exist {
+		listenAddress = "0.0.0.0:7878"
+	}
+	server.SetIdentity(newWallet)
+	server.SetListenAddress(listenAddress)
+	server.SetChainTip(func() handshake.Tip {
+		tip, _ := appState.Chain.Tip()
+		return handshake.Tip{Height: tip.Height, Hash: tip.Hash}
+	})
+
+	server.SetTransport(loadTransport(newWallet))
+
+	// peers that connect without a handshake are accepted in the legacy line mode
+	if allowLegacy, exist := os.LookupEnv("P2P_ALLOW_LEGACY_PEERS"); exist {
+		allow, err := strconv.ParseBool(allowLegacy)
+		if err != nil {
+			log.Fatalf("Invalid P2P_ALLOW_LEGACY_PEERS: %v", err)
+		}
+		server.SetAllowLegacy(allow)
+	}
+
 	kafkaHost, exist := os.LookupEnv("KAFKA_HOST")
 
 	if !exist {
@@ -99,22 +365,35 @@ func main() {
 	kafkaProcessProducer := process.NewKafkaProcess(kafkaHost, "SpringGetDeal", "example-group")
 	kafkaProcessConsumer := process.NewKafkaProcess(kafkaHost, "GoGetDeal", "middle-group")
 
+	// invalid deals from GoGetDeal are moved here with the failure reason in headers
+	deadLetterTopic, exist := os.LookupEnv("KAFKA_DEAD_LETTER_TOPIC")
+	if !exist {
+		deadLetterTopic = "GoGetDealDLQ"
+	}
+	kafkaProcessDeadLetter := process.NewKafkaProcess(kafkaHost, deadLetterTopic, "")
+	kafkaProcessDeadLetter.ConnectWriter()
+	defer kafkaProcessDeadLetter.Close()
+
 	//blockchain kafka
 	wg.Add(1)
 	go p2pprotocol.Run()
 	wg.Add(1)
 	go pool.Run()
 	wg.Add(1)
-	go server.Run("0.0.0.0:7878")
+	go server.Run(listenAddress)
 
 	//kafka run
 	wg.Add(1)
-	go readFromKafkaMessage(kafkaProcessConsumer, appState, newWallet)
+	go readFromKafkaMessage(kafkaProcessConsumer, kafkaProcessDeadLetter, appState, newWallet)
+	wg.Add(1)
+	go sendToKafkaMessage(kafkaProcessProducer, appState.Outbox)
+
+	// pending transactions rebroadcast
 	wg.Add(1)
-	go sendToKafkaMessage(kafkaProcessProducer, appState.KafkaChan)
+	go appState.RunMempool(time.Second)
 
 	// web server setting
-	web_server := web.New("8080")
+	web_server := web.New("8080", appState)
 	wg.Add(1)
 	go web_server.Run()
 